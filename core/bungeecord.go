@@ -0,0 +1,309 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/google/uuid"
+	"gopro/core/component"
+	"gopro/core/event"
+	"io"
+	"net"
+	"strings"
+)
+
+// BungeeCordChannel is the channel backend plugins talk to BungeeCord proxies on.
+const BungeeCordChannel = "bungeecord:main"
+
+// registerBungeeCordChannel answers the messages backend plugins send to BungeeCord proxies, as
+// long as the config enables it. Clients may not send on the channel, their messages are dropped.
+func (p *Proxy) registerBungeeCordChannel() {
+	_ = p.channels.Register(BungeeCordChannel)
+
+	event.Subscribe(p.eventBus, event.Late, func(e *event.PluginMessageEvent) {
+		if e.Channel != BungeeCordChannel || !p.Config().BungeeCordMessaging {
+			return
+		}
+		e.SetHandled(true)
+
+		player, ok := e.Target.(*Player)
+		if e.FromClient() || !ok {
+			return
+		}
+
+		if err := p.handleBungeeCordMessage(player, e.Data); err != nil {
+			player.logger.Debug().Err(err).Msg("Invalid BungeeCord plugin message")
+		}
+	})
+}
+
+// handleBungeeCordMessage runs a subchannel of the BungeeCord protocol, which the server of the
+// player sent. Answers go back to that server, through the connection of the player.
+func (p *Proxy) handleBungeeCordMessage(player *Player, data []byte) error {
+	in := &bungeeReader{Reader: bytes.NewReader(data)}
+	subchannel := in.readUTF()
+	out := &bungeeWriter{}
+
+	switch subchannel {
+	case "Connect":
+		server := p.Server(in.readUTF())
+		if server != nil {
+			go p.bungeeConnect(player, server)
+		}
+	case "ConnectOther":
+		target := p.PlayerByName(in.readUTF())
+		server := p.Server(in.readUTF())
+		if target != nil && server != nil {
+			go p.bungeeConnect(target, server)
+		}
+	case "IP":
+		out.writeUTF("IP")
+		writeAddress(out, player.RemoteAddr())
+	case "IPOther":
+		name := in.readUTF()
+		if target := p.PlayerByName(name); target != nil {
+			out.writeUTF("IPOther")
+			out.writeUTF(target.Username())
+			writeAddress(out, target.RemoteAddr())
+		}
+	case "PlayerCount":
+		name := in.readUTF()
+		if players, ok := p.bungeePlayers(name); ok {
+			out.writeUTF("PlayerCount")
+			out.writeUTF(name)
+			out.writeInt(int32(len(players)))
+		}
+	case "PlayerList":
+		name := in.readUTF()
+		if players, ok := p.bungeePlayers(name); ok {
+			names := make([]string, len(players))
+			for i, target := range players {
+				names[i] = target.Username()
+			}
+
+			out.writeUTF("PlayerList")
+			out.writeUTF(name)
+			out.writeUTF(strings.Join(names, ", "))
+		}
+	case "GetServers":
+		var names []string
+		for _, server := range p.Servers() {
+			names = append(names, server.Name())
+		}
+
+		out.writeUTF("GetServers")
+		out.writeUTF(strings.Join(names, ", "))
+	case "GetServer":
+		if server := player.CurrentServer(); server != nil {
+			out.writeUTF("GetServer")
+			out.writeUTF(server.Name())
+		}
+	case "Message", "MessageRaw":
+		name := in.readUTF()
+		text := in.readUTF()
+
+		message := component.ParseLegacy(text, '§')
+		if subchannel == "MessageRaw" {
+			raw, err := component.Deserialize(text)
+			if err != nil {
+				return err
+			}
+			message = raw
+		}
+
+		targets := p.Players()
+		if name != "ALL" {
+			targets = nil
+			if target := p.PlayerByName(name); target != nil {
+				targets = []*Player{target}
+			}
+		}
+		for _, target := range targets {
+			_ = target.SendMessage(message)
+		}
+	case "KickPlayer":
+		target := p.PlayerByName(in.readUTF())
+		reason := in.readUTF()
+		if target != nil {
+			target.Disconnect(component.ParseLegacy(reason, '§'))
+		}
+	case "Forward":
+		name := in.readUTF()
+		forward := in.readForward()
+		if in.err == nil {
+			p.bungeeForward(player.CurrentServer(), name, forward)
+		}
+	case "ForwardToPlayer":
+		target := p.PlayerByName(in.readUTF())
+		forward := in.readForward()
+		if in.err == nil && target != nil {
+			_ = target.SendServerPluginMessage(BungeeCordChannel, forward)
+		}
+	case "UUID":
+		out.writeUTF("UUID")
+		out.writeUTF(undashed(player.UUID()))
+	case "UUIDOther":
+		name := in.readUTF()
+		if target := p.PlayerByName(name); target != nil {
+			out.writeUTF("UUIDOther")
+			out.writeUTF(target.Username())
+			out.writeUTF(undashed(target.UUID()))
+		}
+	case "ServerIP":
+		name := in.readUTF()
+		if server := p.Server(name); server != nil {
+			out.writeUTF("ServerIP")
+			out.writeUTF(name)
+			if tcp, ok := server.Addr().(*net.TCPAddr); ok {
+				out.writeUTF(tcp.IP.String())
+				out.writeShort(uint16(tcp.Port))
+			} else {
+				host, _, _ := net.SplitHostPort(server.Addr().String())
+				out.writeUTF(host)
+				out.writeShort(0)
+			}
+		}
+	default:
+		if in.err == nil {
+			return errors.New("unknown subchannel " + subchannel)
+		}
+	}
+
+	if in.err != nil {
+		return in.err
+	}
+
+	if out.Len() > 0 {
+		return player.SendServerPluginMessage(BungeeCordChannel, out.Bytes())
+	}
+
+	return nil
+}
+
+func (p *Proxy) bungeeConnect(player *Player, server *ServerInfo) {
+	if err := player.Connect(server); err != nil {
+		player.logger.Debug().Err(err).Str("server", server.Name()).Msg("Failed to connect for a BungeeCord message")
+	}
+}
+
+// bungeePlayers are the players on the server of the name, all players for "ALL". It returns
+// false for unknown servers.
+func (p *Proxy) bungeePlayers(name string) ([]*Player, bool) {
+	if name == "ALL" {
+		return p.Players(), true
+	}
+
+	server := p.Server(name)
+	if server == nil {
+		return nil, false
+	}
+
+	var players []*Player
+	for _, player := range p.Players() {
+		if player.CurrentServer() == server {
+			players = append(players, player)
+		}
+	}
+
+	return players, true
+}
+
+// bungeeForward passes a Forward message on to the server of the name, or for "ALL" and "ONLINE"
+// to every other server. Messages reach servers through one of their players, servers without
+// players don't get them.
+func (p *Proxy) bungeeForward(from *ServerInfo, name string, data []byte) {
+	carriers := make(map[*ServerInfo]*Player)
+	for _, player := range p.Players() {
+		if server := player.CurrentServer(); server != nil {
+			carriers[server] = player
+		}
+	}
+
+	for server, carrier := range carriers {
+		all := (name == "ALL" || name == "ONLINE") && server != from
+		if all || server.Name() == name {
+			_ = carrier.SendServerPluginMessage(BungeeCordChannel, data)
+		}
+	}
+}
+
+func writeAddress(out *bungeeWriter, addr net.Addr) {
+	host, port := addr.String(), 0
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		host, port = tcp.IP.String(), tcp.Port
+	}
+
+	out.writeUTF(host)
+	out.writeInt(int32(port))
+}
+
+// undashed is the uuid as BungeeCord writes it, without dashes.
+func undashed(id uuid.UUID) string {
+	return strings.ReplaceAll(id.String(), "-", "")
+}
+
+// bungeeReader reads the Java DataInput encoding BungeeCord messages use, keeping the first error.
+type bungeeReader struct {
+	*bytes.Reader
+	err error
+}
+
+var errShortMessage = errors.New("message ends early")
+
+func (r *bungeeReader) read(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r.Reader, data); err != nil {
+		r.err = errShortMessage
+		return nil
+	}
+
+	return data
+}
+
+func (r *bungeeReader) readUTF() string {
+	length := r.read(2)
+	if length == nil {
+		return ""
+	}
+
+	return string(r.read(int(binary.BigEndian.Uint16(length))))
+}
+
+// readForward reads the data of a Forward message and wraps it as the receiving servers get it,
+// the subchannel followed by the length prefixed data.
+func (r *bungeeReader) readForward() []byte {
+	subchannel := r.readUTF()
+	length := r.read(2)
+	if length == nil {
+		return nil
+	}
+	data := r.read(int(binary.BigEndian.Uint16(length)))
+
+	out := &bungeeWriter{}
+	out.writeUTF(subchannel)
+	out.writeShort(uint16(len(data)))
+	out.Write(data)
+	return out.Bytes()
+}
+
+// bungeeWriter writes the Java DataOutput encoding BungeeCord messages use.
+type bungeeWriter struct {
+	bytes.Buffer
+}
+
+func (w *bungeeWriter) writeUTF(s string) {
+	w.writeShort(uint16(len(s)))
+	w.WriteString(s)
+}
+
+func (w *bungeeWriter) writeShort(v uint16) {
+	_ = binary.Write(&w.Buffer, binary.BigEndian, v)
+}
+
+func (w *bungeeWriter) writeInt(v int32) {
+	_ = binary.Write(&w.Buffer, binary.BigEndian, v)
+}
@@ -0,0 +1,176 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"gopro/core/event"
+	"gopro/core/proto"
+	"gopro/core/proto/encoding"
+	"gopro/core/proto/packets"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// channelPattern matches channel identifiers like minecraft:brand or ourteam:sync.
+var channelPattern = regexp.MustCompile(`^[a-z0-9._-]+:[a-z0-9._/-]+$`)
+
+// ChannelRegistrar holds the plugin message channels the proxy handles. Messages on these
+// channels fire a PluginMessageEvent, messages on other channels pass through untouched.
+type ChannelRegistrar struct {
+	mu       sync.RWMutex
+	channels map[string]struct{}
+}
+
+func newChannelRegistrar() *ChannelRegistrar {
+	return &ChannelRegistrar{channels: make(map[string]struct{})}
+}
+
+// Register adds the channels, none of them if one of them isn't a valid identifier.
+func (r *ChannelRegistrar) Register(channels ...string) error {
+	for _, channel := range channels {
+		if !channelPattern.MatchString(channel) {
+			return fmt.Errorf("invalid channel identifier %q, expected namespace:name", channel)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, channel := range channels {
+		r.channels[channel] = struct{}{}
+	}
+
+	return nil
+}
+
+func (r *ChannelRegistrar) Unregister(channels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, channel := range channels {
+		delete(r.channels, channel)
+	}
+}
+
+func (r *ChannelRegistrar) Registered(channel string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.channels[channel]
+	return ok
+}
+
+// Channels lists the registered channels, sorted.
+func (r *ChannelRegistrar) Channels() []string {
+	r.mu.RLock()
+	channels := make([]string, 0, len(r.channels))
+	for channel := range r.channels {
+		channels = append(channels, channel)
+	}
+	r.mu.RUnlock()
+
+	sort.Strings(channels)
+	return channels
+}
+
+// pluginMessage is the plugin message packet for the state and direction, nil if there is none.
+func pluginMessage(state byte, protocol int, clientbound bool, channel string, data []byte) proto.Definition {
+	switch {
+	case state == proto.Configuration && clientbound:
+		return &packets.ConfigClientboundPluginMessage{Channel: encoding.String(channel), Data: data}
+	case state == proto.Configuration:
+		return &packets.ConfigServerboundPluginMessage{Channel: encoding.String(channel), Data: data}
+	case state == proto.Play && protocol >= packets.PlayProtocol && clientbound:
+		return &packets.ClientboundPluginMessage{Channel: encoding.String(channel), Data: data}
+	case state == proto.Play && protocol >= packets.PlayProtocol:
+		return &packets.ServerboundPluginMessage{Channel: encoding.String(channel), Data: data}
+	}
+
+	return nil
+}
+
+// pluginMessageID is the id of the plugin message packet for the state and direction.
+func pluginMessageID(state byte, protocol int, clientbound bool) (byte, bool) {
+	switch {
+	case state == proto.Configuration && clientbound:
+		return (&packets.ConfigClientboundPluginMessage{}).ID(protocol), true
+	case state == proto.Configuration:
+		return (&packets.ConfigServerboundPluginMessage{}).ID(protocol), true
+	case state == proto.Play && protocol >= packets.PlayProtocol && clientbound:
+		return (&packets.ClientboundPluginMessage{}).ID(protocol), true
+	case state == proto.Play && protocol >= packets.PlayProtocol:
+		return (&packets.ServerboundPluginMessage{}).ID(protocol), true
+	}
+
+	return 0, false
+}
+
+// readPluginMessage reads the packet if it is a plugin message of the state and direction.
+func readPluginMessage(conn *Conn, packet *proto.Packet, clientbound bool) (channel string, data []byte, ok bool) {
+	id, ok := pluginMessageID(conn.State(), conn.ProtocolVersion, clientbound)
+	if !ok || packet.ID != id {
+		return "", nil, false
+	}
+
+	// the plugin messages of all states and directions are laid out alike
+	var message packets.ClientboundPluginMessage
+	if err := conn.ReadPacket(packet, &message); err != nil {
+		return "", nil, false
+	}
+
+	return string(message.Channel), message.Data, true
+}
+
+// handlePluginMessage fires PluginMessageEvent if the packet read from the connection is a plugin
+// message on a registered channel, returning whether a handler handled it. The server is the one
+// the player is on, nil while it is switching servers.
+func (p *Player) handlePluginMessage(conn *Conn, packet *proto.Packet, fromClient bool, server *ServerInfo) bool {
+	channel, data, ok := readPluginMessage(conn, packet, !fromClient)
+	if !ok || !p.proxy.channels.Registered(channel) {
+		return false
+	}
+
+	e := &event.PluginMessageEvent{Channel: channel, Data: data}
+	var endpoint event.ChannelEndpoint
+	if server != nil {
+		endpoint = server
+	}
+	if fromClient {
+		e.Source, e.Target = p, endpoint
+	} else {
+		e.Source, e.Target = endpoint, p
+	}
+
+	p.proxy.eventBus.Fire(e)
+	return e.Handled()
+}
+
+// SendPluginMessage sends the message to the client of the player, which has to be in the
+// configuration or play state.
+func (p *Player) SendPluginMessage(channel string, data []byte) error {
+	message := pluginMessage(p.conn.State(), p.conn.ProtocolVersion, true, channel, data)
+	if message == nil {
+		return errors.New("can't send plugin messages to this player")
+	}
+
+	return p.conn.WritePacket(message)
+}
+
+// SendServerPluginMessage sends the message to the server the player is on.
+func (p *Player) SendServerPluginMessage(channel string, data []byte) error {
+	p.mu.Lock()
+	sc := p.server
+	p.mu.Unlock()
+
+	if sc == nil {
+		return errors.New("not connected to a server")
+	}
+
+	message := pluginMessage(sc.conn.State(), sc.conn.ProtocolVersion, false, channel, data)
+	if message == nil {
+		return errors.New("can't send plugin messages to this server")
+	}
+
+	return sc.conn.WritePacket(message)
+}
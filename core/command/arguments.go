@@ -0,0 +1,129 @@
+package command
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+// Ids of the Brigadier argument parsers in the registry of the client, since 1.19.
+const (
+	parserBool    = 0
+	parserInteger = 3
+	parserString  = 5
+)
+
+// ArgumentType parses the argument of a node.
+type ArgumentType interface {
+	Parse(r *Reader) (any, error)
+	// Brigadier is the id of the parser the client uses for the argument, with its encoded properties.
+	Brigadier() (parser int32, properties []byte)
+}
+
+type stringType struct {
+	behaviour byte
+}
+
+// Word is a string up to the next space, parsed as string.
+func Word() ArgumentType {
+	return stringType{0}
+}
+
+// String is a word or a quoted phrase, parsed as string.
+func String() ArgumentType {
+	return stringType{1}
+}
+
+// GreedyString is the rest of the input, parsed as string.
+func GreedyString() ArgumentType {
+	return stringType{2}
+}
+
+func (t stringType) Parse(r *Reader) (any, error) {
+	switch t.behaviour {
+	case 1:
+		return r.ReadString()
+	case 2:
+		return r.ReadRemaining(), nil
+	}
+
+	return r.ReadUnquoted(), nil
+}
+
+func (t stringType) Brigadier() (int32, []byte) {
+	return parserString, []byte{t.behaviour}
+}
+
+type integerType struct {
+	min int32
+	max int32
+}
+
+// Integer is a whole number, parsed as int.
+func Integer() ArgumentType {
+	return integerType{math.MinInt32, math.MaxInt32}
+}
+
+// IntegerBetween is a whole number from min to max, parsed as int.
+func IntegerBetween(min int32, max int32) ArgumentType {
+	return integerType{min, max}
+}
+
+func (t integerType) Parse(r *Reader) (any, error) {
+	start := r.Cursor()
+
+	value, err := strconv.ParseInt(r.ReadUnquoted(), 10, 32)
+	if err != nil {
+		r.SetCursor(start)
+		return nil, r.errorf("expected integer")
+	}
+
+	if int32(value) < t.min || int32(value) > t.max {
+		r.SetCursor(start)
+		return nil, r.errorf("integer must be between %d and %d", t.min, t.max)
+	}
+
+	return int(value), nil
+}
+
+func (t integerType) Brigadier() (int32, []byte) {
+	var flags byte
+	properties := []byte{0}
+
+	if t.min != math.MinInt32 {
+		flags |= 0x01
+		properties = binary.BigEndian.AppendUint32(properties, uint32(t.min))
+	}
+	if t.max != math.MaxInt32 {
+		flags |= 0x02
+		properties = binary.BigEndian.AppendUint32(properties, uint32(t.max))
+	}
+	properties[0] = flags
+
+	return parserInteger, properties
+}
+
+type boolType struct{}
+
+// Bool is true or false, parsed as bool.
+func Bool() ArgumentType {
+	return boolType{}
+}
+
+func (boolType) Parse(r *Reader) (any, error) {
+	start := r.Cursor()
+
+	switch r.ReadUnquoted() {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	r.SetCursor(start)
+	return nil, r.errorf("expected true or false")
+}
+
+func (boolType) Brigadier() (int32, []byte) {
+	return parserBool, nil
+}
@@ -0,0 +1,136 @@
+package command
+
+import (
+	"errors"
+	"gopro/core/proto/encoding"
+)
+
+// Flags of the nodes of the Commands packet.
+const (
+	nodeRoot        = 0x00
+	nodeLiteral     = 0x01
+	nodeArgument    = 0x02
+	nodeTypeMask    = 0x03
+	nodeExecutable  = 0x04
+	nodeRedirect    = 0x08
+	nodeSuggestions = 0x10
+)
+
+// askServer makes the client ask the proxy for the completions of an argument.
+const askServer = "minecraft:ask_server"
+
+// Inject adds the commands the source can use to the data of a Commands packet of a server, so
+// the client knows them. Vanilla servers write the root node first; graphs written otherwise are
+// rejected, the packet should then be passed on unchanged.
+func (d *Dispatcher) Inject(data []byte, source Source) ([]byte, error) {
+	buffer := encoding.NewBuffer(data)
+
+	var count encoding.Varint
+	var flags encoding.Byte
+	var childCount encoding.Varint
+	if err := buffer.Read(&count, &flags, &childCount); err != nil {
+		return nil, err
+	}
+	if flags&nodeTypeMask != nodeRoot || flags&nodeRedirect != 0 || childCount < 0 {
+		return nil, errors.New("first node isn't the root")
+	}
+	// every child index takes a byte at least
+	if int(childCount) > len(buffer.Unread()) {
+		return nil, errors.New("root has more children than the packet has bytes")
+	}
+
+	children := make([]int32, childCount)
+	for i := range children {
+		var child encoding.Varint
+		if err := child.Read(buffer); err != nil {
+			return nil, err
+		}
+		children[i] = int32(child)
+	}
+
+	// the other nodes are passed on as they are, up to the root index ending the packet
+	rest := buffer.Remaining()
+	if len(rest) == 0 || rest[len(rest)-1] != 0 {
+		return nil, errors.New("root isn't the first node")
+	}
+	nodes := rest[:len(rest)-1]
+
+	d.mu.RLock()
+	commands := d.root.usableChildren(source)
+	ours := flatten(commands, source)
+	d.mu.RUnlock()
+
+	offset := int32(count)
+	index := make(map[*Node]int32, len(ours))
+	for i, node := range ours {
+		index[node] = offset + int32(i)
+	}
+
+	out := make([]byte, 0, len(data)+64*len(ours))
+	encoding.Varint(int(offset) + len(ours)).WriteIntoSlice(&out)
+
+	out = append(out, nodeRoot)
+	encoding.Varint(len(children) + len(commands)).WriteIntoSlice(&out)
+	for _, child := range children {
+		encoding.Varint(child).WriteIntoSlice(&out)
+	}
+	for _, command := range commands {
+		encoding.Varint(index[command]).WriteIntoSlice(&out)
+	}
+
+	out = append(out, nodes...)
+	for _, node := range ours {
+		out = appendNode(out, node, index, source)
+	}
+
+	encoding.Varint(0).WriteIntoSlice(&out)
+	return out, nil
+}
+
+// flatten lists the nodes of the trees the source can use, parents before children.
+func flatten(roots []*Node, source Source) []*Node {
+	nodes := append([]*Node(nil), roots...)
+	for i := 0; i < len(nodes); i++ {
+		nodes = append(nodes, nodes[i].usableChildren(source)...)
+	}
+
+	return nodes
+}
+
+func appendNode(out []byte, node *Node, index map[*Node]int32, source Source) []byte {
+	var flags byte = nodeLiteral
+	if !node.IsLiteral() {
+		flags = nodeArgument
+	}
+	if node.command != nil {
+		flags |= nodeExecutable
+	}
+	if node.suggestions != nil && !node.IsLiteral() {
+		flags |= nodeSuggestions
+	}
+	out = append(out, flags)
+
+	children := node.usableChildren(source)
+	encoding.Varint(len(children)).WriteIntoSlice(&out)
+	for _, child := range children {
+		encoding.Varint(index[child]).WriteIntoSlice(&out)
+	}
+
+	out = appendString(out, node.name)
+
+	if !node.IsLiteral() {
+		parser, properties := node.argument.Brigadier()
+		encoding.Varint(parser).WriteIntoSlice(&out)
+		out = append(out, properties...)
+	}
+	if flags&nodeSuggestions != 0 {
+		out = appendString(out, askServer)
+	}
+
+	return out
+}
+
+func appendString(out []byte, s string) []byte {
+	encoding.Varint(len(s)).WriteIntoSlice(&out)
+	return append(out, s...)
+}
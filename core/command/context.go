@@ -0,0 +1,41 @@
+package command
+
+// Context is what a command runs with: its source, input and parsed arguments.
+type Context struct {
+	Source Source
+	Input  string
+	args   map[string]any
+}
+
+func newContext(source Source, input string) *Context {
+	return &Context{Source: source, Input: input, args: make(map[string]any)}
+}
+
+// Arg returns the value of the argument, nil if it wasn't given.
+func (c *Context) Arg(name string) any {
+	return c.args[name]
+}
+
+// Has tells whether the argument was given.
+func (c *Context) Has(name string) bool {
+	_, ok := c.args[name]
+	return ok
+}
+
+// String returns the value of a string argument, "" if it wasn't given.
+func (c *Context) String(name string) string {
+	s, _ := c.args[name].(string)
+	return s
+}
+
+// Int returns the value of an integer argument, 0 if it wasn't given.
+func (c *Context) Int(name string) int {
+	i, _ := c.args[name].(int)
+	return i
+}
+
+// Bool returns the value of a bool argument, false if it wasn't given.
+func (c *Context) Bool(name string) bool {
+	b, _ := c.args[name].(bool)
+	return b
+}
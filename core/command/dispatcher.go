@@ -0,0 +1,225 @@
+package command
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownCommand is returned for input that isn't a registered command the source can use.
+var ErrUnknownCommand = errors.New("unknown command")
+
+// Dispatcher holds the registered commands and runs them.
+type Dispatcher struct {
+	mu   sync.RWMutex
+	root *Node
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{root: &Node{}}
+}
+
+// Register adds the command, replacing a command of the same name.
+func (d *Dispatcher) Register(command *Node) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.removeChild(command.name)
+	d.root.children = append(d.root.children, command)
+}
+
+// TryRegister adds the command unless a command of the same name is registered, telling whether
+// it was added.
+func (d *Dispatcher) TryRegister(command *Node) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, child := range d.root.children {
+		if child.name == command.name {
+			return false
+		}
+	}
+
+	d.root.children = append(d.root.children, command)
+	return true
+}
+
+// Unregister removes the command of the name.
+func (d *Dispatcher) Unregister(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.removeChild(name)
+}
+
+func (d *Dispatcher) removeChild(name string) {
+	children := d.root.children[:0]
+	for _, child := range d.root.children {
+		if child.name != name {
+			children = append(children, child)
+		}
+	}
+	d.root.children = children
+}
+
+// Commands lists the commands the source can use, sorted by name.
+func (d *Dispatcher) Commands(source Source) []*Node {
+	d.mu.RLock()
+	commands := d.root.usableChildren(source)
+	d.mu.RUnlock()
+
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].name < commands[j].name
+	})
+
+	return commands
+}
+
+// All lists every registered command, whether sources can use it or not.
+func (d *Dispatcher) All() []*Node {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return append([]*Node(nil), d.root.children...)
+}
+
+// Has tells whether the input, without the leading slash, is one of the commands the source can use.
+func (d *Dispatcher) Has(source Source, input string) bool {
+	name, _, _ := strings.Cut(input, " ")
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, child := range d.root.children {
+		if child.name == name {
+			return child.CanUse(source)
+		}
+	}
+
+	return false
+}
+
+// Execute parses and runs the input, without the leading slash. Input that doesn't parse
+// returns a SyntaxError, input that isn't a command ErrUnknownCommand.
+func (d *Dispatcher) Execute(source Source, input string) error {
+	if !d.Has(source, input) {
+		return ErrUnknownCommand
+	}
+
+	ctx := newContext(source, input)
+	r := NewReader(input)
+
+	node, err := d.parse(r, ctx)
+	if err != nil {
+		return err
+	}
+
+	if node.command == nil {
+		return r.errorf("unknown or incomplete command")
+	}
+
+	// commands may register commands, so they run without the lock
+	return node.command(ctx)
+}
+
+// parse follows the input down the tree, returning the node it ends on.
+func (d *Dispatcher) parse(r *Reader, ctx *Context) (*Node, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	node := d.root
+	for r.CanRead() {
+		if node != d.root {
+			if r.Peek() != ' ' {
+				return nil, r.errorf("expected whitespace to end one argument, but found trailing data")
+			}
+			r.Skip()
+		}
+
+		next, err := d.next(node, r, ctx)
+		if err != nil {
+			return nil, err
+		}
+		node = next
+	}
+
+	return node, nil
+}
+
+// next consumes the first child of the node the input matches.
+func (d *Dispatcher) next(node *Node, r *Reader, ctx *Context) (*Node, error) {
+	start := r.Cursor()
+	var firstErr error
+
+	for _, child := range node.usableChildren(ctx.Source) {
+		r.SetCursor(start)
+
+		err := child.consume(r, ctx)
+		if err == nil && (!r.CanRead() || r.Peek() == ' ') {
+			return child, nil
+		}
+		if err == nil {
+			err = r.errorf("expected whitespace to end one argument, but found trailing data")
+		}
+		if firstErr == nil || !child.IsLiteral() {
+			firstErr = err
+		}
+	}
+
+	r.SetCursor(start)
+	if firstErr == nil {
+		return nil, r.errorf("incorrect argument for command")
+	}
+
+	return nil, firstErr
+}
+
+// Suggest completes the last word of the input, without the leading slash. Start is where in
+// the input the word the suggestions replace begins.
+func (d *Dispatcher) Suggest(source Source, input string) (start int, suggestions []string) {
+	ctx := newContext(source, input)
+	r := NewReader(input)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	node := d.root
+	for {
+		start = r.Cursor()
+		matched := false
+
+		for _, child := range node.usableChildren(source) {
+			r.SetCursor(start)
+			if child.consume(r, ctx) == nil && r.CanRead() && r.Peek() == ' ' {
+				r.Skip()
+				node = child
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			break
+		}
+	}
+
+	partial := input[start:]
+	for _, child := range node.usableChildren(source) {
+		var candidates []string
+		if child.IsLiteral() {
+			candidates = []string{child.name}
+		} else if child.suggestions != nil {
+			candidates = child.suggestions(ctx, partial)
+		}
+
+		for _, candidate := range candidates {
+			if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(partial)) {
+				suggestions = append(suggestions, candidate)
+			}
+		}
+	}
+
+	sort.Strings(suggestions)
+	return start, suggestions
+}
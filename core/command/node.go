@@ -0,0 +1,112 @@
+package command
+
+import (
+	"gopro/core/component"
+)
+
+// Source is who runs a command, a player or the console.
+type Source interface {
+	Name() string
+	SendMessage(message *component.TextComponent) error
+}
+
+// Node is a node of a command tree: a literal word or a typed argument, which may run a command
+// when the input ends on it. Trees are built like Brigadier's:
+//
+//	command.Literal("server").
+//		Executes(listServers).
+//		Then(command.Argument("name", command.Word()).Executes(connect))
+type Node struct {
+	name     string
+	argument ArgumentType
+	children []*Node
+
+	command     func(ctx *Context) error
+	requirement func(source Source) bool
+	suggestions func(ctx *Context, partial string) []string
+}
+
+// Literal makes a node matching the name.
+func Literal(name string) *Node {
+	return &Node{name: name}
+}
+
+// Argument makes a node parsing an argument of the type, its value is read from the Context by name.
+func Argument(name string, argument ArgumentType) *Node {
+	return &Node{name: name, argument: argument}
+}
+
+// Then adds the children to the node.
+func (n *Node) Then(children ...*Node) *Node {
+	n.children = append(n.children, children...)
+	return n
+}
+
+// Executes sets the command run when the input ends on the node.
+func (n *Node) Executes(command func(ctx *Context) error) *Node {
+	n.command = command
+	return n
+}
+
+// Requires hides the node, and its children, from the sources the requirement fails for.
+func (n *Node) Requires(requirement func(source Source) bool) *Node {
+	n.requirement = requirement
+	return n
+}
+
+// Suggests sets how completions of an argument are found. Clients ask the proxy for them.
+func (n *Node) Suggests(suggestions func(ctx *Context, partial string) []string) *Node {
+	n.suggestions = suggestions
+	return n
+}
+
+func (n *Node) Name() string {
+	return n.name
+}
+
+// IsLiteral tells whether the node is a literal, rather than an argument.
+func (n *Node) IsLiteral() bool {
+	return n.argument == nil
+}
+
+// CanUse tells whether the source passes the requirement of the node.
+func (n *Node) CanUse(source Source) bool {
+	return n.requirement == nil || n.requirement(source)
+}
+
+// usableChildren are the children the source can use, literals before arguments.
+func (n *Node) usableChildren(source Source) []*Node {
+	var literals, arguments []*Node
+	for _, child := range n.children {
+		if !child.CanUse(source) {
+			continue
+		}
+		if child.IsLiteral() {
+			literals = append(literals, child)
+		} else {
+			arguments = append(arguments, child)
+		}
+	}
+
+	return append(literals, arguments...)
+}
+
+// consume reads the node off the reader, storing the value of an argument in the context.
+func (n *Node) consume(r *Reader, ctx *Context) error {
+	if n.IsLiteral() {
+		start := r.Cursor()
+		if r.ReadUnquoted() != n.name {
+			r.SetCursor(start)
+			return r.errorf("expected %s", n.name)
+		}
+		return nil
+	}
+
+	value, err := n.argument.Parse(r)
+	if err != nil {
+		return err
+	}
+	ctx.args[n.name] = value
+
+	return nil
+}
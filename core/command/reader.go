@@ -0,0 +1,113 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reader reads the words and arguments of a command.
+type Reader struct {
+	input  string
+	cursor int
+}
+
+func NewReader(input string) *Reader {
+	return &Reader{input: input}
+}
+
+func (r *Reader) Cursor() int {
+	return r.cursor
+}
+
+func (r *Reader) SetCursor(cursor int) {
+	r.cursor = cursor
+}
+
+func (r *Reader) CanRead() bool {
+	return r.cursor < len(r.input)
+}
+
+func (r *Reader) Peek() byte {
+	return r.input[r.cursor]
+}
+
+func (r *Reader) Skip() {
+	r.cursor++
+}
+
+// Remaining is the input not read yet.
+func (r *Reader) Remaining() string {
+	return r.input[r.cursor:]
+}
+
+// ReadUnquoted reads up to the next space.
+func (r *Reader) ReadUnquoted() string {
+	start := r.cursor
+	for r.CanRead() && r.Peek() != ' ' {
+		r.cursor++
+	}
+
+	return r.input[start:r.cursor]
+}
+
+// ReadString reads a word or a quoted phrase, in which backslashes escape quotes and backslashes.
+func (r *Reader) ReadString() (string, error) {
+	if !r.CanRead() || (r.Peek() != '"' && r.Peek() != '\'') {
+		return r.ReadUnquoted(), nil
+	}
+
+	quote := r.Peek()
+	start := r.cursor
+	r.Skip()
+
+	var out strings.Builder
+	for r.CanRead() {
+		c := r.Peek()
+		r.Skip()
+
+		switch {
+		case c == '\\':
+			if !r.CanRead() || (r.Peek() != quote && r.Peek() != '\\') {
+				r.cursor = start
+				return "", r.errorf("invalid escape sequence")
+			}
+			out.WriteByte(r.Peek())
+			r.Skip()
+		case c == quote:
+			return out.String(), nil
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	r.cursor = start
+	return "", r.errorf("unclosed quoted string")
+}
+
+// ReadRemaining reads the rest of the input.
+func (r *Reader) ReadRemaining() string {
+	remaining := r.Remaining()
+	r.cursor = len(r.input)
+
+	return remaining
+}
+
+func (r *Reader) errorf(format string, args ...any) *SyntaxError {
+	return &SyntaxError{Message: fmt.Sprintf(format, args...), Input: r.input, Cursor: r.cursor}
+}
+
+// SyntaxError tells where the input of a command couldn't be parsed.
+type SyntaxError struct {
+	Message string
+	Input   string
+	Cursor  int
+}
+
+func (e *SyntaxError) Error() string {
+	context := e.Input[:e.Cursor]
+	if len(context) > 10 {
+		context = "..." + context[len(context)-10:]
+	}
+
+	return fmt.Sprintf("%s at position %d: %s<--[HERE]", e.Message, e.Cursor, context)
+}
@@ -0,0 +1,374 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"gopro/core/command"
+	"gopro/core/component"
+	"sort"
+	"strings"
+)
+
+// consoleSource runs the commands typed into the console, its messages go to the log.
+type consoleSource struct {
+	logger zerolog.Logger
+}
+
+func (c *consoleSource) Name() string {
+	return "CONSOLE"
+}
+
+func (c *consoleSource) SendMessage(message *component.TextComponent) error {
+	c.logger.Info().Msg(component.PlainText(message, nil))
+	return nil
+}
+
+// ExecuteCommand runs the input, without the leading slash, as the source. Failures are
+// reported to the source as well as returned.
+func (p *Proxy) ExecuteCommand(source command.Source, input string) error {
+	p.logger.Info().Str("source", source.Name()).Str("command", input).Msg("Running command")
+
+	err := p.commands.Execute(source, input)
+
+	var syntaxErr *command.SyntaxError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, command.ErrUnknownCommand):
+		sendError(source, "Unknown command.")
+	case errors.As(err, &syntaxErr):
+		sendError(source, syntaxErr.Error())
+	default:
+		p.logger.Error().Err(err).Str("source", source.Name()).Str("command", input).Msg("Error running command")
+		sendError(source, "An error occurred running this command.")
+	}
+
+	return err
+}
+
+// sendError shows the text in red to the source.
+func sendError(source command.Source, text string) {
+	_ = source.SendMessage(component.NewTextComponent(text).WithColor(component.Red))
+}
+
+// sendMarkup shows the markup to the source, dynamic parts have to be escaped with component.EscapeMarkup.
+func sendMarkup(source command.Source, markup string) {
+	_ = source.SendMessage(component.ParseMarkup(markup, nil))
+}
+
+func (p *Proxy) registerBuiltinCommands() {
+	p.commands.Register(p.serverCommand())
+	p.commands.Register(p.glistCommand())
+	p.commands.Register(p.sendCommand())
+	p.commands.Register(p.endCommand())
+	p.commands.Register(p.reloadCommand())
+	p.commands.Register(p.kickCommand())
+	p.commands.Register(p.pluginsCommand())
+}
+
+// serverCommand shows the server of the player or moves it to another one.
+func (p *Proxy) serverCommand() *command.Node {
+	return command.Literal("server").
+		Requires(func(source command.Source) bool {
+			return isPlayer(source) && hasPermission(source, "gopro.command.server")
+		}).
+		Executes(func(ctx *command.Context) error {
+			player := ctx.Source.(*Player)
+
+			if current := player.CurrentServer(); current != nil {
+				sendMarkup(player, "<yellow>You are currently connected to "+component.EscapeMarkup(current.Name())+".")
+			}
+
+			names := make([]string, 0)
+			for _, server := range p.Servers() {
+				if p.CanAccess(player, server) {
+					names = append(names, component.EscapeMarkup(server.Name()))
+				}
+			}
+			sendMarkup(player, "<yellow>Available servers: "+strings.Join(names, ", "))
+
+			var groups []string
+			for _, group := range p.Groups() {
+				groups = append(groups, component.EscapeMarkup(group.Name()))
+			}
+			if len(groups) > 0 {
+				sendMarkup(player, "<yellow>Server groups: "+strings.Join(groups, ", "))
+			}
+			return nil
+		}).
+		Then(command.Argument("server", command.Word()).
+			Suggests(p.suggestServers).
+			Executes(func(ctx *command.Context) error {
+				player := ctx.Source.(*Player)
+
+				name := ctx.String("server")
+				server := p.Server(name)
+				if server == nil && p.Group(name) != nil {
+					// the group picks one of the servers the player can join
+					server = p.pickServer(player, name, []*ServerInfo{player.CurrentServer()})
+					if server == nil {
+						sendError(player, fmt.Sprintf("No server of %s is available.", name))
+						return nil
+					}
+				}
+				if server == nil {
+					sendError(player, fmt.Sprintf("Server %s doesn't exist.", name))
+					return nil
+				}
+
+				if err := player.Connect(server); err != nil {
+					sendError(player, fmt.Sprintf("Can't connect to %s: %s", name, connectFailure(err)))
+				}
+				return nil
+			}))
+}
+
+// glistCommand lists the online players by server.
+func (p *Proxy) glistCommand() *command.Node {
+	return command.Literal("glist").
+		Requires(requirePermission("gopro.command.glist")).
+		Executes(func(ctx *command.Context) error {
+			byServer := make(map[*ServerInfo][]string)
+			for _, player := range p.Players() {
+				if server := player.CurrentServer(); server != nil {
+					byServer[server] = append(byServer[server], player.Username())
+				}
+			}
+
+			for _, server := range p.Servers() {
+				names := byServer[server]
+				if len(names) == 0 {
+					continue
+				}
+
+				sort.Strings(names)
+				sendMarkup(ctx.Source, fmt.Sprintf("<dark_aqua>[%s] <gray>(%d): <white>%s",
+					component.EscapeMarkup(server.Name()), len(names), component.EscapeMarkup(strings.Join(names, ", "))))
+			}
+
+			count := p.PlayerCount()
+			sendMarkup(ctx.Source, fmt.Sprintf("<yellow>There %s %d %s online.", plural(count, "is", "are"), count, plural(count, "player", "players")))
+			return nil
+		})
+}
+
+// sendCommand moves a player, all players or the players on the server of the source to a server.
+func (p *Proxy) sendCommand() *command.Node {
+	return command.Literal("send").
+		Requires(requirePermission("gopro.command.send")).
+		Then(command.Argument("player", command.Word()).
+			Suggests(func(ctx *command.Context, partial string) []string {
+				suggestions := []string{"all"}
+				if isPlayer(ctx.Source) {
+					suggestions = append(suggestions, "current")
+				}
+
+				return append(suggestions, p.suggestPlayers(ctx, partial)...)
+			}).
+			Then(command.Argument("server", command.Word()).
+				Suggests(p.suggestServers).
+				Executes(p.send)))
+}
+
+func (p *Proxy) send(ctx *command.Context) error {
+	target := ctx.String("player")
+	name := ctx.String("server")
+
+	server := p.Server(name)
+	if server == nil {
+		sendError(ctx.Source, fmt.Sprintf("Server %s doesn't exist.", name))
+		return nil
+	}
+
+	var players []*Player
+	switch target {
+	case "all":
+		players = p.Players()
+	case "current":
+		source, ok := ctx.Source.(*Player)
+		if !ok {
+			sendError(ctx.Source, "Only players are on a server.")
+			return nil
+		}
+
+		current := source.CurrentServer()
+		for _, player := range p.Players() {
+			if player.CurrentServer() == current {
+				players = append(players, player)
+			}
+		}
+	default:
+		player := p.PlayerByName(target)
+		if player == nil {
+			sendError(ctx.Source, fmt.Sprintf("Player %s isn't online.", target))
+			return nil
+		}
+		players = []*Player{player}
+	}
+
+	for _, player := range players {
+		if player.CurrentServer() == server {
+			continue
+		}
+
+		// connecting waits for the server, the players are moved side by side
+		go func(player *Player) {
+			if err := player.Connect(server); err != nil {
+				player.logger.Debug().Err(err).Str("server", server.Name()).Msg("Failed to send player")
+				sendError(player, fmt.Sprintf("Can't connect to %s: %s", server.Name(), connectFailure(err)))
+			}
+		}(player)
+	}
+
+	sendMarkup(ctx.Source, fmt.Sprintf("<green>Sending %d %s to %s.", len(players), plural(len(players), "player", "players"), component.EscapeMarkup(server.Name())))
+	return nil
+}
+
+// endCommand shuts the proxy down.
+func (p *Proxy) endCommand() *command.Node {
+	return command.Literal("end").
+		Requires(requirePermission("gopro.command.end")).
+		Executes(func(*command.Context) error {
+			// shutting down waits for the plugins, which may run commands themselves
+			go p.Shutdown()
+			return nil
+		})
+}
+
+// reloadCommand reads the config again.
+func (p *Proxy) reloadCommand() *command.Node {
+	return command.Literal("reload").
+		Requires(requirePermission("gopro.command.reload")).
+		Executes(func(ctx *command.Context) error {
+			if err := p.Reload(); err != nil {
+				sendError(ctx.Source, "Failed to reload the config: "+err.Error())
+				return nil
+			}
+
+			sendMarkup(ctx.Source, "<green>Reloaded the config.")
+			return nil
+		})
+}
+
+// kickCommand disconnects a player from the proxy, with an optional reason.
+func (p *Proxy) kickCommand() *command.Node {
+	kick := func(ctx *command.Context) error {
+		name := ctx.String("player")
+		player := p.PlayerByName(name)
+		if player == nil {
+			sendError(ctx.Source, fmt.Sprintf("Player %s isn't online.", name))
+			return nil
+		}
+
+		reason := component.NewTextComponent("You were kicked from the proxy")
+		if ctx.Has("reason") {
+			reason = component.ParseMarkup(ctx.String("reason"), nil)
+		}
+
+		player.Disconnect(reason)
+		sendMarkup(ctx.Source, "<green>Kicked "+component.EscapeMarkup(player.Username())+".")
+		return nil
+	}
+
+	return command.Literal("kick").
+		Requires(requirePermission("gopro.command.kick")).
+		Then(command.Argument("player", command.Word()).
+			Suggests(p.suggestPlayers).
+			Executes(kick).
+			Then(command.Argument("reason", command.GreedyString()).Executes(kick)))
+}
+
+// pluginsCommand lists the plugins, the ones that failed to load in red.
+func (p *Proxy) pluginsCommand() *command.Node {
+	return command.Literal("plugins").
+		Requires(requirePermission("gopro.command.plugins")).
+		Executes(func(ctx *command.Context) error {
+			plugins := p.Plugins()
+
+			names := make([]string, len(plugins))
+			for i, plugin := range plugins {
+				color := "green"
+				if !plugin.Loaded() {
+					color = "red"
+				}
+
+				name := plugin.ID()
+				if plugin.Plugin.Version != "" {
+					name += " " + plugin.Plugin.Version
+				}
+				names[i] = "<" + color + ">" + component.EscapeMarkup(name) + "</" + color + ">"
+			}
+
+			sendMarkup(ctx.Source, fmt.Sprintf("<yellow>Plugins (%d): </yellow>%s", len(plugins), strings.Join(names, "<gray>, </gray>")))
+			return nil
+		})
+}
+
+func (p *Proxy) suggestPlayers(*command.Context, string) []string {
+	var names []string
+	for _, player := range p.Players() {
+		names = append(names, player.Username())
+	}
+
+	return names
+}
+
+// suggestServers suggests the servers the source may join, all of them for sources without
+// permissions, and the groups.
+func (p *Proxy) suggestServers(ctx *command.Context, _ string) []string {
+	subject, ok := ctx.Source.(PermissionSubject)
+
+	var names []string
+	for _, server := range p.Servers() {
+		if !ok || p.CanAccess(subject, server) {
+			names = append(names, server.Name())
+		}
+	}
+	for _, group := range p.Groups() {
+		names = append(names, group.Name())
+	}
+
+	return names
+}
+
+// connectFailure is the reason a connection failed, as it is shown to the player.
+func connectFailure(err error) string {
+	var disconnected *DisconnectedError
+	if errors.As(err, &disconnected) {
+		return component.PlainText(disconnected.Reason, nil)
+	}
+	if errors.Is(err, ErrNoPermission) {
+		return "you don't have permission to join it"
+	}
+	if errors.Is(err, ErrServerFull) {
+		return "it is full"
+	}
+
+	return err.Error()
+}
+
+func isPlayer(source command.Source) bool {
+	_, ok := source.(*Player)
+	return ok
+}
+
+// hasPermission tells whether the source has the permission, sources without permissions never do.
+func hasPermission(source command.Source, permission string) bool {
+	subject, ok := source.(PermissionSubject)
+	return ok && subject.HasPermission(permission)
+}
+
+func requirePermission(permission string) func(command.Source) bool {
+	return func(source command.Source) bool {
+		return hasPermission(source, permission)
+	}
+}
+
+func plural(n int, one string, many string) string {
+	if n == 1 {
+		return one
+	}
+
+	return many
+}
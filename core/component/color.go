@@ -1,5 +1,11 @@
 package component
 
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
 const (
 	Black       = "black"
 	DarkBlue    = "dark_blue"
@@ -24,3 +30,83 @@ type Color string
 func Hex(hex string) Color {
 	return Color("#" + hex)
 }
+
+// HexProtocol is the first protocol version (1.16) whose clients understand hex colors.
+const HexProtocol = 735
+
+type namedColor struct {
+	color Color
+	code  rune
+	rgb   uint32
+}
+
+var namedColors = []namedColor{
+	{Black, '0', 0x000000},
+	{DarkBlue, '1', 0x0000AA},
+	{DarkGreen, '2', 0x00AA00},
+	{DarkAqua, '3', 0x00AAAA},
+	{DarkRed, '4', 0xAA0000},
+	{DarkPurple, '5', 0xAA00AA},
+	{Gold, '6', 0xFFAA00},
+	{Gray, '7', 0xAAAAAA},
+	{DarkGray, '8', 0x555555},
+	{Blue, '9', 0x5555FF},
+	{Green, 'a', 0x55FF55},
+	{Aqua, 'b', 0x55FFFF},
+	{Red, 'c', 0xFF5555},
+	{LightPurple, 'd', 0xFF55FF},
+	{Yellow, 'e', 0xFFFF55},
+	{White, 'f', 0xFFFFFF},
+}
+
+func (c Color) IsHex() bool {
+	return strings.HasPrefix(string(c), "#")
+}
+
+// RGB returns the red, green and blue of a named or hex color.
+func (c Color) RGB() (uint32, bool) {
+	if c.IsHex() {
+		rgb, err := strconv.ParseUint(string(c[1:]), 16, 32)
+		if err != nil || len(c) != 7 {
+			return 0, false
+		}
+		return uint32(rgb), true
+	}
+
+	for _, named := range namedColors {
+		if named.color == c {
+			return named.rgb, true
+		}
+	}
+
+	return 0, false
+}
+
+// Nearest returns the named color closest to c, or c itself if it isn't a valid color.
+func (c Color) Nearest() Color {
+	rgb, ok := c.RGB()
+	if !ok {
+		return c
+	}
+
+	best := namedColors[0]
+	bestDistance := math.MaxFloat64
+	for _, named := range namedColors {
+		if d := colorDistance(rgb, named.rgb); d < bestDistance {
+			best, bestDistance = named, d
+		}
+	}
+
+	return best.color
+}
+
+// colorDistance is the "redmean" approximation of the perceived distance between two colors.
+func colorDistance(a uint32, b uint32) float64 {
+	r1, g1, b1 := float64(a>>16&0xFF), float64(a>>8&0xFF), float64(a&0xFF)
+	r2, g2, b2 := float64(b>>16&0xFF), float64(b>>8&0xFF), float64(b&0xFF)
+
+	rMean := (r1 + r2) / 2
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+
+	return (2+rMean/256)*dr*dr + 4*dg*dg + (2+(255-rMean)/256)*db*db
+}
@@ -3,42 +3,78 @@ package component
 import "encoding/json"
 
 const (
-	ClickOpenURL        = "open_url"
-	ClickRunCommand     = "run_command"
-	ClickSuggestCommand = "suggest_command"
-	ClickCopyCommand    = "copy_command"
-
-	HoverShowText = "show_text"
+	TypeText         = "text"
+	TypeTranslatable = "translatable"
+	TypeKeybind      = "keybind"
+	TypeScore        = "score"
+	TypeSelector     = "selector"
+	TypeNBT          = "nbt"
 )
 
+// TextComponent is a chat component. Exactly one of the content fields (Text, Translate,
+// Keybind, Score, Selector or NBT) is expected to be set, Text being the default.
+// The style fields are pointers because an explicit false resets a style inherited from the parent.
 type TextComponent struct {
-	Text          string          `json:"text"`
-	Color         Color           `json:"color,omitempty"`
-	Bold          bool            `json:"bold,omitempty"`
-	Italic        bool            `json:"italic,omitempty"`
-	Underlined    bool            `json:"underlined,omitempty"`
-	Strikethrough bool            `json:"strikethrough,omitempty"`
-	Obfuscated    bool            `json:"obfuscated,omitempty"`
-	ClickEvent    *ClickEvent     `json:"clickEvent,omitempty"`
-	HoverEvent    *HoverEvent     `json:"hoverEvent,omitempty"`
-	Extras        []TextComponent `json:"extra,omitempty"`
+	// Type is the optional content type hint sent by 1.20.3+ servers.
+	Type string
+
+	Text string
+
+	Translate string
+	Fallback  string
+	With      []TextComponent
+
+	Keybind string
+
+	Score *Score
+
+	Selector  string
+	Separator *TextComponent
+
+	NBT *NBTContent
+
+	Color         Color
+	Font          string
+	Bold          *bool
+	Italic        *bool
+	Underlined    *bool
+	Strikethrough *bool
+	Obfuscated    *bool
+	ShadowColor   *ShadowColor
+	Insertion     string
+	ClickEvent    *ClickEvent
+	HoverEvent    *HoverEvent
+	Extras        []TextComponent
+
+	// unknown holds the fields this package doesn't know about, so they survive a round trip.
+	unknown map[string]json.RawMessage
+	// rawText is the number or boolean the text was given as, which is written back unless the
+	// text changed
+	rawText json.RawMessage
 }
 
-type ClickEvent struct {
-	Action ClickEventAction `json:"action"`
-	Value  string           `json:"value"`
+func NewTextComponent(text string) *TextComponent {
+	return &TextComponent{Text: text}
 }
 
-type HoverEvent struct {
-	Action HoverEventAction `json:"action"`
-	Value  string           `json:"value"`
+func NewTranslatableComponent(key string, with ...TextComponent) *TextComponent {
+	return &TextComponent{Translate: key, With: with}
 }
 
-type ClickEventAction string
-type HoverEventAction string
+func NewKeybindComponent(keybind string) *TextComponent {
+	return &TextComponent{Keybind: keybind}
+}
 
-func NewTextComponent(text string) *TextComponent {
-	return &TextComponent{Text: text}
+func NewScoreComponent(name string, objective string) *TextComponent {
+	return &TextComponent{Score: &Score{Name: name, Objective: objective}}
+}
+
+func NewSelectorComponent(selector string) *TextComponent {
+	return &TextComponent{Selector: selector}
+}
+
+func NewNBTComponent(content NBTContent) *TextComponent {
+	return &TextComponent{NBT: &content}
 }
 
 func (c *TextComponent) WithColor(color Color) *TextComponent {
@@ -47,27 +83,52 @@ func (c *TextComponent) WithColor(color Color) *TextComponent {
 }
 
 func (c *TextComponent) WithBold(bold bool) *TextComponent {
-	c.Bold = bold
+	c.Bold = &bold
 	return c
 }
 
 func (c *TextComponent) WithItalic(italic bool) *TextComponent {
-	c.Italic = italic
+	c.Italic = &italic
 	return c
 }
 
 func (c *TextComponent) WithUnderlined(underlined bool) *TextComponent {
-	c.Underlined = underlined
+	c.Underlined = &underlined
 	return c
 }
 
 func (c *TextComponent) WithStrikethrough(strikethrough bool) *TextComponent {
-	c.Strikethrough = strikethrough
+	c.Strikethrough = &strikethrough
 	return c
 }
 
 func (c *TextComponent) WithObfuscate(obfuscated bool) *TextComponent {
-	c.Obfuscated = obfuscated
+	c.Obfuscated = &obfuscated
+	return c
+}
+
+func (c *TextComponent) WithFont(font string) *TextComponent {
+	c.Font = font
+	return c
+}
+
+func (c *TextComponent) WithInsertion(insertion string) *TextComponent {
+	c.Insertion = insertion
+	return c
+}
+
+func (c *TextComponent) WithShadowColor(color ShadowColor) *TextComponent {
+	c.ShadowColor = &color
+	return c
+}
+
+func (c *TextComponent) WithFallback(fallback string) *TextComponent {
+	c.Fallback = fallback
+	return c
+}
+
+func (c *TextComponent) WithSeparator(separator *TextComponent) *TextComponent {
+	c.Separator = separator
 	return c
 }
 
@@ -81,20 +142,32 @@ func (c *TextComponent) WithHoverEvent(action HoverEventAction, value string) *T
 	return c
 }
 
-func (c *TextComponent) WithExtras(extras ...TextComponent) *TextComponent {
-	c.Extras = append(c.Extras, extras...)
+func (c *TextComponent) WithHover(hover *HoverEvent) *TextComponent {
+	c.HoverEvent = hover
 	return c
 }
 
-func newClickEvent(action ClickEventAction, value string) *ClickEvent {
-	return &ClickEvent{Action: action, Value: value}
+func (c *TextComponent) WithExtras(extras ...TextComponent) *TextComponent {
+	c.Extras = append(c.Extras, extras...)
+	return c
 }
 
-func newHoverEvent(action HoverEventAction, value string) *HoverEvent {
-	return &HoverEvent{Action: action, Value: value}
+// IsPlain reports whether the component has literal text content.
+func (c *TextComponent) IsPlain() bool {
+	return c.Translate == "" && c.Keybind == "" && c.Score == nil && c.Selector == "" && c.NBT == nil
 }
 
 func (c *TextComponent) Serialize() (string, error) {
 	val, err := json.Marshal(c)
 	return string(val), err
 }
+
+// Deserialize parses a JSON component. Plain strings and arrays are accepted as well as objects.
+func Deserialize(s string) (*TextComponent, error) {
+	var c TextComponent
+	if err := json.Unmarshal([]byte(s), &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,56 @@
+package component
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+)
+
+type Score struct {
+	Name      string `json:"name"`
+	Objective string `json:"objective"`
+	// Value is only sent by pre 1.20.3 servers that already resolved the score.
+	Value string `json:"value,omitempty"`
+}
+
+// NBTContent shows the value at Path in one of the data sources Block, Entity or Storage.
+type NBTContent struct {
+	Path      string
+	Interpret bool
+	Block     string
+	Entity    string
+	Storage   string
+	// Source is the optional "block", "entity" or "storage" hint sent by 1.20.3+ servers.
+	Source string
+}
+
+// ShadowColor is an ARGB color. On the wire it is either an int or an array of four floats.
+type ShadowColor uint32
+
+func (s ShadowColor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int32(s))
+}
+
+func (s *ShadowColor) UnmarshalJSON(data []byte) error {
+	var argb int64
+	if err := json.Unmarshal(data, &argb); err == nil {
+		*s = ShadowColor(uint32(argb))
+		return nil
+	}
+
+	var rgba []float64
+	if err := json.Unmarshal(data, &rgba); err != nil {
+		return err
+	}
+
+	if len(rgba) != 4 {
+		return errors.New("shadow color must have 4 components")
+	}
+
+	channel := func(f float64) uint32 {
+		return uint32(math.Round(math.Max(0, math.Min(1, f)) * 255))
+	}
+
+	*s = ShadowColor(channel(rgba[3])<<24 | channel(rgba[0])<<16 | channel(rgba[1])<<8 | channel(rgba[2]))
+	return nil
+}
@@ -0,0 +1,99 @@
+package component
+
+import "encoding/json"
+
+const (
+	ClickOpenURL         = "open_url"
+	ClickRunCommand      = "run_command"
+	ClickSuggestCommand  = "suggest_command"
+	ClickChangePage      = "change_page"
+	ClickCopyToClipboard = "copy_to_clipboard"
+	// Deprecated: the client knows no such action, use ClickCopyToClipboard.
+	ClickCopyCommand = "copy_command"
+
+	HoverShowText   = "show_text"
+	HoverShowItem   = "show_item"
+	HoverShowEntity = "show_entity"
+)
+
+type ClickEventAction string
+type HoverEventAction string
+
+// ClickEvent keeps its payload in Value whatever the action, the url, command or page
+// fields of the 1.21.5+ click_event shape are mapped onto it.
+type ClickEvent struct {
+	Action ClickEventAction
+	Value  string
+
+	// Modern is set when the event uses the 1.21.5+ click_event shape.
+	Modern bool
+
+	unknown map[string]json.RawMessage
+}
+
+// HoverEvent carries the content of one of the three actions.
+type HoverEvent struct {
+	Action HoverEventAction
+
+	// Text is the tooltip of show_text, or the raw SNBT value of a pre 1.16 show_item or show_entity.
+	Text   *TextComponent
+	Item   *HoverItem
+	Entity *HoverEntity
+
+	Shape HoverShape
+
+	unknown map[string]json.RawMessage
+}
+
+// HoverShape is the JSON layout a hover event is written in.
+type HoverShape byte
+
+const (
+	// HoverValue is the pre 1.16 hoverEvent layout with a value field.
+	HoverValue = HoverShape(iota)
+	// HoverContents is the 1.16+ hoverEvent layout with a contents field.
+	HoverContents
+	// HoverModern is the 1.21.5+ hover_event layout with inlined fields.
+	HoverModern
+)
+
+type HoverItem struct {
+	ID    string
+	Count int
+	// Components are the item's data components (1.20.5+), kept as raw JSON.
+	Components map[string]any
+	// Tag is the item's SNBT tag (pre 1.20.5).
+	Tag string
+
+	unknown map[string]json.RawMessage
+}
+
+type HoverEntity struct {
+	Type string
+	UUID string
+	Name *TextComponent
+
+	// uuidArray is set when the uuid was given as an array of ints, which it is written as again
+	uuidArray bool
+	unknown   map[string]json.RawMessage
+}
+
+func newClickEvent(action ClickEventAction, value string) *ClickEvent {
+	return &ClickEvent{Action: action, Value: value}
+}
+
+func newHoverEvent(action HoverEventAction, value string) *HoverEvent {
+	return &HoverEvent{Action: action, Text: NewTextComponent(value)}
+}
+
+func ShowText(text *TextComponent) *HoverEvent {
+	return &HoverEvent{Action: HoverShowText, Text: text, Shape: HoverContents}
+}
+
+func ShowItem(id string, count int) *HoverEvent {
+	return &HoverEvent{Action: HoverShowItem, Item: &HoverItem{ID: id, Count: count}, Shape: HoverContents}
+}
+
+func ShowEntity(entityType string, uuid string, name *TextComponent) *HoverEvent {
+	return &HoverEvent{Action: HoverShowEntity, Entity: &HoverEntity{Type: entityType, UUID: uuid, Name: name}, Shape: HoverContents}
+}
@@ -0,0 +1,647 @@
+package component
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// object writes a JSON object keeping the order its fields were put in.
+type object struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (o *object) put(key string, value any) {
+	if o.err != nil {
+		return
+	}
+
+	val, err := json.Marshal(value)
+	if err != nil {
+		o.err = err
+		return
+	}
+
+	o.putRaw(key, val)
+}
+
+func (o *object) putRaw(key string, value json.RawMessage) {
+	if o.buf.Len() == 0 {
+		o.buf.WriteByte('{')
+	} else {
+		o.buf.WriteByte(',')
+	}
+
+	k, _ := json.Marshal(key)
+	o.buf.Write(k)
+	o.buf.WriteByte(':')
+	o.buf.Write(value)
+}
+
+func (o *object) bytes() ([]byte, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+
+	if o.buf.Len() == 0 {
+		return []byte("{}"), nil
+	}
+
+	o.buf.WriteByte('}')
+	return o.buf.Bytes(), nil
+}
+
+func (c TextComponent) MarshalJSON() ([]byte, error) {
+	var o object
+
+	if c.Type != "" {
+		o.put("type", c.Type)
+	}
+	if c.rawText != nil && string(c.rawText) == c.Text {
+		o.putRaw("text", c.rawText)
+	} else if c.Text != "" || c.IsPlain() {
+		o.put("text", c.Text)
+	}
+	if c.Translate != "" {
+		o.put("translate", c.Translate)
+		if c.Fallback != "" {
+			o.put("fallback", c.Fallback)
+		}
+		if len(c.With) > 0 {
+			o.put("with", c.With)
+		}
+	}
+	if c.Keybind != "" {
+		o.put("keybind", c.Keybind)
+	}
+	if c.Score != nil {
+		o.put("score", c.Score)
+	}
+	if c.Selector != "" {
+		o.put("selector", c.Selector)
+	}
+	if c.NBT != nil {
+		o.put("nbt", c.NBT.Path)
+		if c.NBT.Interpret {
+			o.put("interpret", true)
+		}
+		if c.NBT.Block != "" {
+			o.put("block", c.NBT.Block)
+		}
+		if c.NBT.Entity != "" {
+			o.put("entity", c.NBT.Entity)
+		}
+		if c.NBT.Storage != "" {
+			o.put("storage", c.NBT.Storage)
+		}
+		if c.NBT.Source != "" {
+			o.put("source", c.NBT.Source)
+		}
+	}
+	if c.Separator != nil {
+		o.put("separator", c.Separator)
+	}
+
+	if c.Color != "" {
+		o.put("color", c.Color)
+	}
+	if c.Font != "" {
+		o.put("font", c.Font)
+	}
+	putBool(&o, "bold", c.Bold)
+	putBool(&o, "italic", c.Italic)
+	putBool(&o, "underlined", c.Underlined)
+	putBool(&o, "strikethrough", c.Strikethrough)
+	putBool(&o, "obfuscated", c.Obfuscated)
+	if c.ShadowColor != nil {
+		o.put("shadow_color", c.ShadowColor)
+	}
+	if c.Insertion != "" {
+		o.put("insertion", c.Insertion)
+	}
+
+	if c.ClickEvent != nil {
+		c.ClickEvent.marshal(&o)
+	}
+	if c.HoverEvent != nil {
+		c.HoverEvent.marshal(&o)
+	}
+
+	if len(c.Extras) > 0 {
+		o.put("extra", c.Extras)
+	}
+
+	putUnknown(&o, c.unknown)
+
+	return o.bytes()
+}
+
+// putUnknown writes the fields that were kept because they aren't known, sorted by key.
+func putUnknown(o *object, unknown map[string]json.RawMessage) {
+	keys := make([]string, 0, len(unknown))
+	for key := range unknown {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		o.putRaw(key, unknown[key])
+	}
+}
+
+// leftover returns the fields that aren't known, nil if there are none.
+func leftover(fields map[string]json.RawMessage, known ...string) map[string]json.RawMessage {
+	var unknown map[string]json.RawMessage
+	for key, raw := range fields {
+		if slices.Contains(known, key) {
+			continue
+		}
+		if unknown == nil {
+			unknown = make(map[string]json.RawMessage)
+		}
+		unknown[key] = raw
+	}
+
+	return unknown
+}
+
+func putBool(o *object, key string, value *bool) {
+	if value != nil {
+		o.put(key, *value)
+	}
+}
+
+func (c *TextComponent) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return errors.New("empty component")
+	}
+	// like for pointers, null leaves the component as it is
+	if string(data) == "null" {
+		return nil
+	}
+
+	switch data[0] {
+	case '"':
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		*c = TextComponent{Text: text}
+		return nil
+	case '[':
+		var list []TextComponent
+		if err := json.Unmarshal(data, &list); err != nil {
+			return err
+		}
+		*c = TextComponent{}
+		if len(list) > 0 {
+			*c = list[0]
+			c.Extras = append(c.Extras, list[1:]...)
+		}
+		return nil
+	case '{':
+	default:
+		// numbers and booleans are shown as they are, and written back as they were
+		raw := append(json.RawMessage(nil), data...)
+		*c = TextComponent{Text: string(raw), rawText: raw}
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*c = TextComponent{}
+	nbt := NBTContent{}
+	isNBT := false
+
+	for key, raw := range fields {
+		var err error
+		switch key {
+		case "type":
+			err = json.Unmarshal(raw, &c.Type)
+		case "text":
+			err = unmarshalText(raw, &c.Text)
+			if err == nil && raw[0] != '"' {
+				c.rawText = raw
+			}
+		case "translate":
+			err = json.Unmarshal(raw, &c.Translate)
+		case "fallback":
+			err = json.Unmarshal(raw, &c.Fallback)
+		case "with":
+			err = json.Unmarshal(raw, &c.With)
+		case "keybind":
+			err = json.Unmarshal(raw, &c.Keybind)
+		case "score":
+			err = json.Unmarshal(raw, &c.Score)
+		case "selector":
+			err = json.Unmarshal(raw, &c.Selector)
+		case "separator":
+			err = json.Unmarshal(raw, &c.Separator)
+		case "nbt":
+			isNBT = true
+			err = json.Unmarshal(raw, &nbt.Path)
+		case "interpret":
+			err = json.Unmarshal(raw, &nbt.Interpret)
+		case "block":
+			err = json.Unmarshal(raw, &nbt.Block)
+		case "entity":
+			err = json.Unmarshal(raw, &nbt.Entity)
+		case "storage":
+			err = json.Unmarshal(raw, &nbt.Storage)
+		case "source":
+			err = json.Unmarshal(raw, &nbt.Source)
+		case "color":
+			err = json.Unmarshal(raw, &c.Color)
+		case "font":
+			err = json.Unmarshal(raw, &c.Font)
+		case "bold":
+			err = json.Unmarshal(raw, &c.Bold)
+		case "italic":
+			err = json.Unmarshal(raw, &c.Italic)
+		case "underlined":
+			err = json.Unmarshal(raw, &c.Underlined)
+		case "strikethrough":
+			err = json.Unmarshal(raw, &c.Strikethrough)
+		case "obfuscated":
+			err = json.Unmarshal(raw, &c.Obfuscated)
+		case "shadow_color":
+			err = json.Unmarshal(raw, &c.ShadowColor)
+		case "insertion":
+			err = json.Unmarshal(raw, &c.Insertion)
+		case "clickEvent", "click_event":
+			c.ClickEvent = &ClickEvent{Modern: key == "click_event"}
+			err = c.ClickEvent.unmarshal(raw)
+		case "hoverEvent", "hover_event":
+			c.HoverEvent = &HoverEvent{}
+			err = c.HoverEvent.unmarshal(raw, key == "hover_event")
+		case "extra":
+			err = json.Unmarshal(raw, &c.Extras)
+		default:
+			if c.unknown == nil {
+				c.unknown = make(map[string]json.RawMessage)
+			}
+			c.unknown[key] = raw
+		}
+
+		if err != nil {
+			return fmt.Errorf("component field %s: %w", key, err)
+		}
+	}
+
+	if isNBT {
+		c.NBT = &nbt
+	} else if nbt.Interpret || nbt.Block != "" || nbt.Entity != "" || nbt.Storage != "" || nbt.Source != "" {
+		// not an nbt component, give the fields back
+		for _, key := range []string{"interpret", "block", "entity", "storage", "source"} {
+			if raw, ok := fields[key]; ok {
+				if c.unknown == nil {
+					c.unknown = make(map[string]json.RawMessage)
+				}
+				c.unknown[key] = raw
+			}
+		}
+	}
+
+	return nil
+}
+
+// unmarshalText accepts the numbers and booleans some servers send as text, keeping them as
+// they are written.
+func unmarshalText(raw json.RawMessage, text *string) error {
+	if err := json.Unmarshal(raw, text); err == nil {
+		return nil
+	}
+
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return err
+	}
+
+	switch value.(type) {
+	case float64, bool:
+		*text = string(bytes.TrimSpace(raw))
+		return nil
+	}
+
+	return fmt.Errorf("text can't be %s", bytes.TrimSpace(raw))
+}
+
+// clickField is the field the 1.21.5+ click_event shape keeps the payload of an action in.
+func clickField(action ClickEventAction) string {
+	switch action {
+	case ClickOpenURL:
+		return "url"
+	case ClickRunCommand, ClickSuggestCommand:
+		return "command"
+	case ClickChangePage:
+		return "page"
+	default:
+		return "value"
+	}
+}
+
+func (e *ClickEvent) marshal(o *object) {
+	var event object
+	event.put("action", e.Action)
+
+	if !e.Modern {
+		event.put("value", e.Value)
+		putUnknown(&event, e.unknown)
+		raw, err := event.bytes()
+		if err != nil {
+			o.err = err
+			return
+		}
+		o.putRaw("clickEvent", raw)
+		return
+	}
+
+	field := clickField(e.Action)
+	if page, err := strconv.Atoi(e.Value); field == "page" && err == nil {
+		event.put(field, page)
+	} else {
+		event.put(field, e.Value)
+	}
+	putUnknown(&event, e.unknown)
+
+	raw, err := event.bytes()
+	if err != nil {
+		o.err = err
+		return
+	}
+	o.putRaw("click_event", raw)
+}
+
+func (e *ClickEvent) unmarshal(data json.RawMessage) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(fields["action"], &e.Action); err != nil {
+		return err
+	}
+
+	field := "value"
+	if e.Modern {
+		field = clickField(e.Action)
+	}
+	e.unknown = leftover(fields, "action", field)
+
+	raw, ok := fields[field]
+	if !ok {
+		return nil
+	}
+
+	return unmarshalText(raw, &e.Value)
+}
+
+func (e *HoverEvent) marshal(o *object) {
+	var event object
+	event.put("action", e.Action)
+
+	key := "hoverEvent"
+	switch {
+	case e.Shape == HoverModern:
+		key = "hover_event"
+		switch {
+		case e.Item != nil:
+			e.Item.put(&event, "id")
+		case e.Entity != nil:
+			e.Entity.put(&event, "id", "uuid")
+		default:
+			event.put("value", e.text())
+		}
+	case e.Shape == HoverValue && (e.Text != nil || (e.Item == nil && e.Entity == nil)):
+		event.put("value", e.text())
+	default:
+		switch {
+		case e.Item != nil:
+			var item object
+			e.Item.put(&item, "id")
+			raw, err := item.bytes()
+			if err != nil {
+				o.err = err
+				return
+			}
+			event.putRaw("contents", raw)
+		case e.Entity != nil:
+			var entity object
+			e.Entity.put(&entity, "type", "id")
+			raw, err := entity.bytes()
+			if err != nil {
+				o.err = err
+				return
+			}
+			event.putRaw("contents", raw)
+		default:
+			event.put("contents", e.text())
+		}
+	}
+	putUnknown(&event, e.unknown)
+
+	raw, err := event.bytes()
+	if err != nil {
+		o.err = err
+		return
+	}
+	o.putRaw(key, raw)
+}
+
+func (e *HoverEvent) text() *TextComponent {
+	if e.Text == nil {
+		return NewTextComponent("")
+	}
+
+	return e.Text
+}
+
+func (i *HoverItem) put(o *object, idKey string) {
+	o.put(idKey, i.ID)
+	if i.Count != 0 {
+		o.put("count", i.Count)
+	}
+	if len(i.Components) > 0 {
+		o.put("components", i.Components)
+	}
+	if i.Tag != "" {
+		o.put("tag", i.Tag)
+	}
+	putUnknown(o, i.unknown)
+}
+
+func (e *HoverEntity) put(o *object, typeKey string, uuidKey string) {
+	o.put(typeKey, e.Type)
+	if ints, ok := uuidInts(e.UUID); ok && e.uuidArray {
+		o.put(uuidKey, ints)
+	} else {
+		o.put(uuidKey, e.UUID)
+	}
+	if e.Name != nil {
+		o.put("name", e.Name)
+	}
+	putUnknown(o, e.unknown)
+}
+
+func (e *HoverEvent) unmarshal(data json.RawMessage, modern bool) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(fields["action"], &e.Action); err != nil {
+		return err
+	}
+
+	if modern {
+		e.Shape = HoverModern
+		known, err := e.unmarshalContents(fields, "id", "uuid")
+		e.unknown = leftover(fields, append(known, "action")...)
+		return err
+	}
+
+	if contents, ok := fields["contents"]; ok {
+		e.Shape = HoverContents
+		e.unknown = leftover(fields, "action", "contents")
+
+		switch e.Action {
+		case HoverShowItem, HoverShowEntity:
+			var id string
+			if e.Action == HoverShowItem && json.Unmarshal(contents, &id) == nil {
+				// an item given by its id only
+				e.Item = &HoverItem{ID: id, Count: 1}
+				return nil
+			}
+
+			var inner map[string]json.RawMessage
+			if err := json.Unmarshal(contents, &inner); err != nil {
+				return err
+			}
+
+			typeKey, uuidKey := "type", "id"
+			if e.Action == HoverShowItem {
+				typeKey, uuidKey = "id", ""
+			}
+			known, err := e.unmarshalContents(inner, typeKey, uuidKey)
+			if err != nil {
+				return err
+			}
+			if e.Item != nil {
+				e.Item.unknown = leftover(inner, known...)
+			} else {
+				e.Entity.unknown = leftover(inner, known...)
+			}
+			return nil
+		default:
+			return json.Unmarshal(contents, &e.Text)
+		}
+	}
+
+	e.Shape = HoverValue
+	e.unknown = leftover(fields, "action", "value")
+	if value, ok := fields["value"]; ok {
+		return json.Unmarshal(value, &e.Text)
+	}
+
+	return nil
+}
+
+// unmarshalContents reads the item or entity of the event from fields, the entity type and
+// uuid being in typeKey and uuidKey. It returns the keys of the fields it knows.
+func (e *HoverEvent) unmarshalContents(fields map[string]json.RawMessage, typeKey string, uuidKey string) ([]string, error) {
+	switch e.Action {
+	case HoverShowItem:
+		e.Item = &HoverItem{Count: 1}
+		if err := json.Unmarshal(fields["id"], &e.Item.ID); err != nil {
+			return nil, err
+		}
+		if raw, ok := fields["count"]; ok {
+			if err := json.Unmarshal(raw, &e.Item.Count); err != nil {
+				return nil, err
+			}
+		}
+		if raw, ok := fields["components"]; ok {
+			if err := json.Unmarshal(raw, &e.Item.Components); err != nil {
+				return nil, err
+			}
+		}
+		if raw, ok := fields["tag"]; ok {
+			if err := json.Unmarshal(raw, &e.Item.Tag); err != nil {
+				return nil, err
+			}
+		}
+		return []string{"id", "count", "components", "tag"}, nil
+	case HoverShowEntity:
+		e.Entity = &HoverEntity{}
+		if err := json.Unmarshal(fields[typeKey], &e.Entity.Type); err != nil {
+			return nil, err
+		}
+		uuid, err := uuidString(fields[uuidKey])
+		if err != nil {
+			return nil, err
+		}
+		e.Entity.UUID = uuid
+		e.Entity.uuidArray = bytes.HasPrefix(bytes.TrimSpace(fields[uuidKey]), []byte("["))
+		if raw, ok := fields["name"]; ok {
+			if err := json.Unmarshal(raw, &e.Entity.Name); err != nil {
+				return nil, err
+			}
+		}
+		return []string{typeKey, uuidKey, "name"}, nil
+	default:
+		if raw, ok := fields["value"]; ok {
+			return []string{"value"}, json.Unmarshal(raw, &e.Text)
+		}
+		return []string{"value"}, nil
+	}
+}
+
+// uuidString accepts a uuid both as a string and as the array of four ints used by newer versions.
+func uuidString(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+
+	var ints []int32
+	if err := json.Unmarshal(raw, &ints); err != nil {
+		return "", err
+	}
+
+	if len(ints) != 4 {
+		return "", errors.New("uuid int array must have 4 elements")
+	}
+
+	b := make([]byte, 0, 16)
+	for _, i := range ints {
+		b = append(b, byte(i>>24), byte(i>>16), byte(i>>8), byte(i))
+	}
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// uuidInts is the array of four ints of the uuid, as uuidString reads it.
+func uuidInts(uuid string) ([]int32, bool) {
+	b, err := hex.DecodeString(strings.ReplaceAll(uuid, "-", ""))
+	if err != nil || len(b) != 16 {
+		return nil, false
+	}
+
+	ints := make([]int32, 4)
+	for i := range ints {
+		ints[i] = int32(binary.BigEndian.Uint32(b[4*i:]))
+	}
+
+	return ints, true
+}
@@ -0,0 +1,124 @@
+package component
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// sameJSON tells whether both documents hold the same values, whatever the order of their keys.
+func sameJSON(t *testing.T, a string, b string) bool {
+	t.Helper()
+
+	var va, vb any
+	if err := json.Unmarshal([]byte(a), &va); err != nil {
+		t.Fatalf("invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal([]byte(b), &vb); err != nil {
+		t.Fatalf("invalid JSON %s: %v", b, err)
+	}
+
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, input := range []string{
+		`{"text":"Hello","color":"#ff8800","bold":true,"italic":false,"extra":[{"text":" world","underlined":true}]}`,
+		`{"translate":"chat.type.text","fallback":"<%s> %s","with":[{"text":"Steve"},{"text":"hi"}]}`,
+		`{"keybind":"key.jump","font":"minecraft:uniform","insertion":"jump"}`,
+		`{"score":{"name":"@p","objective":"kills"}}`,
+		`{"selector":"@a","separator":{"text":", "}}`,
+		`{"nbt":"Items[0]","interpret":true,"block":"1 2 3"}`,
+		`{"type":"text","text":"typed","shadow_color":-16777216}`,
+		`{"text":"unknown","future_field":{"nested":[1,2]},"another":"kept"}`,
+		`{"text":"link","clickEvent":{"action":"open_url","value":"https://example.com","future":1}}`,
+		`{"text":"page","click_event":{"action":"change_page","page":3,"future":true}}`,
+		`{"text":"command","click_event":{"action":"run_command","command":"/help"}}`,
+		`{"text":"tooltip","hoverEvent":{"action":"show_text","value":{"text":"old"},"future":"kept"}}`,
+		`{"text":"tooltip","hoverEvent":{"action":"show_text","contents":{"text":"new"}}}`,
+		`{"text":"tooltip","hover_event":{"action":"show_text","value":{"text":"modern"}}}`,
+		`{"text":"item","hoverEvent":{"action":"show_item","contents":{"id":"minecraft:stone","count":2,"future":1}}}`,
+		`{"text":"item","hover_event":{"action":"show_item","id":"minecraft:stone","count":3,"components":{"minecraft:custom_name":"x"},"future":1}}`,
+		`{"text":"entity","hoverEvent":{"action":"show_entity","contents":{"type":"minecraft:pig","id":[1,2,3,-4],"name":{"text":"Pig"},"future":1}}}`,
+		`{"text":"entity","hoverEvent":{"action":"show_entity","contents":{"type":"minecraft:pig","id":"00000001-0002-0003-0004-000000000005"}}}`,
+		`{"text":"entity","hover_event":{"action":"show_entity","id":"minecraft:cow","uuid":[1,2,3,4],"future":true}}`,
+		`{"text":1000000}`,
+		`{"text":12.50}`,
+		`{"text":true}`,
+	} {
+		c, err := Deserialize(input)
+		if err != nil {
+			t.Errorf("Deserialize(%s): %v", input, err)
+			continue
+		}
+
+		output, err := c.Serialize()
+		if err != nil {
+			t.Errorf("Serialize of %s: %v", input, err)
+			continue
+		}
+
+		if !sameJSON(t, input, output) {
+			t.Errorf("%s was written back as %s", input, output)
+		}
+	}
+}
+
+func TestJSONPlainValues(t *testing.T) {
+	for input, want := range map[string]string{
+		`"plain"`:   "plain",
+		`1000000`:   "1000000",
+		`1e+06`:     "1e+06",
+		`false`:     "false",
+		`["a","b"]`: "a",
+	} {
+		c, err := Deserialize(input)
+		if err != nil {
+			t.Errorf("Deserialize(%s): %v", input, err)
+			continue
+		}
+		if c.Text != want {
+			t.Errorf("Deserialize(%s) has text %q, want %q", input, c.Text, want)
+		}
+	}
+}
+
+func TestJSONNumericTextIsWrittenAsGiven(t *testing.T) {
+	c, err := Deserialize(`1000000`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, _ := c.Serialize()
+	if output != `{"text":1000000}` {
+		t.Errorf("numeric text was written as %s", output)
+	}
+
+	c.Text = "changed"
+	output, _ = c.Serialize()
+	if output != `{"text":"changed"}` {
+		t.Errorf("changed text was written as %s", output)
+	}
+}
+
+func TestJSONNullIsNoText(t *testing.T) {
+	c, err := Deserialize(`{"translate":"key","with":[null,{"text":"b"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.With) != 2 || c.With[0].Text != "" || c.With[1].Text != "b" {
+		t.Errorf("with is %+v, want an empty component and b", c.With)
+	}
+}
+
+func TestJSONEntityUUIDArray(t *testing.T) {
+	c, err := Deserialize(`{"text":"","hoverEvent":{"action":"show_entity","contents":{"type":"minecraft:pig","id":[0,1,2,3]}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.HoverEvent.Entity.UUID; got != "00000000-0000-0001-0000-000200000003" {
+		t.Errorf("uuid is %s", got)
+	}
+}
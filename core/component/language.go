@@ -0,0 +1,96 @@
+package component
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Language resolves translation keys for rendering translatable components.
+type Language interface {
+	Translate(key string) (string, bool)
+}
+
+// LanguageTable is a Language backed by a map from keys to Java format strings, as found in the
+// client's lang/*.json files.
+type LanguageTable map[string]string
+
+func (t LanguageTable) Translate(key string) (string, bool) {
+	format, ok := t[key]
+	return format, ok
+}
+
+// LoadLanguage reads a LanguageTable from the JSON of a client language file.
+func LoadLanguage(data []byte) (LanguageTable, error) {
+	table := LanguageTable{}
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+//go:embed lang/en_us.json
+var englishUS []byte
+
+// EnglishUS holds the en_us translations of the keys a proxy is likely to come across:
+// disconnect reasons, chat formats, join messages and key names.
+var EnglishUS = mustLoadLanguage(englishUS)
+
+func mustLoadLanguage(data []byte) LanguageTable {
+	table, err := LoadLanguage(data)
+	if err != nil {
+		panic("invalid bundled language: " + err.Error())
+	}
+
+	return table
+}
+
+// formatPart is either literal text or the index of a format argument.
+type formatPart struct {
+	text string
+	arg  int
+}
+
+// parseFormat splits a Java format string using %s, %n$s and %% into parts.
+func parseFormat(format string) []formatPart {
+	var parts []formatPart
+	var text strings.Builder
+	next := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			text.WriteByte(format[i])
+			continue
+		}
+
+		if format[i+1] == '%' {
+			text.WriteByte('%')
+			i++
+			continue
+		}
+
+		if format[i+1] == 's' {
+			parts = append(parts, formatPart{text: text.String(), arg: -1}, formatPart{arg: next})
+			text.Reset()
+			next++
+			i++
+			continue
+		}
+
+		// %n$s
+		if end := strings.Index(format[i:], "$s"); end > 1 {
+			if n, err := strconv.Atoi(format[i+1 : i+end]); err == nil && n > 0 {
+				parts = append(parts, formatPart{text: text.String(), arg: -1}, formatPart{arg: n - 1})
+				text.Reset()
+				i += end + 1
+				continue
+			}
+		}
+
+		text.WriteByte(format[i])
+	}
+
+	return append(parts, formatPart{text: text.String(), arg: -1})
+}
@@ -0,0 +1,259 @@
+package component
+
+import (
+	"strings"
+)
+
+const (
+	SectionChar   = '§'
+	AmpersandChar = '&'
+)
+
+// ParseLegacy converts text using legacy formatting codes prefixed with char into a component.
+// Besides the 16 colors and the k-o formats it understands the &#RRGGBB and §x§R§R§G§G§B§B hex forms.
+// As in the client, a color code resets the formats and r resets everything.
+func ParseLegacy(text string, char rune) *TextComponent {
+	root := NewTextComponent("")
+
+	runes := []rune(text)
+	current := style{}
+	var segment strings.Builder
+
+	flush := func() {
+		if segment.Len() == 0 {
+			return
+		}
+		root.Extras = append(root.Extras, *current.component(segment.String()))
+		segment.Reset()
+	}
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != char || i+1 >= len(runes) {
+			segment.WriteRune(runes[i])
+			continue
+		}
+
+		code := toLower(runes[i+1])
+
+		if hex, length := parseLegacyHex(runes[i:], char); length > 0 {
+			flush()
+			current = style{color: Hex(hex)}
+			i += length - 1
+			continue
+		}
+
+		next, ok := current.apply(code)
+		if !ok {
+			segment.WriteRune(runes[i])
+			continue
+		}
+
+		flush()
+		current = next
+		i++
+	}
+	flush()
+
+	if len(root.Extras) == 1 {
+		return &root.Extras[0]
+	}
+
+	return root
+}
+
+// parseLegacyHex returns the hex digits and the length of a &#RRGGBB or §x§R§R§G§G§B§B color at the start of runes.
+func parseLegacyHex(runes []rune, char rune) (string, int) {
+	if len(runes) >= 8 && runes[1] == '#' && isHex(runes[2:8]) {
+		return string(runes[2:8]), 8
+	}
+
+	if len(runes) >= 14 && toLower(runes[1]) == 'x' {
+		digits := make([]rune, 0, 6)
+		for i := 2; i < 14; i += 2 {
+			if runes[i] != char {
+				return "", 0
+			}
+			digits = append(digits, runes[i+1])
+		}
+		if isHex(digits) {
+			return string(digits), 14
+		}
+	}
+
+	return "", 0
+}
+
+func isHex(runes []rune) bool {
+	for _, r := range runes {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func toLower(r rune) rune {
+	if r >= 'A' && r <= 'Z' {
+		return r + 'a' - 'A'
+	}
+	return r
+}
+
+// apply returns the style after the legacy code, or false if code isn't one.
+func (s style) apply(code rune) (style, bool) {
+	for _, named := range namedColors {
+		if named.code == code {
+			return style{color: named.color}, true
+		}
+	}
+
+	switch code {
+	case 'k':
+		s.obfuscated = true
+	case 'l':
+		s.bold = true
+	case 'm':
+		s.strikethrough = true
+	case 'n':
+		s.underlined = true
+	case 'o':
+		s.italic = true
+	case 'r':
+		return style{}, true
+	default:
+		return s, false
+	}
+
+	return s, true
+}
+
+// component makes a component with text that explicitly has every format of the style that is on.
+func (s style) component(text string) *TextComponent {
+	c := NewTextComponent(text).WithColor(s.color)
+	if s.bold {
+		c.WithBold(true)
+	}
+	if s.italic {
+		c.WithItalic(true)
+	}
+	if s.underlined {
+		c.WithUnderlined(true)
+	}
+	if s.strikethrough {
+		c.WithStrikethrough(true)
+	}
+	if s.obfuscated {
+		c.WithObfuscate(true)
+	}
+
+	return c
+}
+
+// SerializeLegacy flattens the component into text with legacy formatting codes prefixed with char.
+// Hex colors are written as §x§R§R§G§G§B§B, or &#RRGGBB when char is '&'.
+// Translatable components are written as their fallback or key, see PlainText for resolving them.
+func SerializeLegacy(c *TextComponent, char rune) string {
+	var out strings.Builder
+	last := style{}
+
+	c.walk(style{}, func(c *TextComponent, s style) {
+		text := c.contentText()
+		if text == "" {
+			return
+		}
+
+		if s != last {
+			writeLegacyStyle(&out, last, s, char)
+			last = s
+		}
+		out.WriteString(text)
+	})
+
+	return out.String()
+}
+
+func writeLegacyStyle(out *strings.Builder, last style, s style, char rune) {
+	// formats can only be turned off by a color or reset code, which turn off all of them
+	reset := s.color != last.color ||
+		(last.bold && !s.bold) || (last.italic && !s.italic) || (last.underlined && !s.underlined) ||
+		(last.strikethrough && !s.strikethrough) || (last.obfuscated && !s.obfuscated)
+
+	if reset {
+		writeLegacyColor(out, s.color, char)
+		last = style{color: s.color}
+	}
+
+	formats := []struct {
+		on, was bool
+		code    rune
+	}{
+		{s.obfuscated, last.obfuscated, 'k'},
+		{s.bold, last.bold, 'l'},
+		{s.strikethrough, last.strikethrough, 'm'},
+		{s.underlined, last.underlined, 'n'},
+		{s.italic, last.italic, 'o'},
+	}
+
+	for _, format := range formats {
+		if format.on && !format.was {
+			out.WriteRune(char)
+			out.WriteRune(format.code)
+		}
+	}
+}
+
+func writeLegacyColor(out *strings.Builder, color Color, char rune) {
+	if color == "" {
+		out.WriteRune(char)
+		out.WriteRune('r')
+		return
+	}
+
+	if color.IsHex() {
+		if _, ok := color.RGB(); !ok {
+			return
+		}
+		hex := strings.ToLower(string(color[1:]))
+		if char == AmpersandChar {
+			out.WriteRune(char)
+			out.WriteString("#" + hex)
+			return
+		}
+		out.WriteRune(char)
+		out.WriteRune('x')
+		for _, r := range hex {
+			out.WriteRune(char)
+			out.WriteRune(r)
+		}
+		return
+	}
+
+	for _, named := range namedColors {
+		if named.color == color {
+			out.WriteRune(char)
+			out.WriteRune(named.code)
+			return
+		}
+	}
+}
+
+// contentText is the literal text a component shows without resolving anything.
+func (c *TextComponent) contentText() string {
+	switch {
+	case c.Translate != "":
+		if c.Fallback != "" {
+			return c.Fallback
+		}
+		return c.Translate
+	case c.Keybind != "":
+		return c.Keybind
+	case c.Score != nil:
+		return c.Score.Value
+	case c.Selector != "":
+		return c.Selector
+	case c.NBT != nil:
+		return ""
+	}
+
+	return c.Text
+}
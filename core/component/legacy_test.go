@@ -0,0 +1,37 @@
+package component
+
+import (
+	"testing"
+)
+
+func TestLegacyRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		text string
+		char rune
+	}{
+		{"plain", SectionChar},
+		{"§cHello §lworld", SectionChar},
+		{"§6§lbold §7gray §rplain", SectionChar},
+		{"§x§f§f§0§0§0§0hex §9blue", SectionChar},
+		{"&#ff0000hex &aand &ngreen", AmpersandChar},
+		{"&k&mobfuscated", AmpersandChar},
+	} {
+		if got := SerializeLegacy(ParseLegacy(test.text, test.char), test.char); got != test.text {
+			t.Errorf("%q was written back as %q", test.text, got)
+		}
+	}
+}
+
+func TestParseLegacy(t *testing.T) {
+	c := ParseLegacy("§cred §lbold §ano longer bold §zunknown", SectionChar)
+
+	if len(c.Extras) != 3 {
+		t.Fatalf("parsed into %d components, want 3", len(c.Extras))
+	}
+	if c.Extras[0].Color != Red || c.Extras[1].Bold == nil || !*c.Extras[1].Bold {
+		t.Errorf("first components are %+v and %+v", c.Extras[0], c.Extras[1])
+	}
+	if c.Extras[2].Color != Green || c.Extras[2].Bold != nil || c.Extras[2].Text != "no longer bold §zunknown" {
+		t.Errorf("a color code doesn't reset the formats: %+v", c.Extras[2])
+	}
+}
@@ -0,0 +1,571 @@
+package component
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The markup is a subset of Adventure's MiniMessage. Supported tags are
+//
+//	<gold>, <color:gold>, <c:#ff0000>, <#ff0000>        colors, named or hex (#rgb works too)
+//	<bold>, <b>, <italic>, <i>, <em>, <underlined>, <u>,
+//	<strikethrough>, <st>, <obfuscated>, <obf>        decorations, <!bold> turns one off
+//	<gradient:#f00:#00f[:...]>, <rainbow>             colors every character of the enclosed text
+//	<hover:show_text:'text'>                          the text is markup itself
+//	<click:action:value>                              open_url, run_command, suggest_command, copy_to_clipboard, change_page
+//	<insert:text>, <font:name>                        insertion and font
+//	<key:key.jump>, <lang:key[:arg...]>, <newline>    keybind, translatable and line break
+//	<reset>                                           closes every open tag
+//	<name>                                            a named placeholder
+//
+// Tags are closed with </name> (which also closes any tag opened after it) or stay open until
+// the end. Arguments containing ':' or '>' are quoted with ' or ". Unknown tags are kept as text.
+// \< writes a literal < and \\ a literal backslash.
+
+// markupNode is a component under construction whose children still may be added to.
+type markupNode struct {
+	tag       string
+	component *TextComponent
+	children  []*markupNode
+	// colorize colors the i-th of n characters of a gradient or rainbow.
+	colorize func(i int, n int) Color
+	// colored is set once colorize colored the texts below the node, which outer gradients skip
+	colored bool
+	// length is the number of characters colorize colored
+	length int
+}
+
+type markupParser struct {
+	placeholders map[string]*TextComponent
+	stack        []*markupNode
+}
+
+// ParseMarkup parses markup into a component, <name> tags being replaced by placeholders[name].
+func ParseMarkup(markup string, placeholders map[string]*TextComponent) *TextComponent {
+	root := &markupNode{component: NewTextComponent("")}
+	p := &markupParser{placeholders: placeholders, stack: []*markupNode{root}}
+
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			p.append(&markupNode{component: NewTextComponent(text.String())})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(markup); i++ {
+		ch := markup[i]
+
+		if ch == '\\' && i+1 < len(markup) && (markup[i+1] == '<' || markup[i+1] == '\\') {
+			text.WriteByte(markup[i+1])
+			i++
+			continue
+		}
+
+		if ch != '<' {
+			text.WriteByte(ch)
+			continue
+		}
+
+		end := tagEnd(markup, i+1)
+		if end < 0 {
+			text.WriteByte(ch)
+			continue
+		}
+
+		content := markup[i+1 : end]
+		if !p.isTag(content) {
+			text.WriteByte(ch)
+			continue
+		}
+
+		flush()
+		p.handle(content)
+		i = end
+	}
+	flush()
+
+	for len(p.stack) > 1 {
+		p.pop()
+	}
+
+	built := root.build()
+	if built.Text == "" && len(built.Extras) == 1 {
+		return &built.Extras[0]
+	}
+
+	return built
+}
+
+// tagEnd returns the index of the > closing the tag starting at from, skipping quoted arguments.
+func tagEnd(markup string, from int) int {
+	var quote byte
+	for i := from; i < len(markup); i++ {
+		switch ch := markup[i]; {
+		case quote != 0 && ch == '\\':
+			i++
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote == 0 && (ch == '\'' || ch == '"'):
+			quote = ch
+		case quote == 0 && ch == '<':
+			return -1
+		case quote == 0 && ch == '>':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// splitArgs splits the tag content on ':', unquoting quoted arguments.
+func splitArgs(content string) []string {
+	var args []string
+	var current strings.Builder
+	var quote byte
+
+	for i := 0; i < len(content); i++ {
+		ch := content[i]
+		switch {
+		case quote != 0 && ch == '\\' && i+1 < len(content):
+			i++
+			current.WriteByte(content[i])
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote == 0 && (ch == '\'' || ch == '"') && current.Len() == 0:
+			quote = ch
+		case quote == 0 && ch == ':':
+			args = append(args, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(ch)
+		}
+	}
+
+	return append(args, current.String())
+}
+
+func (p *markupParser) top() *markupNode {
+	return p.stack[len(p.stack)-1]
+}
+
+func (p *markupParser) append(n *markupNode) {
+	top := p.top()
+	top.children = append(top.children, n)
+}
+
+func (p *markupParser) push(n *markupNode) {
+	p.append(n)
+	p.stack = append(p.stack, n)
+}
+
+func (p *markupParser) pop() {
+	n := p.top()
+	p.stack = p.stack[:len(p.stack)-1]
+
+	if n.colorize != nil {
+		n.applyColors()
+	}
+}
+
+// isTag reports whether the tag content is something handle understands, everything else is text.
+func (p *markupParser) isTag(content string) bool {
+	if strings.HasPrefix(content, "/") {
+		name := tagName(splitArgs(content[1:])[0])
+		for _, n := range p.stack[1:] {
+			if n.tag == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	args := splitArgs(strings.TrimSuffix(content, "/"))
+	name := strings.ToLower(strings.TrimPrefix(args[0], "!"))
+
+	if _, ok := p.placeholders[args[0]]; ok {
+		return true
+	}
+	if _, ok := parseColor(name); ok {
+		return true
+	}
+	if _, ok := decorations[name]; ok {
+		return true
+	}
+
+	switch name {
+	case "color", "colour", "c":
+		_, ok := parseColor(argument(args, 1))
+		return ok
+	case "gradient", "rainbow", "reset", "newline", "br", "key", "lang", "tr", "translate", "insert", "insertion", "font":
+		return true
+	case "hover":
+		return argument(args, 1) == HoverShowText
+	case "click":
+		return len(args) >= 3
+	}
+
+	return false
+}
+
+func argument(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+var decorations = map[string]func(c *TextComponent, on bool){
+	"bold":          func(c *TextComponent, on bool) { c.WithBold(on) },
+	"b":             func(c *TextComponent, on bool) { c.WithBold(on) },
+	"italic":        func(c *TextComponent, on bool) { c.WithItalic(on) },
+	"i":             func(c *TextComponent, on bool) { c.WithItalic(on) },
+	"em":            func(c *TextComponent, on bool) { c.WithItalic(on) },
+	"underlined":    func(c *TextComponent, on bool) { c.WithUnderlined(on) },
+	"u":             func(c *TextComponent, on bool) { c.WithUnderlined(on) },
+	"strikethrough": func(c *TextComponent, on bool) { c.WithStrikethrough(on) },
+	"st":            func(c *TextComponent, on bool) { c.WithStrikethrough(on) },
+	"obfuscated":    func(c *TextComponent, on bool) { c.WithObfuscate(on) },
+	"obf":           func(c *TextComponent, on bool) { c.WithObfuscate(on) },
+}
+
+// tagName is the name a tag is closed by, aliases share one.
+func tagName(name string) string {
+	name = strings.ToLower(strings.TrimPrefix(name, "!"))
+
+	switch name {
+	case "b":
+		return "bold"
+	case "i", "em":
+		return "italic"
+	case "u":
+		return "underlined"
+	case "st":
+		return "strikethrough"
+	case "obf":
+		return "obfuscated"
+	case "colour", "c":
+		return "color"
+	case "insertion":
+		return "insert"
+	}
+
+	return name
+}
+
+func (p *markupParser) handle(content string) {
+	if strings.HasPrefix(content, "/") {
+		name := tagName(splitArgs(content[1:])[0])
+		for len(p.stack) > 1 {
+			closed := p.top().tag == name
+			p.pop()
+			if closed {
+				return
+			}
+		}
+		return
+	}
+
+	args := splitArgs(strings.TrimSuffix(content, "/"))
+	name := tagName(args[0])
+	negated := strings.HasPrefix(args[0], "!")
+
+	if placeholder, ok := p.placeholders[args[0]]; ok {
+		p.append(&markupNode{component: placeholder.Clone()})
+		return
+	}
+
+	if color, ok := parseColor(name); ok {
+		p.push(&markupNode{tag: name, component: NewTextComponent("").WithColor(color)})
+		return
+	}
+
+	if decorate, ok := decorations[name]; ok {
+		c := NewTextComponent("")
+		decorate(c, !negated)
+		p.push(&markupNode{tag: name, component: c})
+		return
+	}
+
+	switch name {
+	case "reset":
+		for len(p.stack) > 1 {
+			p.pop()
+		}
+	case "newline", "br":
+		p.append(&markupNode{component: NewTextComponent("\n")})
+	case "key":
+		p.append(&markupNode{component: NewKeybindComponent(argument(args, 1))})
+	case "lang", "tr", "translate":
+		with := make([]TextComponent, 0, len(args))
+		for _, arg := range args[min(2, len(args)):] {
+			with = append(with, *ParseMarkup(arg, p.placeholders))
+		}
+		p.append(&markupNode{component: NewTranslatableComponent(argument(args, 1), with...)})
+	case "color":
+		color, _ := parseColor(argument(args, 1))
+		p.push(&markupNode{tag: name, component: NewTextComponent("").WithColor(color)})
+	case "insert":
+		p.push(&markupNode{tag: name, component: NewTextComponent("").WithInsertion(argument(args, 1))})
+	case "font":
+		p.push(&markupNode{tag: name, component: NewTextComponent("").WithFont(strings.Join(args[1:], ":"))})
+	case "hover":
+		hover := ShowText(ParseMarkup(argument(args, 2), p.placeholders))
+		p.push(&markupNode{tag: name, component: NewTextComponent("").WithHover(hover)})
+	case "click":
+		// urls contain ':', so the value is everything after the action
+		c := NewTextComponent("").WithClickEvent(ClickEventAction(args[1]), strings.Join(args[2:], ":"))
+		p.push(&markupNode{tag: name, component: c})
+	case "gradient":
+		p.push(&markupNode{tag: name, component: NewTextComponent(""), colorize: gradient(args[1:])})
+	case "rainbow":
+		p.push(&markupNode{tag: name, component: NewTextComponent(""), colorize: rainbow(argument(args, 1))})
+	}
+}
+
+// parseColor accepts the named colors, #rrggbb and #rgb.
+func parseColor(s string) (Color, bool) {
+	s = strings.ToLower(s)
+	s = strings.ReplaceAll(s, "grey", "gray")
+
+	for _, named := range namedColors {
+		if string(named.color) == s {
+			return named.color, true
+		}
+	}
+
+	if len(s) == 4 && s[0] == '#' && isHex([]rune(s[1:])) {
+		s = string([]byte{'#', s[1], s[1], s[2], s[2], s[3], s[3]})
+	}
+
+	if len(s) == 7 && s[0] == '#' && isHex([]rune(s[1:])) {
+		return Color(s), true
+	}
+
+	return "", false
+}
+
+func gradient(args []string) func(i int, n int) Color {
+	var stops []uint32
+	for _, arg := range args {
+		if color, ok := parseColor(arg); ok {
+			rgb, _ := color.RGB()
+			stops = append(stops, rgb)
+		}
+	}
+
+	switch len(stops) {
+	case 0:
+		stops = []uint32{0xFFFFFF, 0x000000}
+	case 1:
+		stops = append(stops, stops[0])
+	}
+
+	return func(i int, n int) Color {
+		t := 0.0
+		if n > 1 {
+			t = float64(i) / float64(n-1)
+		}
+
+		position := t * float64(len(stops)-1)
+		segment := min(int(position), len(stops)-2)
+
+		return rgbColor(lerp(stops[segment], stops[segment+1], position-float64(segment)))
+	}
+}
+
+func rainbow(arg string) func(i int, n int) Color {
+	reverse := strings.HasPrefix(arg, "!")
+	phase, _ := strconv.Atoi(strings.TrimPrefix(arg, "!"))
+
+	return func(i int, n int) Color {
+		if reverse {
+			i = n - 1 - i
+		}
+
+		hue := math.Mod(float64(i)/float64(n)+float64(phase)/10, 1)
+		return rgbColor(hsvToRGB(hue, 1, 1))
+	}
+}
+
+func lerp(a uint32, b uint32, t float64) uint32 {
+	channel := func(shift uint32) uint32 {
+		x, y := float64(a>>shift&0xFF), float64(b>>shift&0xFF)
+		return uint32(math.Round(x+(y-x)*t)) << shift
+	}
+
+	return channel(16) | channel(8) | channel(0)
+}
+
+func hsvToRGB(h float64, s float64, v float64) uint32 {
+	sector := math.Floor(h * 6)
+	f := h*6 - sector
+	p, q, t := v*(1-s), v*(1-f*s), v*(1-(1-f)*s)
+
+	var r, g, b float64
+	switch int(sector) % 6 {
+	case 0:
+		r, g, b = v, t, p
+	case 1:
+		r, g, b = q, v, p
+	case 2:
+		r, g, b = p, v, t
+	case 3:
+		r, g, b = p, q, v
+	case 4:
+		r, g, b = t, p, v
+	default:
+		r, g, b = v, p, q
+	}
+
+	return uint32(r*255)<<16 | uint32(g*255)<<8 | uint32(b*255)
+}
+
+func rgbColor(rgb uint32) Color {
+	return Color(fmt.Sprintf("#%06x", rgb))
+}
+
+// applyColors splits every text below the node into characters colored by the node's colorize.
+// Texts below nested gradients and rainbows keep the colors of the innermost one and are only
+// counted, their nodes aren't visited again so deep nesting stays linear.
+func (n *markupNode) applyColors() {
+	// spans are the texts to color and the nested nodes colored already, in order
+	var spans []*markupNode
+	var collect func(n *markupNode)
+	collect = func(n *markupNode) {
+		if len(n.children) == 0 && n.component.IsPlain() && n.component.Text != "" {
+			spans = append(spans, n)
+		}
+		for _, child := range n.children {
+			if child.colored {
+				spans = append(spans, child)
+				continue
+			}
+			collect(child)
+		}
+	}
+	collect(n)
+
+	total := 0
+	for _, span := range spans {
+		if span.colored {
+			total += span.length
+		} else {
+			total += utf8.RuneCountInString(span.component.Text)
+		}
+	}
+
+	i := 0
+	for _, span := range spans {
+		if span.colored {
+			i += span.length
+			continue
+		}
+
+		for _, r := range span.component.Text {
+			span.children = append(span.children, &markupNode{component: NewTextComponent(string(r)).WithColor(n.colorize(i, total))})
+			i++
+		}
+		span.component.Text = ""
+	}
+
+	n.colored = true
+	n.length = total
+}
+
+func (n *markupNode) build() *TextComponent {
+	c := n.component
+	for _, child := range n.children {
+		c.Extras = append(c.Extras, *child.build())
+	}
+
+	return c
+}
+
+// EscapeMarkup escapes text so ParseMarkup shows it as it is.
+func EscapeMarkup(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `<`, `\<`).Replace(text)
+}
+
+// SerializeMarkup writes the component as markup that ParseMarkup turns back into an equal tree.
+func SerializeMarkup(c *TextComponent) string {
+	var out strings.Builder
+	writeMarkup(&out, c)
+	return out.String()
+}
+
+func writeMarkup(out *strings.Builder, c *TextComponent) {
+	var closing []string
+	open := func(tag string, name string) {
+		out.WriteString("<" + tag + ">")
+		closing = append(closing, name)
+	}
+
+	if c.Color != "" {
+		open(string(c.Color), string(c.Color))
+	}
+	for _, decoration := range []struct {
+		name  string
+		value *bool
+	}{
+		{"bold", c.Bold},
+		{"italic", c.Italic},
+		{"underlined", c.Underlined},
+		{"strikethrough", c.Strikethrough},
+		{"obfuscated", c.Obfuscated},
+	} {
+		if decoration.value == nil {
+			continue
+		}
+		if *decoration.value {
+			open(decoration.name, decoration.name)
+		} else {
+			open("!"+decoration.name, decoration.name)
+		}
+	}
+	if c.Font != "" {
+		open("font:"+quoteArgument(c.Font), "font")
+	}
+	if c.Insertion != "" {
+		open("insert:"+quoteArgument(c.Insertion), "insert")
+	}
+	if c.HoverEvent != nil && c.HoverEvent.Action == HoverShowText && c.HoverEvent.Text != nil {
+		open("hover:show_text:"+quoteArgument(SerializeMarkup(c.HoverEvent.Text)), "hover")
+	}
+	if c.ClickEvent != nil {
+		open("click:"+string(c.ClickEvent.Action)+":"+quoteArgument(c.ClickEvent.Value), "click")
+	}
+
+	switch {
+	case c.Translate != "":
+		out.WriteString("<lang:" + quoteArgument(c.Translate))
+		for i := range c.With {
+			out.WriteString(":" + quoteArgument(SerializeMarkup(&c.With[i])))
+		}
+		out.WriteString(">")
+	case c.Keybind != "":
+		out.WriteString("<key:" + quoteArgument(c.Keybind) + ">")
+	default:
+		out.WriteString(EscapeMarkup(c.contentText()))
+	}
+
+	for i := range c.Extras {
+		writeMarkup(out, &c.Extras[i])
+	}
+
+	for i := len(closing) - 1; i >= 0; i-- {
+		out.WriteString("</" + closing[i] + ">")
+	}
+}
+
+// quoteArgument quotes a tag argument if it contains anything that ends it early.
+func quoteArgument(arg string) string {
+	if !strings.ContainsAny(arg, `:>'"<\`) {
+		return arg
+	}
+
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(arg) + "'"
+}
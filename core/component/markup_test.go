@@ -0,0 +1,75 @@
+package component
+
+import (
+	"testing"
+)
+
+func TestMarkupRoundTrip(t *testing.T) {
+	for _, markup := range []string{
+		"plain text",
+		"<gold>Hello <bold>world</bold>!</gold>",
+		"<#ff8800>hex <!italic>upright</!italic>",
+		"<underlined><strikethrough>both</strikethrough></underlined> <obfuscated>x</obfuscated>",
+		"<hover:show_text:'<red>tip'>hover me</hover>",
+		"<click:open_url:'https://example.com'>link</click>",
+		"<click:run_command:/help>help</click>",
+		"<insert:'text: with colon'>insert</insert> <font:minecraft:uniform>font</font>",
+		"<lang:chat.type.text:'<green>Steve':hi> <key:key.jump>",
+		`escaped \<gold> and \\ backslash`,
+	} {
+		want, _ := ParseMarkup(markup, nil).Serialize()
+
+		serialized := SerializeMarkup(ParseMarkup(markup, nil))
+		got, _ := ParseMarkup(serialized, nil).Serialize()
+
+		if !sameJSON(t, want, got) {
+			t.Errorf("%s was written as %s, which parses to %s instead of %s", markup, serialized, got, want)
+		}
+	}
+}
+
+// Gradients are written as a color per character, so only what they look like has to survive.
+func TestMarkupGradientRoundTrip(t *testing.T) {
+	for _, markup := range []string{
+		"<gradient:#ff0000:#0000ff>gradient</gradient>",
+		"<bold><rainbow>rainbow</rainbow></bold><reset> after",
+	} {
+		want := SerializeLegacy(ParseMarkup(markup, nil), SectionChar)
+
+		serialized := SerializeMarkup(ParseMarkup(markup, nil))
+		if got := SerializeLegacy(ParseMarkup(serialized, nil), SectionChar); got != want {
+			t.Errorf("%s was written as %s, which looks like %q instead of %q", markup, serialized, got, want)
+		}
+	}
+}
+
+func TestEscapeMarkup(t *testing.T) {
+	text := `<gold> stays \ text`
+
+	if got := ParseMarkup(EscapeMarkup(text), nil).Text; got != text {
+		t.Errorf("escaped text was parsed as %q", got)
+	}
+}
+
+func TestMarkupPlaceholders(t *testing.T) {
+	c := ParseMarkup("Hi <name>!", map[string]*TextComponent{"name": NewTextComponent("Steve")})
+
+	if got := PlainText(c, nil); got != "Hi Steve!" {
+		t.Errorf("placeholder was replaced as %q", got)
+	}
+}
+
+func TestMarkupNestedGradient(t *testing.T) {
+	c := ParseMarkup("<gradient:#000000:#ffffff>a<gradient:#ff0000:#ff0000>bc</gradient>d</gradient>", nil)
+
+	var colors []Color
+	c.walk(style{}, func(c *TextComponent, s style) {
+		if c.Text != "" {
+			colors = append(colors, s.color)
+		}
+	})
+
+	if len(colors) != 4 || colors[1] != "#ff0000" || colors[2] != "#ff0000" {
+		t.Errorf("characters have the colors %v, want the inner gradient's red for b and c", colors)
+	}
+}
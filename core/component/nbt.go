@@ -0,0 +1,176 @@
+package component
+
+import (
+	"bytes"
+	"encoding/json"
+	"gopro/core/proto/encoding"
+	"gopro/core/proto/nbt"
+	"math"
+)
+
+// NBTProtocol is the first protocol version (1.20.3) that sends components as NBT instead of JSON.
+const NBTProtocol = 765
+
+// WriteVersioned writes the component as a JSON string, or as network NBT from NBTProtocol on.
+// Hex colors are downsampled for clients older than HexProtocol.
+func (c TextComponent) WriteVersioned(buffer *encoding.Buffer, protocol int) error {
+	if protocol < HexProtocol {
+		c = *c.Downsample()
+	}
+
+	if protocol < NBTProtocol {
+		s, err := c.Serialize()
+		if err != nil {
+			return err
+		}
+
+		encoding.String(s).Write(buffer)
+		return nil
+	}
+
+	tag, err := c.ToNBT()
+	if err != nil {
+		return err
+	}
+
+	return nbt.Write(buffer, tag)
+}
+
+// ReadVersioned reads a component written by WriteVersioned.
+func (c *TextComponent) ReadVersioned(buffer *encoding.Buffer, protocol int) error {
+	if protocol < NBTProtocol {
+		var s encoding.String
+		if err := s.Read(buffer); err != nil {
+			return err
+		}
+
+		return json.Unmarshal([]byte(s), c)
+	}
+
+	tag, err := nbt.Read(buffer)
+	if err != nil {
+		return err
+	}
+
+	return c.FromNBT(tag)
+}
+
+// ToNBT converts the component to the tag layout of the nbt package. The NBT form has the
+// same fields as the JSON one, booleans being bytes.
+func (c *TextComponent) ToNBT() (any, error) {
+	val, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(val))
+	decoder.UseNumber()
+
+	var tree any
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	return jsonToNBT(tree), nil
+}
+
+// FromNBT reads the component from a tag as returned by nbt.Read.
+func (c *TextComponent) FromNBT(tag any) error {
+	val, err := json.Marshal(nbtToJSON(tag))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(val, c)
+}
+
+func jsonToNBT(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			if i >= math.MinInt32 && i <= math.MaxInt32 {
+				return int32(i)
+			}
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		compound := make(map[string]any, len(v))
+		for key, elem := range v {
+			compound[key] = jsonToNBT(elem)
+		}
+		return compound
+	case []any:
+		list := make([]any, len(v))
+		mixed := false
+		for i, elem := range v {
+			list[i] = jsonToNBT(elem)
+			mixed = mixed || !sameTag(list[0], list[i])
+		}
+		if mixed {
+			// lists can't mix types, the client unwraps compounds holding a single empty key
+			for i, elem := range list {
+				list[i] = map[string]any{"": elem}
+			}
+		}
+		return list
+	}
+
+	return value
+}
+
+func sameTag(a any, b any) bool {
+	switch a.(type) {
+	case map[string]any:
+		_, ok := b.(map[string]any)
+		return ok
+	case []any:
+		_, ok := b.([]any)
+		return ok
+	}
+
+	return typeName(a) == typeName(b)
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case bool:
+		return "bool"
+	case int32:
+		return "int"
+	case int64:
+		return "long"
+	case float64:
+		return "double"
+	case string:
+		return "string"
+	}
+
+	return "unknown"
+}
+
+func nbtToJSON(value any) any {
+	switch v := value.(type) {
+	case int8:
+		// components only use bytes for booleans
+		return v != 0
+	case map[string]any:
+		if elem, ok := v[""]; ok && len(v) == 1 {
+			return nbtToJSON(elem)
+		}
+		obj := make(map[string]any, len(v))
+		for key, elem := range v {
+			obj[key] = nbtToJSON(elem)
+		}
+		return obj
+	case []any:
+		list := make([]any, len(v))
+		for i, elem := range v {
+			list[i] = nbtToJSON(elem)
+		}
+		return list
+	}
+
+	return value
+}
@@ -0,0 +1,73 @@
+package component
+
+import (
+	"gopro/core/proto/encoding"
+	"testing"
+)
+
+const nbtComponent = `{"text":"Hello","color":"#ff8800","bold":true,"italic":false,"extra":[` +
+	`{"translate":"chat.type.text","with":[{"text":"Steve"},{"text":"hi"}]},` +
+	`{"text":"link","clickEvent":{"action":"open_url","value":"https://example.com"}},` +
+	`{"text":"tooltip","hoverEvent":{"action":"show_text","contents":{"text":"shown","underlined":true}}},` +
+	`{"text":"item","hover_event":{"action":"show_item","id":"minecraft:stone","count":3}}]}`
+
+func TestNBTRoundTrip(t *testing.T) {
+	c, err := Deserialize(nbtComponent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := c.ToNBT()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var back TextComponent
+	if err := back.FromNBT(tag); err != nil {
+		t.Fatal(err)
+	}
+
+	output, _ := back.Serialize()
+	if !sameJSON(t, nbtComponent, output) {
+		t.Errorf("%s was read back from NBT as %s", nbtComponent, output)
+	}
+}
+
+func TestNBTPlainText(t *testing.T) {
+	tag, err := NewTextComponent("plain").ToNBT()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var back TextComponent
+	if err := back.FromNBT(tag); err != nil {
+		t.Fatal(err)
+	}
+	if back.Text != "plain" {
+		t.Errorf("plain text was read back as %q", back.Text)
+	}
+}
+
+func TestVersionedRoundTrip(t *testing.T) {
+	c, err := Deserialize(nbtComponent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, protocol := range []int{NBTProtocol - 1, NBTProtocol} {
+		buffer := encoding.NewBuffer(nil)
+		if err := c.WriteVersioned(buffer, protocol); err != nil {
+			t.Fatalf("protocol %d: %v", protocol, err)
+		}
+
+		var back TextComponent
+		if err := back.ReadVersioned(encoding.NewBuffer(buffer.Data), protocol); err != nil {
+			t.Fatalf("protocol %d: %v", protocol, err)
+		}
+
+		output, _ := back.Serialize()
+		if !sameJSON(t, nbtComponent, output) {
+			t.Errorf("protocol %d: %s was read back as %s", protocol, nbtComponent, output)
+		}
+	}
+}
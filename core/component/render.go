@@ -0,0 +1,212 @@
+package component
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+)
+
+// segment is a run of text with the style and events it is shown with.
+type segment struct {
+	text  string
+	style style
+	click *ClickEvent
+	hover *HoverEvent
+}
+
+type flattener struct {
+	language Language
+	segments []segment
+}
+
+// flatten resolves the component tree into segments, translating with language (EnglishUS if nil).
+func flatten(c *TextComponent, language Language) []segment {
+	if language == nil {
+		language = EnglishUS
+	}
+
+	f := &flattener{language: language}
+	f.add(c, segment{})
+	return f.segments
+}
+
+func (f *flattener) add(c *TextComponent, parent segment) {
+	s := segment{style: parent.style.inherit(c), click: parent.click, hover: parent.hover}
+	if c.ClickEvent != nil {
+		s.click = c.ClickEvent
+	}
+	if c.HoverEvent != nil {
+		s.hover = c.HoverEvent
+	}
+
+	switch {
+	case c.Translate != "":
+		f.translate(c, s)
+	case c.Keybind != "":
+		if name, ok := f.language.Translate(c.Keybind); ok {
+			f.text(name, s)
+		} else {
+			f.text(c.Keybind, s)
+		}
+	default:
+		f.text(c.contentText(), s)
+	}
+
+	for i := range c.Extras {
+		f.add(&c.Extras[i], s)
+	}
+}
+
+func (f *flattener) text(text string, s segment) {
+	if text == "" {
+		return
+	}
+
+	s.text = text
+	f.segments = append(f.segments, s)
+}
+
+func (f *flattener) translate(c *TextComponent, s segment) {
+	format, ok := f.language.Translate(c.Translate)
+	if !ok {
+		format = c.Fallback
+	}
+	if format == "" {
+		format = c.Translate
+	}
+
+	for _, part := range parseFormat(format) {
+		switch {
+		case part.arg < 0:
+			f.text(part.text, s)
+		case part.arg < len(c.With):
+			f.add(&c.With[part.arg], s)
+		}
+	}
+}
+
+// PlainText renders the component as text without any styling.
+func PlainText(c *TextComponent, language Language) string {
+	var out strings.Builder
+	for _, s := range flatten(c, language) {
+		out.WriteString(s.text)
+	}
+
+	return out.String()
+}
+
+// String renders the component as plain en_us text, so it logs readably.
+func (c *TextComponent) String() string {
+	return PlainText(c, nil)
+}
+
+// ANSI renders the component for terminals understanding 24-bit color escape codes.
+func ANSI(c *TextComponent, language Language) string {
+	var out strings.Builder
+	last := style{}
+
+	for _, s := range flatten(c, language) {
+		if s.style != last {
+			out.WriteString(ansiStyle(s.style))
+			last = s.style
+		}
+		out.WriteString(s.text)
+	}
+
+	if last != (style{}) {
+		out.WriteString("\x1b[0m")
+	}
+
+	return out.String()
+}
+
+func ansiStyle(s style) string {
+	codes := []string{"0"}
+
+	if rgb, ok := s.color.RGB(); ok {
+		codes = append(codes, fmt.Sprintf("38;2;%d;%d;%d", rgb>>16&0xFF, rgb>>8&0xFF, rgb&0xFF))
+	}
+	if s.bold {
+		codes = append(codes, "1")
+	}
+	if s.italic {
+		codes = append(codes, "3")
+	}
+	if s.underlined {
+		codes = append(codes, "4")
+	}
+	if s.strikethrough {
+		codes = append(codes, "9")
+	}
+
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
+
+// HTML renders the component as escaped HTML spans with inline styles. Hover texts become
+// titles and http(s) open_url click events links; nothing else from the component reaches
+// the markup unescaped.
+func HTML(c *TextComponent, language Language) string {
+	var out strings.Builder
+
+	for _, s := range flatten(c, language) {
+		text := strings.ReplaceAll(html.EscapeString(s.text), "\n", "<br>")
+
+		var attributes strings.Builder
+		if css := cssStyle(s.style); css != "" {
+			attributes.WriteString(` style="` + css + `"`)
+		}
+		if s.hover != nil && s.hover.Action == HoverShowText && s.hover.Text != nil {
+			attributes.WriteString(` title="` + html.EscapeString(PlainText(s.hover.Text, language)) + `"`)
+		}
+
+		span := "<span" + attributes.String() + ">" + text + "</span>"
+		if link, ok := safeURL(s.click); ok {
+			span = `<a href="` + html.EscapeString(link) + `" rel="nofollow noopener noreferrer">` + span + "</a>"
+		}
+
+		out.WriteString(span)
+	}
+
+	return out.String()
+}
+
+func cssStyle(s style) string {
+	var css []string
+
+	if rgb, ok := s.color.RGB(); ok {
+		css = append(css, fmt.Sprintf("color:#%06x", rgb))
+	}
+	if s.bold {
+		css = append(css, "font-weight:bold")
+	}
+	if s.italic {
+		css = append(css, "font-style:italic")
+	}
+
+	var decorations []string
+	if s.underlined {
+		decorations = append(decorations, "underline")
+	}
+	if s.strikethrough {
+		decorations = append(decorations, "line-through")
+	}
+	if len(decorations) > 0 {
+		css = append(css, "text-decoration:"+strings.Join(decorations, " "))
+	}
+
+	return strings.Join(css, ";")
+}
+
+func safeURL(click *ClickEvent) (string, bool) {
+	if click == nil || click.Action != ClickOpenURL {
+		return "", false
+	}
+
+	u, err := url.Parse(click.Value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+
+	return u.String(), true
+}
@@ -0,0 +1,160 @@
+package component
+
+import "maps"
+
+// style is the effective style of a component after inheriting from its parents.
+type style struct {
+	color         Color
+	bold          bool
+	italic        bool
+	underlined    bool
+	strikethrough bool
+	obfuscated    bool
+}
+
+func (s style) inherit(c *TextComponent) style {
+	if c.Color != "" {
+		s.color = c.Color
+	}
+	if c.Bold != nil {
+		s.bold = *c.Bold
+	}
+	if c.Italic != nil {
+		s.italic = *c.Italic
+	}
+	if c.Underlined != nil {
+		s.underlined = *c.Underlined
+	}
+	if c.Strikethrough != nil {
+		s.strikethrough = *c.Strikethrough
+	}
+	if c.Obfuscated != nil {
+		s.obfuscated = *c.Obfuscated
+	}
+
+	return s
+}
+
+// walk calls fn with the effective style of every component in the tree, parents before their extras.
+func (c *TextComponent) walk(parent style, fn func(c *TextComponent, s style)) {
+	s := parent.inherit(c)
+	fn(c, s)
+
+	for i := range c.Extras {
+		c.Extras[i].walk(s, fn)
+	}
+}
+
+// Clone returns a deep copy of the component.
+func (c *TextComponent) Clone() *TextComponent {
+	clone := *c
+
+	clone.With = cloneAll(c.With)
+	clone.Extras = cloneAll(c.Extras)
+	clone.unknown = maps.Clone(c.unknown)
+	if c.Score != nil {
+		score := *c.Score
+		clone.Score = &score
+	}
+	if c.NBT != nil {
+		content := *c.NBT
+		clone.NBT = &content
+	}
+	if c.Separator != nil {
+		clone.Separator = c.Separator.Clone()
+	}
+	if c.ClickEvent != nil {
+		click := *c.ClickEvent
+		click.unknown = maps.Clone(click.unknown)
+		clone.ClickEvent = &click
+	}
+	if c.HoverEvent != nil {
+		clone.HoverEvent = c.HoverEvent.clone()
+	}
+
+	return &clone
+}
+
+func (e *HoverEvent) clone() *HoverEvent {
+	hover := *e
+	hover.unknown = maps.Clone(e.unknown)
+	if e.Text != nil {
+		hover.Text = e.Text.Clone()
+	}
+	if e.Item != nil {
+		item := *e.Item
+		item.unknown = maps.Clone(e.Item.unknown)
+		if e.Item.Components != nil {
+			item.Components = cloneJSON(e.Item.Components).(map[string]any)
+		}
+		hover.Item = &item
+	}
+	if e.Entity != nil {
+		entity := *e.Entity
+		entity.unknown = maps.Clone(e.Entity.unknown)
+		if e.Entity.Name != nil {
+			entity.Name = e.Entity.Name.Clone()
+		}
+		hover.Entity = &entity
+	}
+
+	return &hover
+}
+
+// cloneJSON copies the maps and slices of a value decoded from JSON.
+func cloneJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		clone := make(map[string]any, len(v))
+		for key, elem := range v {
+			clone[key] = cloneJSON(elem)
+		}
+		return clone
+	case []any:
+		clone := make([]any, len(v))
+		for i, elem := range v {
+			clone[i] = cloneJSON(elem)
+		}
+		return clone
+	}
+
+	return value
+}
+
+func cloneAll(components []TextComponent) []TextComponent {
+	if components == nil {
+		return nil
+	}
+
+	clones := make([]TextComponent, len(components))
+	for i := range components {
+		clones[i] = *components[i].Clone()
+	}
+
+	return clones
+}
+
+// Downsample returns a copy of the component with every hex color replaced by the nearest named color.
+func (c *TextComponent) Downsample() *TextComponent {
+	clone := c.Clone()
+	clone.downsample()
+	return clone
+}
+
+func (c *TextComponent) downsample() {
+	if c.Color.IsHex() {
+		c.Color = c.Color.Nearest()
+	}
+	if c.Separator != nil {
+		c.Separator.downsample()
+	}
+	if c.HoverEvent != nil && c.HoverEvent.Text != nil {
+		c.HoverEvent.Text.downsample()
+	}
+	for i := range c.With {
+		c.With[i].downsample()
+	}
+	for i := range c.Extras {
+		c.Extras[i].downsample()
+	}
+}
@@ -0,0 +1,27 @@
+package component
+
+import (
+	"testing"
+)
+
+func TestCloneCopiesEvents(t *testing.T) {
+	c, err := Deserialize(`{"text":"","extra":[` +
+		`{"text":"item","clickEvent":{"action":"open_url","value":"https://example.com","future":1},` +
+		`"hover_event":{"action":"show_item","id":"minecraft:stone","components":{"minecraft:lore":["a"]},"future":1}},` +
+		`{"text":"entity","hoverEvent":{"action":"show_entity","contents":{"type":"minecraft:pig","id":[1,2,3,4],"name":{"text":"Pig"},"future":1}}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := c.Serialize()
+
+	clone := c.Clone()
+	clone.Extras[0].ClickEvent.unknown["future"] = []byte("2")
+	clone.Extras[0].HoverEvent.unknown["future"] = []byte("2")
+	clone.Extras[0].HoverEvent.Item.Components["minecraft:lore"].([]any)[0] = "b"
+	clone.Extras[1].HoverEvent.Entity.Name.Text = "Cow"
+	clone.Extras[1].HoverEvent.Entity.unknown["future"] = []byte("2")
+
+	if after, _ := c.Serialize(); after != before {
+		t.Errorf("changing the clone changed the original from %s to %s", before, after)
+	}
+}
@@ -0,0 +1,400 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Forwarding modes telling backends the real address and uuid of players.
+const (
+	ForwardingNone = "none"
+	// ForwardingLegacy appends them to the handshake host like BungeeCord does
+	ForwardingLegacy = "legacy"
+)
+
+// Config is the configuration of the proxy, read from a JSON file.
+type Config struct {
+	// Bind is the address clients connect to, unless Listeners are set
+	Bind       string `json:"bind"`
+	OnlineMode bool   `json:"online_mode"`
+	// CompressionThreshold is the packet size from which packets to clients are compressed, -1 to never compress
+	CompressionThreshold int    `json:"compression_threshold"`
+	Forwarding           string `json:"forwarding"`
+	// Servers maps the names of the backend servers to their addresses
+	Servers map[string]string `json:"servers"`
+	// Try lists the servers or groups players are connected to on join and moved to when their
+	// server goes away, the first one they can join being used
+	Try []string `json:"try"`
+	// Groups are the groups of servers players are balanced between, by group name
+	Groups map[string]GroupConfig `json:"groups,omitempty"`
+	// Capacity limits the number of players on servers, by server name
+	Capacity map[string]int `json:"capacity,omitempty"`
+	// ForcedHosts maps the hosts clients connect with to the servers or groups they join first
+	ForcedHosts map[string][]string `json:"forced_hosts,omitempty"`
+	// Listeners are the addresses clients connect to, each with its own settings
+	Listeners []ListenerConfig `json:"listeners,omitempty"`
+	// Restricted lists the servers only players with the permission gopro.server.<name> may join
+	Restricted []string `json:"restricted,omitempty"`
+	// BungeeCordMessaging answers the bungeecord:main plugin messages of backend plugins
+	BungeeCordMessaging bool              `json:"bungeecord_messaging"`
+	Status              StatusConfig      `json:"status"`
+	HealthCheck         HealthCheckConfig `json:"health_check"`
+	// ProxyProtocol reads the addresses of clients from the PROXY protocol headers of a load balancer
+	ProxyProtocol ProxyProtocolConfig `json:"proxy_protocol"`
+	RateLimit     RateLimitConfig     `json:"rate_limit"`
+	// MaxConnections caps the connections open at once on all listeners, 0 for no cap
+	MaxConnections int `json:"max_connections"`
+}
+
+// RateLimitConfig limits how often clients of an address, or of its subnet, may connect.
+type RateLimitConfig struct {
+	Enabled bool `json:"enabled"`
+	// Status limits the status pings, pings over the limits are closed
+	Status RateLimits `json:"status"`
+	// Login limits the logins, logins over the limits are disconnected
+	Login RateLimits `json:"login"`
+	// IPv4Subnet and IPv6Subnet are the prefix lengths of the subnets addresses are limited in
+	IPv4Subnet int `json:"ipv4_subnet"`
+	IPv6Subnet int `json:"ipv6_subnet"`
+	// LoginThrottle is how long an address has to wait after a login before the next, in milliseconds
+	LoginThrottle int `json:"login_throttle"`
+}
+
+// RateLimits are the token buckets of an address and of its subnet.
+type RateLimits struct {
+	IP     BucketConfig `json:"ip"`
+	Subnet BucketConfig `json:"subnet"`
+}
+
+// BucketConfig is a token bucket, allowing Burst attempts at once and refilling Rate attempts per
+// second. A burst of 0 doesn't limit the attempts.
+type BucketConfig struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// ProxyProtocolConfig sets where PROXY protocol headers are read from and whether they are sent.
+type ProxyProtocolConfig struct {
+	// Enabled expects a v1 or v2 header on every connection of a trusted source
+	Enabled bool `json:"enabled"`
+	// Trusted lists the addresses and CIDR ranges of the load balancers, required when enabled
+	Trusted []string `json:"trusted,omitempty"`
+	// SendToServers starts the connections to the servers with a v2 header with the address of the player
+	SendToServers bool `json:"send_to_servers"`
+}
+
+// ListenerConfig is an address clients connect to. The settings it leaves unset are the ones of
+// the config, as are the ones its proxy_protocol and rate_limit blocks leave out.
+type ListenerConfig struct {
+	// Name identifies the listener in logs and the API, it is the bind address if empty
+	Name string `json:"name,omitempty"`
+	Bind string `json:"bind"`
+	// MOTD is the MOTD markup of the server list, which is shown instead of the one of a backend
+	MOTD          string               `json:"motd,omitempty"`
+	OnlineMode    *bool                `json:"online_mode,omitempty"`
+	ForcedHosts   map[string][]string  `json:"forced_hosts,omitempty"`
+	ProxyProtocol *ProxyProtocolConfig `json:"proxy_protocol,omitempty"`
+	RateLimit     *RateLimitConfig     `json:"rate_limit,omitempty"`
+}
+
+// listeners are the listeners of the config, named, or a listener of Bind if there are none.
+func (c *Config) listeners() []ListenerConfig {
+	if len(c.Listeners) == 0 {
+		return []ListenerConfig{{Name: c.Bind, Bind: c.Bind}}
+	}
+
+	listeners := make([]ListenerConfig, len(c.Listeners))
+	for i, listener := range c.Listeners {
+		if listener.Name == "" {
+			listener.Name = listener.Bind
+		}
+		listeners[i] = listener
+	}
+
+	return listeners
+}
+
+// GroupConfig is a group of servers.
+type GroupConfig struct {
+	Servers []string `json:"servers"`
+	// Strategy picks the server of the group, one of the Strategy constants or a strategy
+	// registered by a plugin. It is round_robin if empty.
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// HealthCheckConfig sets how often the servers are pinged to find out whether they are up.
+type HealthCheckConfig struct {
+	Enabled bool `json:"enabled"`
+	// Interval is the time between checks, in milliseconds
+	Interval int `json:"interval"`
+	// Timeout is how long a server may take to answer, in milliseconds
+	Timeout int `json:"timeout"`
+}
+
+// Modes of pinging the backends for the server list.
+const (
+	PingBackendsOff = "off"
+	// PingBackendsCounts shows the sums of the player counts of the backends
+	PingBackendsCounts = "counts"
+	// PingBackendsMOTD shows the MOTD of StatusConfig.MOTDServer
+	PingBackendsMOTD = "motd"
+)
+
+// StatusConfig is what the proxy shows in the server list.
+type StatusConfig struct {
+	// MOTD is markup as read by component.ParseMarkup
+	MOTD       string `json:"motd"`
+	MaxPlayers int    `json:"max_players"`
+	// Favicon is the path of a 64x64 PNG image, empty for none
+	Favicon      string `json:"favicon"`
+	PingBackends string `json:"ping_backends"`
+	// MOTDServer is the server whose MOTD is shown, the first one of Try if empty
+	MOTDServer string `json:"motd_server,omitempty"`
+	// PingTimeout is how long pinging a backend may take, in milliseconds
+	PingTimeout int `json:"ping_timeout"`
+	// PingCache is how long the status of a backend is kept, in milliseconds
+	PingCache int `json:"ping_cache"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Bind:                 ":25565",
+		OnlineMode:           true,
+		CompressionThreshold: 256,
+		Forwarding:           ForwardingNone,
+		Servers:              map[string]string{"lobby": "127.0.0.1:25566"},
+		Try:                  []string{"lobby"},
+		BungeeCordMessaging:  true,
+		Status: StatusConfig{
+			MOTD:         "<aqua>A gopro proxy",
+			MaxPlayers:   500,
+			Favicon:      "server-icon.png",
+			PingBackends: PingBackendsOff,
+			PingTimeout:  1000,
+			PingCache:    5000,
+		},
+		HealthCheck: HealthCheckConfig{
+			Enabled:  true,
+			Interval: 10000,
+			Timeout:  2000,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Status: RateLimits{
+				IP:     BucketConfig{Rate: 2, Burst: 10},
+				Subnet: BucketConfig{Rate: 10, Burst: 50},
+			},
+			Login: RateLimits{
+				IP:     BucketConfig{Rate: 0.2, Burst: 3},
+				Subnet: BucketConfig{Rate: 2, Burst: 10},
+			},
+			IPv4Subnet:    24,
+			IPv6Subnet:    48,
+			LoginThrottle: 4000,
+		},
+	}
+}
+
+// LoadConfig reads the config at path, writing the default config there if there is none yet.
+func LoadConfig(path string) (*Config, error) {
+	config := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		return config, os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the default servers are examples for new configs, decoding would add them to the servers of
+	// the file as it merges maps
+	config.Servers = nil
+	config.Try = nil
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := config.completeListeners(data); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return config, config.validate()
+}
+
+// completeListeners reads the proxy_protocol and rate_limit blocks of the listeners again, onto
+// the ones of the config, so the settings a block leaves out are the ones of the config.
+func (c *Config) completeListeners(data []byte) error {
+	var raw struct {
+		Listeners []struct {
+			ProxyProtocol json.RawMessage `json:"proxy_protocol"`
+			RateLimit     json.RawMessage `json:"rate_limit"`
+		} `json:"listeners"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	for i, listener := range raw.Listeners {
+		if c.Listeners[i].ProxyProtocol != nil {
+			config := c.ProxyProtocol
+			// decoding trusted would overwrite the slice of the config
+			config.Trusted = append([]string(nil), config.Trusted...)
+			if err := json.Unmarshal(listener.ProxyProtocol, &config); err != nil {
+				return err
+			}
+			c.Listeners[i].ProxyProtocol = &config
+		}
+		if c.Listeners[i].RateLimit != nil {
+			config := c.RateLimit
+			if err := json.Unmarshal(listener.RateLimit, &config); err != nil {
+				return err
+			}
+			c.Listeners[i].RateLimit = &config
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validate() error {
+	if c.Forwarding != ForwardingNone && c.Forwarding != ForwardingLegacy {
+		return fmt.Errorf("unknown forwarding mode %q", c.Forwarding)
+	}
+
+	switch c.Status.PingBackends {
+	case PingBackendsOff, PingBackendsCounts, PingBackendsMOTD:
+	default:
+		return fmt.Errorf("unknown status ping_backends mode %q", c.Status.PingBackends)
+	}
+	if c.Status.MOTDServer != "" {
+		if _, ok := c.Servers[c.Status.MOTDServer]; !ok {
+			return fmt.Errorf("status motd_server is unknown server %q", c.Status.MOTDServer)
+		}
+	}
+
+	if c.HealthCheck.Enabled && (c.HealthCheck.Interval <= 0 || c.HealthCheck.Timeout <= 0) {
+		return errors.New("health_check interval and timeout have to be positive")
+	}
+
+	if err := c.ProxyProtocol.validate(); err != nil {
+		return fmt.Errorf("proxy_protocol: %w", err)
+	}
+	if err := c.validateForcedHosts(c.ForcedHosts); err != nil {
+		return err
+	}
+	if err := c.RateLimit.validate(); err != nil {
+		return fmt.Errorf("rate_limit: %w", err)
+	}
+	if c.MaxConnections < 0 {
+		return errors.New("max_connections is negative")
+	}
+
+	names := make(map[string]bool)
+	for _, listener := range c.listeners() {
+		if listener.Bind == "" {
+			return errors.New("listeners need a bind address")
+		}
+		if names[listener.Name] {
+			return fmt.Errorf("listener name %q is used twice", listener.Name)
+		}
+		names[listener.Name] = true
+
+		if listener.ProxyProtocol != nil {
+			if err := listener.ProxyProtocol.validate(); err != nil {
+				return fmt.Errorf("listener %q proxy_protocol: %w", listener.Name, err)
+			}
+		}
+		if err := c.validateForcedHosts(listener.ForcedHosts); err != nil {
+			return fmt.Errorf("listener %q: %w", listener.Name, err)
+		}
+		if listener.RateLimit != nil {
+			if err := listener.RateLimit.validate(); err != nil {
+				return fmt.Errorf("listener %q rate_limit: %w", listener.Name, err)
+			}
+		}
+	}
+
+	for name, group := range c.Groups {
+		if _, ok := c.Servers[name]; ok {
+			return fmt.Errorf("group %q has the name of a server", name)
+		}
+		if len(group.Servers) == 0 {
+			return fmt.Errorf("group %q has no servers", name)
+		}
+		for _, server := range group.Servers {
+			if _, ok := c.Servers[server]; !ok {
+				return fmt.Errorf("group %q lists unknown server %q", name, server)
+			}
+		}
+	}
+
+	for name, capacity := range c.Capacity {
+		if _, ok := c.Servers[name]; !ok {
+			return fmt.Errorf("capacity of unknown server %q", name)
+		}
+		if capacity < 0 {
+			return fmt.Errorf("capacity of server %q is negative", name)
+		}
+	}
+
+	for _, name := range c.Try {
+		_, server := c.Servers[name]
+		_, group := c.Groups[name]
+		if !server && !group {
+			return fmt.Errorf("try lists unknown server or group %q", name)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validateForcedHosts(hosts map[string][]string) error {
+	for host, names := range hosts {
+		for _, name := range names {
+			_, server := c.Servers[name]
+			_, group := c.Groups[name]
+			if !server && !group {
+				return fmt.Errorf("forced host %q lists unknown server or group %q", host, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *ProxyProtocolConfig) validate() error {
+	// clients could send headers with any address, getting around the rate limits too
+	if c.Enabled && len(c.Trusted) == 0 {
+		return errors.New("trusted has to list the load balancers when enabled")
+	}
+	if _, err := parseTrusted(c.Trusted); err != nil {
+		return fmt.Errorf("trusted: %w", err)
+	}
+
+	return nil
+}
+
+func (c *RateLimitConfig) validate() error {
+	if c.IPv4Subnet < 0 || c.IPv4Subnet > 32 || c.IPv6Subnet < 0 || c.IPv6Subnet > 128 {
+		return errors.New("subnet prefix lengths have to be 0-32 for IPv4 and 0-128 for IPv6")
+	}
+	if c.LoginThrottle < 0 {
+		return errors.New("login_throttle is negative")
+	}
+
+	for _, bucket := range []BucketConfig{c.Status.IP, c.Status.Subnet, c.Login.IP, c.Login.Subnet} {
+		if bucket.Rate < 0 || bucket.Burst < 0 {
+			return errors.New("rates and bursts can't be negative")
+		}
+	}
+
+	return nil
+}
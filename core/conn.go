@@ -1,24 +1,44 @@
 package core
 
 import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
 	"crypto/aes"
 	"crypto/cipher"
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog"
+	"gopro/core/component"
 	"gopro/core/proto"
 	"gopro/core/proto/encoding"
 	"gopro/core/proto/encryption"
+	"gopro/core/proto/packets"
+	"io"
 	"net"
+	"sync"
+	"sync/atomic"
 )
 
+// maxPacketSize is the largest packet the vanilla protocol allows, 2^21 - 1 bytes.
+const maxPacketSize = 2097151
+
+var errConnClosed = errors.New("connection closed")
+
 type Conn struct {
-	isActive bool
-	Conn     net.Conn
-	Logger   zerolog.Logger
+	Conn   net.Conn
+	Logger zerolog.Logger
+
+	ProtocolVersion int
+	// Threshold is the size from which packets are compressed, -1 while compression is off
+	Threshold int
 
-	State           byte
-	ProtocolVersion byte
-	Threshold       int
+	reader *bufio.Reader
+	closed atomic.Bool
+	// state is switched by the connection's goroutine and read by the ones sending to it
+	state atomic.Uint32
+	// writeMu keeps the packets of concurrent writers, and the encrypter, in order
+	writeMu sync.Mutex
 
 	encryptedState encryption.EncryptionState
 	sharedSecret   []byte
@@ -26,6 +46,12 @@ type Conn struct {
 	decrypter      cipher.Stream
 
 	currentHandler PacketHandler
+	// player is set once the connection logged in
+	player *Player
+	// listener is the listener that accepted the client, nil for connections to servers
+	listener *Listener
+	// VirtualHost is the host the client connected with, lowercased
+	VirtualHost string
 }
 
 type PacketHandler interface {
@@ -33,89 +59,227 @@ type PacketHandler interface {
 }
 
 func Wrap(conn net.Conn, logger zerolog.Logger, deps *HandlerDependency) *Conn {
-	wrapped := &Conn{Conn: conn, State: 0, Logger: logger}
+	wrapped := newConn(conn, logger)
 	wrapped.currentHandler = newHandshakeHandler(deps, wrapped)
 	return wrapped
+}
 
+func newConn(conn net.Conn, logger zerolog.Logger) *Conn {
+	c := &Conn{Conn: conn, Logger: logger, Threshold: -1, reader: bufio.NewReader(conn)}
+	c.SwitchState(proto.Handshake)
+	return c
 }
 
-func (c *Conn) StartEncrypting(sharedSecret []byte) {
+// StartEncrypting encrypts everything sent and received from now on with the shared secret.
+func (c *Conn) StartEncrypting(sharedSecret []byte) error {
+	block, err := aes.NewCipher(sharedSecret)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
 	c.sharedSecret = sharedSecret
 	c.encryptedState = encryption.SharedKey
-	block, err := aes.NewCipher(c.sharedSecret)
+	c.decrypter = encryption.NewCFB8Decrypter(block, sharedSecret)
+	c.encrypter = encryption.NewCFB8Encrypter(block, sharedSecret)
+
+	return nil
+}
+
+// ReadByte reads a byte off the connection, decrypting it if needed.
+func (c *Conn) ReadByte() (byte, error) {
+	b, err := c.reader.ReadByte()
 	if err != nil {
-		c.Logger.Error().Err(err).Msg("Error creating AES cipher")
-		c.Close()
-		return
+		return 0, err
+	}
+
+	if c.decrypter != nil {
+		decrypted := []byte{b}
+		c.decrypter.XORKeyStream(decrypted, decrypted)
+		b = decrypted[0]
 	}
 
-	c.decrypter = cipher.NewCFBDecrypter(block, c.sharedSecret)
-	c.encrypter = cipher.NewCFBEncrypter(block, c.sharedSecret)
+	return b, nil
 }
 
 func (c *Conn) Read() (*proto.Packet, error) {
-	if c.isActive {
-		return nil, nil
+	if c.closed.Load() {
+		return nil, errConnClosed
 	}
 
-	buffer := encoding.NewBuffer(make([]byte, 4096))
-
-	//read packet data
-	l, err := c.Conn.Read(buffer.Data)
+	length, err := encoding.ReadVarint(c)
 	if err != nil {
 		return nil, err
 	}
 
-	buffer.Data = buffer.Data[:l]
-
-	if c.encryptedState == encryption.SharedKey {
-		c.decrypt(buffer.Data)
+	if length <= 0 || length > maxPacketSize {
+		return nil, fmt.Errorf("invalid packet length %d", length)
 	}
 
-	var leng encoding.Varint
-	err = leng.Read(buffer)
-	if err != nil {
-		c.Logger.Error().Err(err).Msg("error reading packet length")
-		c.Close()
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
 		return nil, err
 	}
 
-	if int(leng) > len(buffer.Data) {
-		c.Logger.Error().Msg(fmt.Sprintf("incomplete packet received, expected %d but got %d", leng, len(buffer.Data)))
-		c.Close()
+	if c.decrypter != nil {
+		c.decrypter.XORKeyStream(data, data)
+	}
+
+	if c.Threshold >= 0 {
+		data, err = decompress(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return proto.Parse(encoding.NewBuffer(data))
+}
+
+func decompress(data []byte) ([]byte, error) {
+	buffer := encoding.NewBuffer(data)
+
+	var dataLength encoding.Varint
+	if err := dataLength.Read(buffer); err != nil {
 		return nil, err
 	}
 
-	packet, err := proto.Parse(buffer)
+	// a data length of 0 marks a packet below the threshold, sent uncompressed
+	if dataLength == 0 {
+		return buffer.Remaining(), nil
+	}
+
+	if dataLength < 0 || dataLength > maxPacketSize {
+		return nil, fmt.Errorf("invalid uncompressed packet length %d", dataLength)
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(buffer.Remaining()))
 	if err != nil {
-		c.Logger.Error().Err(err).Msg("error parsing packet")
-		c.Close()
 		return nil, err
 	}
+	defer reader.Close()
+
+	decompressed := make([]byte, dataLength)
+	if _, err := io.ReadFull(reader, decompressed); err != nil {
+		return nil, err
+	}
+
+	return decompressed, nil
+}
 
-	return packet, nil
+// State is the protocol state of the connection, one of proto.Handshake to proto.Play.
+func (c *Conn) State() byte {
+	return byte(c.state.Load())
 }
 
 func (c *Conn) SwitchState(b byte) {
-	c.State = b
+	c.state.Store(uint32(b))
 }
 
 func (c *Conn) SwitchPacketHandler(handler PacketHandler) {
 	c.currentHandler = handler
 }
-func (c *Conn) decrypt(bytearr []byte) {
-	decrypted := make([]byte, len(bytearr))
-	c.decrypter.XORKeyStream(decrypted, bytearr)
-	copy(bytearr, decrypted)
-}
 
 func (c *Conn) SendPacket(pk *proto.Packet) error {
-	_, err := c.Conn.Write(pk.Bytes())
+	if c.closed.Load() {
+		return errConnClosed
+	}
+
+	frame, err := c.frame(pk)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.encrypter != nil {
+		c.encrypter.XORKeyStream(frame, frame)
+	}
+
+	_, err = c.Conn.Write(frame)
 	return err
 }
 
+// frame prefixes the packet with its length, compressing it if it reaches the threshold.
+func (c *Conn) frame(pk *proto.Packet) ([]byte, error) {
+	if c.Threshold < 0 {
+		return pk.Bytes(), nil
+	}
+
+	body := pk.Body()
+	inner := make([]byte, 0, len(body)+5)
+
+	if len(body) < c.Threshold {
+		encoding.Varint(0).WriteIntoSlice(&inner)
+		inner = append(inner, body...)
+	} else {
+		encoding.Varint(len(body)).WriteIntoSlice(&inner)
+
+		var compressed bytes.Buffer
+		writer := zlib.NewWriter(&compressed)
+		if _, err := writer.Write(body); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		inner = append(inner, compressed.Bytes()...)
+	}
+
+	frame := make([]byte, 0, len(inner)+5)
+	encoding.Varint(len(inner)).WriteIntoSlice(&frame)
+
+	return append(frame, inner...), nil
+}
+
+// WritePacket marshals the packet for the protocol version of the connection and sends it.
+func (c *Conn) WritePacket(def proto.Definition) error {
+	pk, err := proto.MarshalDefinition(c.ProtocolVersion, def)
+	if err != nil {
+		return err
+	}
+
+	return c.SendPacket(pk)
+}
+
+// ReadPacket unmarshals the packet for the protocol version of the connection.
+func (c *Conn) ReadPacket(packet *proto.Packet, v any) error {
+	return packet.Unmarshal(c.ProtocolVersion, v)
+}
+
+// Disconnect sends the reason with the Disconnect packet of the current state and closes the connection.
+func (c *Conn) Disconnect(reason *component.TextComponent) {
+	c.Logger.Info().Stringer("reason", reason).Msg("Disconnecting")
+
+	var def proto.Definition
+	state := c.State()
+	switch {
+	case state == proto.Login:
+		def = packets.NewDisconnect(reason)
+	case state == proto.Configuration:
+		def = packets.NewConfigDisconnect(reason)
+	case state == proto.Play && c.ProtocolVersion >= packets.PlayProtocol:
+		def = packets.NewPlayDisconnect(reason)
+	}
+
+	if def != nil {
+		if err := c.WritePacket(def); err != nil {
+			c.Logger.Debug().Err(err).Str("packet", "disconnect").Msg("Error while sending packet")
+		}
+	}
+
+	c.Close()
+}
+
+// Closed tells whether the connection was closed.
+func (c *Conn) Closed() bool {
+	return c.closed.Load()
+}
+
 func (c *Conn) Close() {
-	if !c.isActive {
+	if c.closed.Swap(true) {
 		return
 	}
 
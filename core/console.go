@@ -0,0 +1,159 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"golang.org/x/term"
+	"gopro/core/component"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// consolePrompt is shown in front of the line being typed into the console.
+const consolePrompt = "> "
+
+// logOutput is where the log is written to. The console takes it over while it reads from a
+// terminal, so log lines are printed above the prompt instead of through the typed line.
+type logOutput struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (o *logOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.out.Write(p)
+}
+
+func (o *logOutput) set(out io.Writer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.out = out
+}
+
+// startConsole reads commands from stdin. A terminal is put into raw mode for line editing,
+// history and tab completion; other input, like a pipe, is read line by line.
+func (p *Proxy) startConsole() {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		go p.readCommands(os.Stdin)
+		return
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		p.logger.Warn().Err(err).Msg("Failed to set up the terminal, reading commands without line editing")
+		go p.readCommands(os.Stdin)
+		return
+	}
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, consolePrompt)
+	if width, height, err := term.GetSize(fd); err == nil {
+		_ = terminal.SetSize(width, height)
+	}
+	terminal.AutoCompleteCallback = p.completeConsole
+
+	p.output.set(terminal)
+	p.restoreConsole = func() {
+		p.output.set(os.Stderr)
+		_ = term.Restore(fd, state)
+	}
+
+	go p.readTerminal(terminal)
+}
+
+// stopConsole gives the terminal back in the state the proxy found it in.
+func (p *Proxy) stopConsole() {
+	if p.restoreConsole != nil {
+		p.restoreConsole()
+		p.restoreConsole = nil
+	}
+}
+
+func (p *Proxy) readTerminal(terminal *term.Terminal) {
+	for {
+		line, err := terminal.ReadLine()
+		if errors.Is(err, io.EOF) {
+			// ctrl-c and ctrl-d don't raise signals in raw mode
+			p.Shutdown()
+			return
+		}
+		if err != nil && !errors.Is(err, term.ErrPasteIndicator) {
+			p.logger.Error().Err(err).Msg("Failed to read from the console")
+			return
+		}
+
+		p.runConsoleLine(line)
+	}
+}
+
+func (p *Proxy) readCommands(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		p.runConsoleLine(scanner.Text())
+	}
+}
+
+func (p *Proxy) runConsoleLine(line string) {
+	line = strings.TrimPrefix(strings.TrimSpace(line), "/")
+	if line == "" {
+		return
+	}
+
+	_ = p.ExecuteCommand(p.console, line)
+}
+
+// completeConsole completes the word in front of the cursor on tab. Several candidates are
+// completed as far as they agree and then listed.
+func (p *Proxy) completeConsole(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	prefix := line[:pos]
+	start, suggestions := p.commands.Suggest(p.console, prefix)
+	if len(suggestions) == 0 {
+		return "", 0, false
+	}
+
+	completion := suggestions[0]
+	if len(suggestions) == 1 {
+		if !strings.HasPrefix(line[pos:], " ") {
+			completion += " "
+		}
+	} else {
+		for _, suggestion := range suggestions[1:] {
+			completion = commonPrefix(completion, suggestion)
+		}
+
+		// the terminal is locked while completing, the list is printed once it isn't
+		listing := strings.Join(suggestions, "  ")
+		go func() {
+			_ = p.console.SendMessage(component.NewTextComponent(listing))
+		}()
+	}
+
+	if len(completion) < pos-start {
+		return "", 0, false
+	}
+
+	completed := prefix[:start] + completion
+	return completed + line[pos:], len(completed), true
+}
+
+// commonPrefix is the longest prefix of a and b, ignoring case like the suggestions do.
+func commonPrefix(a string, b string) string {
+	n := 0
+	for n < len(a) && n < len(b) && strings.EqualFold(a[n:n+1], b[n:n+1]) {
+		n++
+	}
+
+	return a[:n]
+}
@@ -0,0 +1,27 @@
+package event
+
+import "net"
+
+// ConnectionHandshakeEvent is fired when a connection sent its handshake. Cancelling it closes the connection.
+type ConnectionHandshakeEvent struct {
+	Cancellation
+	RemoteAddr    net.Addr
+	Protocol      int
+	ServerAddress string
+	ServerPort    uint16
+	// NextState is the state the connection asked for, 1 for status and 2 for login
+	NextState byte
+}
+
+func (e *ConnectionHandshakeEvent) Name() string {
+	return "ConnectionHandshakeEvent"
+}
+
+// DisconnectEvent is fired when the connection of a player who logged in is closed.
+type DisconnectEvent struct {
+	Player Player
+}
+
+func (e *DisconnectEvent) Name() string {
+	return "DisconnectEvent"
+}
@@ -1,14 +1,60 @@
 package event
 
+// Priority orders the handlers of an event: VeryEarly handlers run first, System handlers last.
+// Handlers of equal priority run in the order they subscribed.
+type Priority int
+
 const (
-	VeryEarly = 0
-	Early     = 1
-	Moderate  = 2
-	Late      = 3
-	VeryLate  = 4
-	System    = 5
+	VeryEarly = Priority(iota)
+	Early
+	Moderate
+	Late
+	VeryLate
+	// System handlers observe the final outcome of the event, for logging or metrics. They may not
+	// change the event, changes to its fields are reverted.
+	System
 )
 
+func (p Priority) String() string {
+	switch p {
+	case VeryEarly:
+		return "very_early"
+	case Early:
+		return "early"
+	case Moderate:
+		return "moderate"
+	case Late:
+		return "late"
+	case VeryLate:
+		return "very_late"
+	case System:
+		return "system"
+	}
+
+	return "unknown"
+}
+
 type Event interface {
 	Name() string
 }
+
+// Cancellable is implemented by events whose outcome handlers can cancel. Handlers running after
+// a cancellation still run and see it, they may also undo it.
+type Cancellable interface {
+	Event
+	Cancelled() bool
+	SetCancelled(cancelled bool)
+}
+
+// Cancellation implements the state of Cancellable for embedding into events.
+type Cancellation struct {
+	cancelled bool
+}
+
+func (c *Cancellation) Cancelled() bool {
+	return c.cancelled
+}
+
+func (c *Cancellation) SetCancelled(cancelled bool) {
+	c.cancelled = cancelled
+}
@@ -2,72 +2,449 @@ package event
 
 import (
 	"github.com/rs/zerolog"
-	"os"
 	"reflect"
+	"runtime/debug"
 	"sort"
 	"sync"
+	"time"
 )
 
+// DefaultTimeout is how long an asynchronous handler may take before the bus moves on without it.
+const DefaultTimeout = 5 * time.Second
+
 type handlerWrapper struct {
-	handler  reflect.Value
-	priority int
+	id       uint64
+	owner    string
+	handler  func(Event, *Continuation)
+	priority Priority
+	// async handlers get a copy of the event, which replaces the event once they resume in time
+	async bool
 }
 
 type Bus struct {
 	logger    zerolog.Logger
-	listeners map[string][]handlerWrapper
-	mu        sync.RWMutex
+	listeners map[reflect.Type][]*handlerWrapper
+	timeouts  map[reflect.Type]time.Duration
+	nextID    uint64
+	// owner names the handlers subscribed while NameSubscriptions runs
+	owner string
+	mu    sync.RWMutex
+}
+
+// Subscription is the handle of a subscribed handler.
+type Subscription struct {
+	bus       *Bus
+	eventType reflect.Type
+	id        uint64
 }
 
-func NewEventBus() *Bus {
+func NewEventBus(logger zerolog.Logger) *Bus {
 	return &Bus{
-		listeners: make(map[string][]handlerWrapper),
-		//FIX THUS
-		logger: zerolog.New(os.Stdout).With().Timestamp().Logger(),
+		listeners: make(map[reflect.Type][]*handlerWrapper),
+		timeouts:  make(map[reflect.Type]time.Duration),
+		logger:    logger.With().Str("component", "event_bus").Logger(),
 	}
 }
 
-func (eb *Bus) Subscribe(priority int, handler interface{}) {
+// Subscribe registers handler for events of type T, which usually is a pointer to an event struct.
+func Subscribe[T Event](bus *Bus, priority Priority, handler func(T)) *Subscription {
+	return bus.subscribe(reflect.TypeFor[T](), priority, false, func(e Event, c *Continuation) {
+		handler(e.(T))
+		c.Resume()
+	})
+}
+
+// SubscribeAsync registers a handler that may finish its work after returning, for example after
+// a database lookup. The bus waits for it to call Resume on the continuation, or for the timeout
+// of the event, before calling the next handler. The handler works on a copy of the event, its
+// changes are dropped if it times out.
+func SubscribeAsync[T Event](bus *Bus, priority Priority, handler func(T, *Continuation)) *Subscription {
+	return bus.subscribe(reflect.TypeFor[T](), priority, true, func(e Event, c *Continuation) {
+		handler(e.(T), c)
+	})
+}
+
+// SetTimeout sets how long each asynchronous handler of events of type T may take.
+func SetTimeout[T Event](bus *Bus, timeout time.Duration) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	bus.timeouts[reflect.TypeFor[T]()] = timeout
+}
+
+func (eb *Bus) subscribe(eventType reflect.Type, priority Priority, async bool, handler func(Event, *Continuation)) *Subscription {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
 
-	handlerValue := reflect.ValueOf(handler)
-	handlerType := handlerValue.Type()
-
-	if handlerType.Kind() != reflect.Func || handlerType.NumIn() != 1 {
-		eb.logger.Panic().Msgf("handler must be a function with exactly one argument")
-	}
+	eb.nextID++
+	wrapper := &handlerWrapper{id: eb.nextID, owner: eb.owner, handler: handler, priority: priority, async: async}
 
-	//first argument
-	firstArgument := handlerType.In(0)
-	eventName := reflect.Zero(firstArgument).Interface().(Event).Name()
+	handlers := append(eb.listeners[eventType], wrapper)
 
-	eb.listeners[eventName] = append(eb.listeners[eventName], handlerWrapper{
-		handler:  handlerValue,
-		priority: priority,
+	//sort handlers by priority, keeping the subscription order of equal priorities
+	sort.SliceStable(handlers, func(i, j int) bool {
+		return handlers[i].priority < handlers[j].priority
 	})
 
+	eb.listeners[eventType] = handlers
+
 	//log the event registration
-	eb.logger.Info().Str("event", eventName).Int("priority", priority).Msg("Event hooked")
+	eb.logger.Debug().Stringer("event", eventType).Stringer("priority", priority).Msg("Event hooked")
 
-	//sort handlers by priority
-	sort.Slice(eb.listeners[eventName], func(i, j int) bool {
-		return eb.listeners[eventName][i].priority > eb.listeners[eventName][j].priority
-	})
+	return &Subscription{bus: eb, eventType: eventType, id: wrapper.id}
+}
+
+// Named sets the owner of the handler, usually a plugin, which is logged when the handler misbehaves.
+func (s *Subscription) Named(owner string) *Subscription {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	for _, wrapper := range s.bus.listeners[s.eventType] {
+		if wrapper.id == s.id {
+			wrapper.owner = owner
+		}
+	}
+
+	return s
+}
+
+// NameSubscriptions calls fn, naming the handlers it subscribes after the owner as Named does.
+// Handlers other goroutines subscribe meanwhile are named too, so owners take turns, like plugins
+// loading one after another.
+func (eb *Bus) NameSubscriptions(owner string, fn func() error) error {
+	eb.mu.Lock()
+	eb.owner = owner
+	eb.mu.Unlock()
+
+	defer func() {
+		eb.mu.Lock()
+		eb.owner = ""
+		eb.mu.Unlock()
+	}()
+
+	return fn()
+}
+
+// Unsubscribe removes the handler, it is not called by events fired afterwards.
+func (s *Subscription) Unsubscribe() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	handlers := s.bus.listeners[s.eventType]
+	for i, wrapper := range handlers {
+		if wrapper.id == s.id {
+			// copy, Fire may still be iterating over the old slice
+			remaining := make([]*handlerWrapper, 0, len(handlers)-1)
+			remaining = append(remaining, handlers[:i]...)
+			s.bus.listeners[s.eventType] = append(remaining, handlers[i+1:]...)
+			return
+		}
+	}
+}
+
+// UnsubscribeOwner removes the handlers named after the owner, like the ones of a plugin that
+// failed to load.
+func (eb *Bus) UnsubscribeOwner(owner string) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	for eventType, handlers := range eb.listeners {
+		// copy, Fire may still be iterating over the old slice
+		remaining := make([]*handlerWrapper, 0, len(handlers))
+		for _, wrapper := range handlers {
+			if wrapper.owner != owner {
+				remaining = append(remaining, wrapper)
+			}
+		}
+		eb.listeners[eventType] = remaining
+	}
+}
+
+// Fire calls every handler of the event in priority order and returns once all of them ran.
+// A panicking handler is logged and doesn't stop the others.
+func (eb *Bus) Fire(event Event) {
+	eb.run(event)
 }
 
-func (eb *Bus) Trigger(event Event) {
+// FireAsync is Fire without blocking the caller, the returned future completes once all handlers ran.
+func (eb *Bus) FireAsync(event Event) *Future {
+	future := newFuture()
+
+	go func() {
+		defer future.complete()
+		eb.run(event)
+	}()
+
+	return future
+}
+
+func (eb *Bus) run(event Event) {
+	eventType := reflect.TypeOf(event)
+
 	eb.mu.RLock()
-	defer eb.mu.RUnlock()
-
-	if handlers, ok := eb.listeners[event.Name()]; ok {
-		go func(handlers []handlerWrapper) {
-			for _, wrapper := range handlers {
-				eventValue := reflect.ValueOf(event)
-				if wrapper.handler.Type().In(0) == eventValue.Type() {
-					wrapper.handler.Call([]reflect.Value{eventValue})
-				}
+	handlers := eb.listeners[eventType]
+	timeout, ok := eb.timeouts[eventType]
+	eb.mu.RUnlock()
+
+	if !ok {
+		timeout = DefaultTimeout
+	}
+
+	for _, wrapper := range handlers {
+		var before reflect.Value
+		if wrapper.priority == System {
+			before = snapshot(event)
+		}
+
+		// a handler that times out may still be working on its copy, which is dropped
+		target := event
+		if wrapper.async {
+			target = clone(event)
+		}
+
+		continuation := newContinuation()
+		eb.call(wrapper, target, continuation)
+
+		if !eb.await(continuation, timeout) {
+			eb.logger.Warn().Str("event", event.Name()).Str("plugin", wrapper.owner).Dur("timeout", timeout).Msg("Event handler timed out, continuing without it")
+			continue
+		}
+
+		if target != event {
+			reflect.ValueOf(event).Elem().Set(reflect.ValueOf(target).Elem())
+		}
+
+		if continuation.err != nil {
+			eb.logger.Error().Err(continuation.err).Str("event", event.Name()).Str("plugin", wrapper.owner).Msg("Event handler failed")
+		}
+
+		if before.IsValid() && restore(event, before) {
+			eb.logger.Error().Str("event", event.Name()).Str("plugin", wrapper.owner).Msg("System event handler changed the event, the change was reverted")
+		}
+	}
+}
+
+// snapshot copies the struct an event points to, values behind its pointers, slices and maps
+// included, so changes to them are reverted too.
+func snapshot(event Event) reflect.Value {
+	v := reflect.ValueOf(event)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+
+	return deepCopy(v.Elem(), map[visit]reflect.Value{})
+}
+
+// clone copies the struct an event points to along with the values behind its pointers, slices
+// and maps. Interfaces, like players and servers, are shared. Events that don't point to a struct
+// are returned as they are.
+func clone(event Event) Event {
+	v := reflect.ValueOf(event)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return event
+	}
+
+	copied := reflect.New(v.Elem().Type())
+	copied.Elem().Set(deepCopy(v.Elem(), map[visit]reflect.Value{}))
+	return copied.Interface().(Event)
+}
+
+// visit identifies a pointer, map or slice already copied or compared, so cycles end there.
+type visit struct {
+	ptr    uintptr
+	length int
+	typ    reflect.Type
+}
+
+// deepCopy copies the value for clone, copying what several pointers share only once.
+// Unexported fields are copied as they are, funcs, channels and unsafe pointers are shared.
+func deepCopy(v reflect.Value, copies map[visit]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		key := visit{ptr: v.Pointer(), typ: v.Type()}
+		if copied, ok := copies[key]; ok {
+			return copied
+		}
+		copied := reflect.New(v.Elem().Type())
+		copies[key] = copied
+		copied.Elem().Set(deepCopy(v.Elem(), copies))
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := copied.Field(i); field.CanSet() {
+				field.Set(deepCopy(v.Field(i), copies))
 			}
-		}(handlers)
+		}
+		return copied
+	case reflect.Array:
+		copied := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(deepCopy(v.Index(i), copies))
+		}
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		key := visit{ptr: v.Pointer(), length: v.Len(), typ: v.Type()}
+		if copied, ok := copies[key]; ok {
+			return copied
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		copies[key] = copied
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(deepCopy(v.Index(i), copies))
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		key := visit{ptr: v.Pointer(), typ: v.Type()}
+		if copied, ok := copies[key]; ok {
+			return copied
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		copies[key] = copied
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), deepCopy(iter.Value(), copies))
+		}
+		return copied
 	}
+
+	return v
+}
+
+// restore sets the event back to the snapshot, reporting whether it had changed.
+func restore(event Event, before reflect.Value) bool {
+	current := reflect.ValueOf(event).Elem()
+	if equal(before, current, map[[2]visit]bool{}) {
+		return false
+	}
+
+	current.Set(before)
+	return true
+}
+
+// equal is reflect.DeepEqual for the values deepCopy copies. Funcs, channels and unsafe pointers
+// are skipped, as DeepEqual never finds two funcs equal and deepCopy shares them anyway.
+func equal(a reflect.Value, b reflect.Value, visited map[[2]visit]bool) bool {
+	switch a.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return true
+	case reflect.Pointer:
+		if a.Pointer() == b.Pointer() {
+			return true
+		}
+		if a.IsNil() || b.IsNil() {
+			return false
+		}
+		key := [2]visit{{ptr: a.Pointer(), typ: a.Type()}, {ptr: b.Pointer(), typ: b.Type()}}
+		if visited[key] {
+			return true
+		}
+		visited[key] = true
+		return equal(a.Elem(), b.Elem(), visited)
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		if a.Elem().Type() != b.Elem().Type() {
+			return false
+		}
+		return equal(a.Elem(), b.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if !equal(a.Field(i), b.Field(i), visited) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if !equal(a.Index(i), b.Index(i), visited) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false
+		}
+		if a.Pointer() == b.Pointer() {
+			return true
+		}
+		key := [2]visit{{ptr: a.Pointer(), length: a.Len(), typ: a.Type()}, {ptr: b.Pointer(), length: b.Len(), typ: b.Type()}}
+		if visited[key] {
+			return true
+		}
+		visited[key] = true
+		for i := 0; i < a.Len(); i++ {
+			if !equal(a.Index(i), b.Index(i), visited) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false
+		}
+		if a.Pointer() == b.Pointer() {
+			return true
+		}
+		key := [2]visit{{ptr: a.Pointer(), typ: a.Type()}, {ptr: b.Pointer(), typ: b.Type()}}
+		if visited[key] {
+			return true
+		}
+		visited[key] = true
+		iter := a.MapRange()
+		for iter.Next() {
+			value := b.MapIndex(iter.Key())
+			if !value.IsValid() || !equal(iter.Value(), value, visited) {
+				return false
+			}
+		}
+		return true
+	case reflect.Invalid:
+		return !b.IsValid()
+	}
+
+	return a.Equal(b)
+}
+
+// await waits for the continuation, returning false if it timed out.
+func (eb *Bus) await(continuation *Continuation, timeout time.Duration) bool {
+	select {
+	case <-continuation.done:
+		// synchronous handlers are done before returning, skip the timer
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-continuation.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (eb *Bus) call(wrapper *handlerWrapper, event Event, continuation *Continuation) {
+	defer func() {
+		if r := recover(); r != nil {
+			eb.logger.Error().Str("event", event.Name()).Str("plugin", wrapper.owner).Interface("panic", r).Bytes("stack", debug.Stack()).Msg("Event handler panicked")
+			continuation.Resume()
+		}
+	}()
+
+	wrapper.handler(event, continuation)
 }
@@ -0,0 +1,387 @@
+package event
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type testEvent struct {
+	Cancellation
+	Message string
+	Tags    []string
+	Info    *testInfo
+}
+
+type testInfo struct {
+	Online int
+}
+
+func (e *testEvent) Name() string {
+	return "testEvent"
+}
+
+func newTestBus() *Bus {
+	return NewEventBus(zerolog.Nop())
+}
+
+func TestFireRunsEarliestPriorityFirst(t *testing.T) {
+	bus := newTestBus()
+
+	var calls []string
+	for _, priority := range []Priority{System, Late, VeryEarly, VeryLate, Moderate, Early} {
+		priority := priority
+		Subscribe(bus, priority, func(e *testEvent) {
+			calls = append(calls, priority.String())
+		})
+	}
+
+	bus.Fire(&testEvent{})
+
+	want := []string{"very_early", "early", "moderate", "late", "very_late", "system"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("handlers ran in order %v, want %v", calls, want)
+	}
+}
+
+func TestFireKeepsSubscriptionOrderOfEqualPriorities(t *testing.T) {
+	bus := newTestBus()
+
+	var calls []string
+	for _, name := range []string{"a", "b", "c", "d"} {
+		name := name
+		Subscribe(bus, Moderate, func(e *testEvent) {
+			calls = append(calls, name)
+		})
+	}
+	Subscribe(bus, Early, func(e *testEvent) {
+		calls = append(calls, "early")
+	})
+
+	bus.Fire(&testEvent{})
+
+	want := []string{"early", "a", "b", "c", "d"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("handlers ran in order %v, want %v", calls, want)
+	}
+}
+
+func TestLaterHandlersSeeCancellation(t *testing.T) {
+	bus := newTestBus()
+
+	Subscribe(bus, Early, func(e *testEvent) {
+		e.SetCancelled(true)
+	})
+
+	var seen bool
+	Subscribe(bus, Late, func(e *testEvent) {
+		seen = e.Cancelled()
+	})
+
+	e := &testEvent{}
+	bus.Fire(e)
+
+	if !seen {
+		t.Error("late handler didn't see the cancellation of the early one")
+	}
+}
+
+func TestSystemHandlersCannotChangeEvent(t *testing.T) {
+	bus := newTestBus()
+
+	Subscribe(bus, VeryLate, func(e *testEvent) {
+		e.Message = "changed by very late"
+	})
+
+	var seen string
+	Subscribe(bus, System, func(e *testEvent) {
+		seen = e.Message
+		e.Message = "changed by system"
+		e.SetCancelled(true)
+	})
+
+	e := &testEvent{}
+	bus.Fire(e)
+
+	if seen != "changed by very late" {
+		t.Errorf("system handler saw %q, want the outcome of the other handlers", seen)
+	}
+	if e.Message != "changed by very late" {
+		t.Errorf("message is %q after firing, the system handler's change wasn't reverted", e.Message)
+	}
+	if e.Cancelled() {
+		t.Error("event is cancelled after firing, the system handler's cancellation wasn't reverted")
+	}
+}
+
+func TestUnsubscribedHandlerIsNotCalled(t *testing.T) {
+	bus := newTestBus()
+
+	called := false
+	subscription := Subscribe(bus, Moderate, func(e *testEvent) {
+		called = true
+	})
+	subscription.Unsubscribe()
+
+	bus.Fire(&testEvent{})
+
+	if called {
+		t.Error("handler was called after unsubscribing")
+	}
+}
+
+func TestPanickingHandlerDoesNotStopOthers(t *testing.T) {
+	bus := newTestBus()
+
+	Subscribe(bus, Early, func(e *testEvent) {
+		panic("broken plugin")
+	})
+
+	called := false
+	Subscribe(bus, Late, func(e *testEvent) {
+		called = true
+	})
+
+	bus.Fire(&testEvent{})
+
+	if !called {
+		t.Error("handler after the panicking one wasn't called")
+	}
+}
+
+func TestAsyncHandlerChangesAreKeptOnceResumed(t *testing.T) {
+	bus := newTestBus()
+
+	SubscribeAsync(bus, Early, func(e *testEvent, c *Continuation) {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			e.Message = "changed by async"
+			e.SetCancelled(true)
+			c.Resume()
+		}()
+	})
+
+	var seen string
+	Subscribe(bus, Late, func(e *testEvent) {
+		seen = e.Message
+	})
+
+	e := &testEvent{}
+	bus.Fire(e)
+
+	if seen != "changed by async" {
+		t.Errorf("late handler saw %q, want the change of the async handler before it", seen)
+	}
+	if e.Message != "changed by async" || !e.Cancelled() {
+		t.Errorf("event is %+v after firing, the async handler's changes weren't kept", e)
+	}
+}
+
+func TestFailedAsyncHandlerDoesNotStopOthers(t *testing.T) {
+	bus := newTestBus()
+
+	SubscribeAsync(bus, Early, func(e *testEvent, c *Continuation) {
+		e.Message = "changed before failing"
+		c.Fail(errors.New("lookup failed"))
+		// calls after the first are ignored
+		c.Resume()
+	})
+
+	called := false
+	Subscribe(bus, Late, func(e *testEvent) {
+		called = true
+	})
+
+	e := &testEvent{}
+	bus.Fire(e)
+
+	if !called {
+		t.Error("handler after the failed one wasn't called")
+	}
+	if e.Message != "changed before failing" {
+		t.Errorf("message is %q, want the change made before failing", e.Message)
+	}
+}
+
+func TestTimedOutAsyncHandlerCannotChangeEvent(t *testing.T) {
+	bus := newTestBus()
+	SetTimeout[*testEvent](bus, 10*time.Millisecond)
+
+	release := make(chan struct{})
+	changed := make(chan struct{})
+	SubscribeAsync(bus, Early, func(e *testEvent, c *Continuation) {
+		go func() {
+			<-release
+			e.Message = "changed after timing out"
+			c.Resume()
+			close(changed)
+		}()
+	})
+
+	var seen string
+	Subscribe(bus, Late, func(e *testEvent) {
+		seen = e.Message
+	})
+
+	e := &testEvent{Message: "original"}
+	future := bus.FireAsync(e)
+
+	select {
+	case <-future.Done():
+	case <-time.After(time.Second):
+		t.Fatal("event didn't complete after the handler timed out")
+	}
+
+	close(release)
+	<-changed
+
+	if seen != "original" {
+		t.Errorf("late handler saw %q, want the event without the timed out handler", seen)
+	}
+	if e.Message != "original" {
+		t.Errorf("message is %q, the timed out handler changed the event", e.Message)
+	}
+}
+
+func TestAsyncHandlerWaitsForTimeoutOfEvent(t *testing.T) {
+	bus := newTestBus()
+	SetTimeout[*testEvent](bus, 10*time.Millisecond)
+
+	SubscribeAsync(bus, Moderate, func(e *testEvent, c *Continuation) {
+		// never resumes
+	})
+
+	start := time.Now()
+	bus.Fire(&testEvent{})
+
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond || elapsed > time.Second {
+		t.Errorf("firing took %s, want the timeout of the event", elapsed)
+	}
+}
+
+func TestNameSubscriptionsNamesHandlersSubscribedMeanwhile(t *testing.T) {
+	bus := newTestBus()
+
+	_ = bus.NameSubscriptions("plugin", func() error {
+		Subscribe(bus, Moderate, func(e *testEvent) {})
+		return nil
+	})
+	Subscribe(bus, Moderate, func(e *testEvent) {})
+
+	handlers := bus.listeners[reflect.TypeFor[*testEvent]()]
+	if handlers[0].owner != "plugin" {
+		t.Errorf("handler subscribed by the plugin is owned by %q, want plugin", handlers[0].owner)
+	}
+	if handlers[1].owner != "" {
+		t.Errorf("handler subscribed afterwards is owned by %q, want none", handlers[1].owner)
+	}
+}
+
+func TestUnsubscribeOwnerRemovesHandlersOfOwner(t *testing.T) {
+	bus := newTestBus()
+
+	var calls []string
+	_ = bus.NameSubscriptions("plugin", func() error {
+		Subscribe(bus, Moderate, func(e *testEvent) { calls = append(calls, "plugin") })
+		return nil
+	})
+	Subscribe(bus, Moderate, func(e *testEvent) { calls = append(calls, "other") }).Named("other")
+
+	bus.UnsubscribeOwner("plugin")
+	bus.Fire(&testEvent{})
+
+	if want := []string{"other"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("called %v, want %v", calls, want)
+	}
+}
+
+func TestSystemHandlersCannotChangeValuesBehindPointers(t *testing.T) {
+	bus := newTestBus()
+
+	Subscribe(bus, System, func(e *testEvent) {
+		e.Tags[0] = "changed by system"
+		e.Info.Online = 100
+	})
+
+	e := &testEvent{Tags: []string{"original"}, Info: &testInfo{Online: 1}}
+	bus.Fire(e)
+
+	if e.Tags[0] != "original" {
+		t.Errorf("tag is %q after firing, the system handler's change wasn't reverted", e.Tags[0])
+	}
+	if e.Info.Online != 1 {
+		t.Errorf("online is %d after firing, the system handler's change wasn't reverted", e.Info.Online)
+	}
+}
+
+type cyclicEvent struct {
+	Node     *testNode
+	Callback func() string
+	Done     chan struct{}
+}
+
+type testNode struct {
+	Value string
+	Next  *testNode
+}
+
+func (e *cyclicEvent) Name() string {
+	return "cyclicEvent"
+}
+
+func newCyclicEvent() *cyclicEvent {
+	first := &testNode{Value: "first"}
+	first.Next = &testNode{Value: "second", Next: first}
+
+	return &cyclicEvent{Node: first, Callback: func() string { return "callback" }, Done: make(chan struct{})}
+}
+
+func TestCloneKeepsCycles(t *testing.T) {
+	e := newCyclicEvent()
+
+	copied := clone(e).(*cyclicEvent)
+	if copied.Node == e.Node || copied.Node.Next == e.Node.Next {
+		t.Error("clone shares the nodes with the event")
+	}
+	if copied.Node.Next.Next != copied.Node {
+		t.Error("clone doesn't keep the cycle of the nodes")
+	}
+	if copied.Callback() != "callback" || copied.Done != e.Done {
+		t.Error("clone doesn't share the callback and channel")
+	}
+}
+
+func TestUnchangedEventWithFuncsIsNotRestored(t *testing.T) {
+	e := newCyclicEvent()
+
+	if restore(e, snapshot(e)) {
+		t.Error("unchanged event was reported as changed")
+	}
+
+	before := snapshot(e)
+	e.Node.Next.Value = "changed"
+	if !restore(e, before) {
+		t.Error("changed event was reported as unchanged")
+	}
+	if e.Node.Next.Value != "second" || e.Node.Next.Next != e.Node {
+		t.Errorf("event wasn't restored, the second node is %+v", e.Node.Next)
+	}
+}
+
+func TestSystemHandlersCannotChangeCyclicEvent(t *testing.T) {
+	bus := newTestBus()
+
+	Subscribe(bus, System, func(e *cyclicEvent) {
+		e.Node.Next.Next = nil
+	})
+
+	e := newCyclicEvent()
+	bus.Fire(e)
+
+	if e.Node.Next.Next != e.Node {
+		t.Error("the system handler's change of a cyclic event wasn't reverted")
+	}
+}
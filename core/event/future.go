@@ -0,0 +1,60 @@
+package event
+
+import (
+	"context"
+	"sync"
+)
+
+// Continuation is handed to asynchronous handlers, which call Resume or Fail exactly once when done.
+// Calls after the first, or after the handler timed out, are ignored.
+type Continuation struct {
+	once sync.Once
+	err  error
+	done chan struct{}
+}
+
+func newContinuation() *Continuation {
+	return &Continuation{done: make(chan struct{})}
+}
+
+func (c *Continuation) Resume() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+// Fail resumes the event, logging err.
+func (c *Continuation) Fail(err error) {
+	c.once.Do(func() {
+		c.err = err
+		close(c.done)
+	})
+}
+
+// Future completes when every handler of an event fired with FireAsync ran.
+type Future struct {
+	done chan struct{}
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) complete() {
+	close(f.done)
+}
+
+// Done is closed once the event completed.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Await blocks until the event completed or ctx is done, in which case its error is returned.
+func (f *Future) Await(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,22 +1,75 @@
 package event
 
-import "gopro/core/component"
+import (
+	"gopro/core/component"
+	"gopro/core/proto/auth"
+	"net"
+)
 
-type LoginStartEvent struct {
+// PreLoginEvent is fired when a client starts logging in, before it is authenticated. Handlers
+// may reject the login or let the player join without authentication.
+type PreLoginEvent struct {
+	RemoteAddr     net.Addr
 	Username       string
+	OnlineMode     bool
 	Declined       bool
 	DeclinedReason *component.TextComponent
 }
 
-func NewLoginStartEvent(user string) *LoginStartEvent {
-	return &LoginStartEvent{Username: user, Declined: false}
+// LoginStartEvent is the former name of PreLoginEvent.
+//
+// Deprecated: use PreLoginEvent.
+type LoginStartEvent = PreLoginEvent
+
+func NewPreLoginEvent(addr net.Addr, user string, onlineMode bool) *PreLoginEvent {
+	return &PreLoginEvent{RemoteAddr: addr, Username: user, OnlineMode: onlineMode}
 }
 
-func (e *LoginStartEvent) Name() string {
-	return "LoginStartEvent"
+func (e *PreLoginEvent) Name() string {
+	return "PreLoginEvent"
 }
 
-func (e *LoginStartEvent) Reject(declinedReason *component.TextComponent) {
+func (e *PreLoginEvent) Reject(declinedReason *component.TextComponent) {
 	e.Declined = true
 	e.DeclinedReason = declinedReason
 }
+
+func (e *PreLoginEvent) Cancelled() bool {
+	return e.Declined
+}
+
+func (e *PreLoginEvent) SetCancelled(cancelled bool) {
+	e.Declined = cancelled
+}
+
+// GameProfileRequestEvent is fired once the profile of a player is known, from Mojang in online
+// mode or made up offline. Handlers may replace the profile to change the uuid or skin.
+type GameProfileRequestEvent struct {
+	Username   string
+	OnlineMode bool
+	Profile    *auth.GameProfile
+}
+
+func (e *GameProfileRequestEvent) Name() string {
+	return "GameProfileRequestEvent"
+}
+
+// PostLoginEvent is fired when a player logged in, before it is connected to a server.
+type PostLoginEvent struct {
+	Player Player
+}
+
+func (e *PostLoginEvent) Name() string {
+	return "PostLoginEvent"
+}
+
+// PlayerChooseInitialServerEvent is fired to choose the first server of a player. The player is
+// disconnected if InitialServer is nil afterwards.
+type PlayerChooseInitialServerEvent struct {
+	Player        Player
+	InitialServer Server
+}
+
+func (e *PlayerChooseInitialServerEvent) Name() string {
+	return "PlayerChooseInitialServerEvent"
+}
@@ -0,0 +1,14 @@
+package event
+
+import "gopro/core/permission"
+
+// PermissionsSetupEvent is fired at login to set up the permissions of the player. Handlers may
+// replace the provider for this player, the provider of the proxy is the default.
+type PermissionsSetupEvent struct {
+	Subject  permission.Subject
+	Provider permission.Provider
+}
+
+func (e *PermissionsSetupEvent) Name() string {
+	return "PermissionsSetupEvent"
+}
@@ -0,0 +1,28 @@
+package event
+
+import (
+	"github.com/google/uuid"
+	"gopro/core/component"
+	"net"
+)
+
+// Player is the view of a connected player events give to handlers.
+type Player interface {
+	UUID() uuid.UUID
+	Username() string
+	RemoteAddr() net.Addr
+	ProtocolVersion() int
+	SendMessage(message *component.TextComponent) error
+	Disconnect(reason *component.TextComponent)
+}
+
+// Server is a backend server players can be connected to.
+type Server interface {
+	Name() string
+	Addr() net.Addr
+}
+
+// ChannelEndpoint is one end of a plugin message, a Player or a Server.
+type ChannelEndpoint interface {
+	Name() string
+}
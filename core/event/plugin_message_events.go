@@ -0,0 +1,31 @@
+package event
+
+// PluginMessageEvent is fired for a plugin message on a registered channel, sent by a client to
+// its server or by a server to the client. Messages handlers mark handled aren't forwarded.
+type PluginMessageEvent struct {
+	// Source and Target are the player and the server the message is passed between
+	Source  ChannelEndpoint
+	Target  ChannelEndpoint
+	Channel string
+	Data    []byte
+	handled bool
+}
+
+func (e *PluginMessageEvent) Name() string {
+	return "PluginMessageEvent"
+}
+
+// Handled tells whether a handler took care of the message, which then isn't forwarded.
+func (e *PluginMessageEvent) Handled() bool {
+	return e.handled
+}
+
+func (e *PluginMessageEvent) SetHandled(handled bool) {
+	e.handled = handled
+}
+
+// FromClient tells whether the player sent the message to its server.
+func (e *PluginMessageEvent) FromClient() bool {
+	_, ok := e.Source.(Player)
+	return ok
+}
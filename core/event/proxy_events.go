@@ -0,0 +1,22 @@
+package event
+
+// ProxyInitializeEvent is fired once the plugins are loaded, before the proxy accepts connections.
+type ProxyInitializeEvent struct{}
+
+func (e *ProxyInitializeEvent) Name() string {
+	return "ProxyInitializeEvent"
+}
+
+// ProxyShutdownEvent is fired when the proxy shuts down, before the plugins are.
+type ProxyShutdownEvent struct{}
+
+func (e *ProxyShutdownEvent) Name() string {
+	return "ProxyShutdownEvent"
+}
+
+// ProxyReloadEvent is fired after the config was reloaded.
+type ProxyReloadEvent struct{}
+
+func (e *ProxyReloadEvent) Name() string {
+	return "ProxyReloadEvent"
+}
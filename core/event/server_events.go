@@ -0,0 +1,47 @@
+package event
+
+// ServerPreConnectEvent is fired before a player is connected to a server. Handlers may change the
+// target or cancel the connection.
+type ServerPreConnectEvent struct {
+	Cancellation
+	Player Player
+	// Original is the server the connection was asked for
+	Original Server
+	Target   Server
+}
+
+func (e *ServerPreConnectEvent) Name() string {
+	return "ServerPreConnectEvent"
+}
+
+// ServerConnectedEvent is fired when the server accepted the login of the player, before the
+// player is moved over to it. Previous is nil on the first connection.
+type ServerConnectedEvent struct {
+	Player   Player
+	Server   Server
+	Previous Server
+}
+
+func (e *ServerConnectedEvent) Name() string {
+	return "ServerConnectedEvent"
+}
+
+// ServerPostConnectEvent is fired when the player is playing on its new server.
+type ServerPostConnectEvent struct {
+	Player   Player
+	Previous Server
+}
+
+func (e *ServerPostConnectEvent) Name() string {
+	return "ServerPostConnectEvent"
+}
+
+// ServerStatusChangeEvent is fired when the health checker finds a server went down or came back up.
+type ServerStatusChangeEvent struct {
+	Server Server
+	Online bool
+}
+
+func (e *ServerStatusChangeEvent) Name() string {
+	return "ServerStatusChangeEvent"
+}
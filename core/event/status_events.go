@@ -1,41 +1,23 @@
 package event
 
 import (
-	"gopro/core/component"
 	"gopro/core/proto/status"
+	"net"
 )
 
+// ServerStatusRequestEvent is fired when a client asks for the status shown in its server list.
+// Handlers may change the response.
 type ServerStatusRequestEvent struct {
+	RemoteAddr net.Addr
+	// Protocol is the protocol version of the client
+	Protocol int
 	Response *status.Response
 }
 
-func (s ServerStatusRequestEvent) Name() string {
+func (e *ServerStatusRequestEvent) Name() string {
 	return "ServerStatusRequestEvent"
 }
 
-func NewServerStatusRequestEvent() *ServerStatusRequestEvent {
-	return &ServerStatusRequestEvent{
-		Response: &status.Response{
-			Version: status.Version{
-				Name:     "1.2000000.69",
-				Protocol: 47,
-			},
-			Players: status.Players{
-				Max:    0,
-				Online: 1,
-				Sample: []status.SamplePlauer{
-					{
-						"Dream",
-						"ec70bcaf-702f-4bb8-b48d-276fa52a780c",
-					},
-					{
-						"candy_boy",
-						"d89e6017-c301-480a-99d4-0401683766dc",
-					},
-				},
-			},
-			Description: *component.NewTextComponent("meow").WithColor(component.Gold).WithStrikethrough(true).WithExtras(*component.NewTextComponent(" wtf").WithItalic(true).WithColor(component.White)),
-			Favicon:     "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAEAAAABACAYAAACqaXHeAAAACXBIWXMAAAsSAAALEgHS3X78AAAAAXNSR0IArs4c6QAAIABJREFUeF5dmwmYZlV553/n3O3b6quq3umVhoZuMLLDRIkRMGo0ohhnRseYODFqYsYkGkOSZ2aemcQn42R7YpLJZjZNogSFSKISgVJUQBYFZG+2Bhu66eqlqr761nvPveecmffcr5qeNNRT21f3O+c97/J//+//KF7+p7Kfyc4srkjfr3R0FYozidOOt6VSBWAV9HN86QElP4Bo+rWNUD5C4fANg253UArcJAfnQSekzRSlDRGayDXAWFxlGVtwzuEx4amRinEoPCo8Q37oqKCSDwXylv/fPw06Au9RWqHTiMSn+MqRpBGmmpiZRJ/QpA+sHh5cZz47+VcOMVl7hLwFXEEcvSl7s93o/zdZtlcpFUEEpPUbDxWMDQxKPBoi+QB0Bd4FYygvG1XQbqJbHVxvUC8qShDTaO2IEk2mU2wldqkoqbCVwjmF9yUo2XqE9/J9sAy1FSJwVn6NFwso+T+uf+fld2IVR5zGqMTSUS1UpaiocLqikWoqq5hMTN9PxtdF95YfH9/KEdl6sHH6+623levdX4Da7HVSb0RFKBVDaaHvYGJhHFYefu9jBbFHyaoSB6mGNEOZFDcYo/IC3WhBovC6QkWKJEqDp1S2xKUGJ15kEqii6cFawhF72Zh8FgPL3rLa0PJe2qMSoBGj0xRXTMBUJGlKGsWY0YTYZrR1htcWG4GOdXhcXniMMTaelDfmt+X/he+wpLrvYc/gNemXSPU5YmkfxcEplJy0WFc8wMhfVyDuH4mBNFCCtvJ08dvaltbC0KLkdc7jxSiZLFZeJydVn5bKNPH6JspaymMVfiwuLycrz5GN2+nma3+X0wzrSBW0IphtoNa3wlJ8MaabJmxdt4Fu2qJ3aJnFg8vYiSeOI3SmUSmUpcJONKYoUa4qy2Oj/1n9sfs9FX929nes8h8LfiYu52TjCq907WauAuugkkXJAtc8JA8L9bIpif8KqEztijrGmhLXjFHzbUiL2piSS8oSWjFRM8LnDrcsRvN4OaJIoyKNdwYle9dR+F45h/MuGM43HXQymO+iVBWcdTZLSStLV2c0i4jeYo9J34DSYbmNTkx/UFKOFDYv8abE2Pz55PvuR5X+5/kHfZJeKK/1wwIGchYKFUd1gMiJBMNIqPvglXUQSoyWdUjI5qyEg8cnEj3yew9xCllce4qTbCZGlI3Jm4kxopAMg3fJv1RSj4SZuPr0/eV1kmzF07B4ZVHyte4Er4jnUjrzGTaviIyiNQbbH6NLR2uuw1I+JGo0QhKf9Ar8xIEpKTuYufnGryj9xY19n0QzxBpGJfTLkHO85EFJX6oK8ROSkKxfQjEkKdn49GTRdRIUF47By7HI+iVXSLRM7Vhn3GnmCc8MKb72LPmllh/Km8izdP2z8IYSImK02uiSCkJiLhx6LiNpQEunTMYlaZbQDNESk7YaTLRBKY2zivG4CFVMqwjThEYafU7N3HNxMV7qpS7PoXSEFC1vLKcQdrNW9uRkJCmtJShZ3NrvpuVpugkvhojEI+SgJbFpVPjZKRaQr09uXoy59rtTv64dUF4X3kneL4SkRduENJY8oGgqmE8SRnlBmTjxelKV4LTGRC44kLyVsZ7SVSG8S/m55lYV33uBUaVOGBfE1iH/mV6vzrhRVic268KJK8w0O09LUzhZW9thzRji+qGK1AYIS5fTFA92Gn/YoB42IbH60yI4PUK11+pRJEdZu3x45rTKyurXnCf8vCJOMpqtDuP+mEQpWlFMS0LIFxKNaBVjcZQ4IufCAZQorKr3WMSOZpYtKP3AhUarLEkKQ1p5xr0+1SQn6kgJS7B5AcMxvrK1AeqzwCspaXLE5UkXF3f1UqbkJ+L606TOisUvW/QdFeuecuyaSWhlEcPCcnhQsZpL4iQ8vbpY4X8kQ21N65CSBLzmHeFz/f7y+marzahfIB7XbqU0tSaS9UYRJlJU3qO9IvKydk2lKmIJByqGmaU5015Q6TOXGVvqxL+wCkODNwXpTCckjiiNGA9Wcb3+NPGV00qwZoSpW4ao8LWLhhOX0LH47xvUPSXxk5bomOeHz2nxztevZ8/OFo0sppgUjKqcv/6nE7RTT2kV9zxhOKQc9j82UJd18EoMHGKrTqIhNBWYgixpkkVNkiQlTjzjYkIsv48URUgvioQ4RJ7yjki8UmucdhQzkLabC2rHs1eZIwdeTNTIMNdaz/DYMib26GYLOxqGkqFSj9biPlNXD4uo8FoSVCw1o/aEcEICmWO4fciWBxz75pKQV/ad3uZdb9rK5o0dyZI12nOOmVbJ/fuP8Y37lrnyki4HDo257Z4x33vJsrQvxl8RwVkpCD6pIWSdR50l8RHduEkSJwH1FaHC+PC1ltKstYBotPdErpJMFIxjEw0dhW6woNr/cqYp+5Mk3tAgDjkwZdyI0N0GbmUVVoZ1SQuQV/Yo2VhiqgzZXmxc+4OclMO/VJF8zvDG+a1c+55t3HH3sxw4XPDBHz+TLZtmaoildfgQe1aVpaxG3HH/Ijs3N9CR49DRIfc/tsqdDxme7DvMzzThIslH03AQzK90yPTNAIUhR9BmXIdSCEvpJTQ2gCpIvK99QXsKyZKdmCxjQUWPvMqopWGSMCYeDjFqFjs7Q9JMKAcD7NHl2vK2fqiPkjqjiwHC2QsmD3AN/9SEmestP3f5+Vz7gbPZ/9DDLNz1IldctoMzdq4nEoPpiCSOiaIoGEEaIWsrhuMxhbECVRmMJiweH/DEgVXufnjEPaue4QcbqJ0C/YI/ByyR6YzMS4mTPsnhwvMlHMVQNbYIgM5BLB4jkFA7yiQiEg9I/YJqHbrGFCeWEtU7woYKlg4s4ZsJcbuFGQzQOgoI1ssDJbYFxIR6Le4Yoiv875/L2Xi94ePvfh3vvXorvUNP8wefeYQrL17H3j3bSNOYSeFoNzOajZRIPECqhRcvqCiMoShLSlMyKQxLKwOOL494/NkV7nxwzN0St+9roLakJ7FEpFNiCScpVCEkNVGwgJTeKSDTEbpy+KokFVCSKFSWQkuMZRfU7P7Xm/GJQWKXXqI91yB/aTWULV+aGuzEUSgbAausIbiQ5qf4X7L9wJH8UY/ffduV/PxP7MGtHuLrdz/P/mePcvVVZ5FlTW5/cBWXznLZ3i47N9St6xowEi/I8wJTliEkKmsZDMccOb7K4WNDnnmuz92PFzzgHOYnMzizVWOK0BhKKy6pYYo3pLX2NniYJEd5Fyd1UXKAlMNYE2UxtmlJYrWgZr79A6Y6PEhyXxKvb6EGwwBP65CPQt2sW9VTGvGwd8G8GiqP/3Kf1xzYwk1/8y5mxs+y2l/l01/cz4V7u7xi706ePWLJ1u/monPbuN4xSiPt+FqNlMQeujRyY7CVwzlLbspggONLQyozYXm14Oa7JjywZFl+dYx/cxsy2dgpICygHVM3XHFCFsVoqf86DjnAVgU2sjTaTXwH4iRaUJ3bdxjdc0nVTKiaYCXpGUskyUXFVILvpQc4pVkLNX4Kd/29E7Z9fsKfXftDvOmHd1MMlnj24HG+cMvTvOfqc9mwfpZDg/XsPT3DDo6G2i6Lqo9fPssGXB0ChaGaGsCUluXVIcdO9BmM8lAxBiPLrfcM+NazOb33Z6jzm6EsyvrkOeINAbh5h45iImntlRcoROpt4CCkjU86GfGMNHV+QXW+t9u4kU1cklA6iz86IjIOTRJArBXoaAXKCjavMWUdDnUoZn+9yp9eso33XLOX3EiPAF+54zmWV/r8xFsuIM4aJFmHYtKbgkJZaO38kkxrPxAPKClKQ1nakBjL0rE6GLGyOmJlkLO0IiHi6Y8qbrunxz37NP4dc9NcNG2yxCtDw2WJVES01lA5Ve9DOXQW4ZqauJ2QRHZBxY9uMz5PknCiVYU/WhCZEu0iTCh5kk1VYHa8nULhsPwY/1zFvusLbv6NC9mwoRuqwiSv+MebH+cH9qzj4leeThTFU0BXJ6b63KfRXzcLodUtq6qO/6rCWvlcG2B1MGY0Maz0C4YjhykdB14ccd3jI3of6KK2CugSK8qJSNcqIevCdgJtIWXXJ4F+s7pANRPUTIyWlty5BRV9Z5PRrpkEPs9U2OUCbQU+RkIoBTQdRQlZkpBPipAYpXwJlcX1Q36mmuW3P/pKYmnJcHz/8Aq3fOtZ3vGGfXS7XZJECDHplh3OVhRFDZ2TJCaK4wCIxABy6s66kADXEmF/MGR5ZcChYzkHFyfBu4XeyhLFV+7scXfXYt/bQTWFtxDvczUaDfFae5h4l3YqwHaVeBrruriGpkwtsS0XVPK9TQbTSpS3VJMSwe1yQo1IcoCnrAwWcRBPK2uTD1botDJWDxdkn+zzqXedwTU/+kps6TBVzoOPvch3H1vivW9/JXEcB2Ot9occXxrw2IE+y2OBrg1ixpy1LeG8fRtrPBCwlg/GECOZ0vDCS0t87aGIrWf9CLtO340tJzz5xMMceuqbbOwYbrxvwIEfieEHpZuq81RdGeqGLJEKENpp+VaTSG+TxVRJhWo3xIkXVPrIJkPeEuYOP65wKyYkHDmh2WYrLESaFmty5ufmGa4uBwMMvjjh0u9arvutH2Dd1nOpJqus9Jb42j2HSGPFVa/aHQBPbzhm/zOL3HD7kPN/6B1c9qrXMD83w4svvMgtX7mBffPP88bLtxAnstw6NPKiYKW3yt/cXPLun/8jrrjiCrSa4L3BmIK/+8xnefybf0w+6vP5R3IG70zhHPHAqRGElohUjQlCnzLlL4SfSEFIaToNIW8WVPrYJqPzRpJI0rCe4ugEk+ckjYyZZocyNyRZirhjO0sZjAdEVYL7gxG/vHcdv/LTZzMpG6GOj8cTvvytg7z1tduY7c5SOcfD+1/iuoUR/+lnP8Fbrn4bSSykhLTbnqXlVf72L/+K1vDrvPHVW9CB9VGUZcHX7zuM2v4L/OyHPxrgLbaHMyt4V1Kozfzub36YePlWvnrPgPskdD/URs3qaTsiUBsS2XDNWRMLHR/F+FRRNhW+K1WgXFDNJ7eaaNIIIZA4TbdK6PcGTPKcue5GivGErpz4ZIwpKwpb4p/XNH+/z+d/7RVccv5mBoOC/rBgXFTc98gR3nD5TtqtJku9MV+87XmSre/gv338kyErezvBmyPgJwEMHevBr//S+/jPryvYvHEmlDNnS268/QTvvfZfOf2MsymKMS8ceIAdp3Xqk23t5O//7jM8e9cnqErD398y4sgPg39zC2H0JR/W5JQ0wRofRWQC7rSi0BU20+iZBC9QuPvsLhNNkqSsHFFpmfeaYlLR6w/pNufIxwVz7YzSVoFxGUutviHnVfs1n/6NV4ZE1xvkrPZz+sOcVlqyccOGUIcXj/X53G0n+KXfuJ59574qJCnvclS1yLFjL9HtNMlmtvHF6/+aE4//La86f0Mot5Jvbrh9ifdeexO7z9zHyonD3PRPn+Ptb38Ds80E3TyNm/75Zu7952vZOB/xj7f2eDiucD+XBNeu6TRp2xSJ9P9CrvqqZuCkaCQKPdOg2UkX1KaDZxg79EmmEzIXMTy6ghLmRjKnUwGfz2YNTFWF2BwK2/rfh/zeNZt5/as3U7qMsvKhPI3GE+bamm53NhzB4aOr3HRXxa/81ufZtXtvyC2UKyi/ym0L3+K88/Zx2tY93H3vXXz7xo9x1WWbQu8hfOyNC0e4/Jrf5A1vegvODPDK1Iyy/Gvs5E8++QmGBz5NkmhuuavHHav/r4L9YoJqJ4HPDH2GdL9RPbGSCkLaQDUccVsxk6XCOC+oHUf2GLNchbw8GzVYPbIc3idUklCjpbrr4P6TqiQ/ULH7Lwv+/EOn0Z3tULmYRpaGTlWyfbsZMz/XDetcPN7ns7f2+NX/9Q/s3XcO1hT4alWIKQTpJdKdNrfy1Zu/yFPf/C1ee2mdB2LteXD/cb50/yb+7K/+nE3rOziT1xRb2uW++57gM//nI5yxcRXrPPc9MuCrBwzlxxJUN8breMpMK6IkCiOybP0WShUzXnqJVtuzZcsODi2tLqitL+0xrueT2HqapcIt56F5sFI3rcd4T+UVpS3JnaW4y3LZ1wt+5/2nQdQK9LWUF+nujh5fZfP6FjMzdVnq9SfccNuLbD/vXXzkV/87mFW8zcPvQjsRp5wYtPnoh97FNZcc48xdcy9Xgtzw1W8f48Dq2XzoF36e8y+4AOcMX7v1a9zwD3/B3tNOBBpM1rr/wIgbHhwx+XCC2irwNw40WZgVNlL8+g6qOw9xDIM+8WjEhp1nc2w4XlAbn99tolwnUenJl0a0TRRQlABAI+VDypKt6k7Ne8wXSi7fX/Hx92+mojaAbF485fiJPmftWk+jkYXTKkzFo88c5/rbSz7y65/g9a+/HCUGEMASNxgVLT7153/Cwfs/xdWv3cS62VaYE8qz5DWTvODOB47y4HMJ7e5paFVhx0c4e4dipp0GaCwLPL5ScN03+ixeDeoHW9MJUx0CKktItm+jEsLXVSQ6xq+OaG/dzkSrBTX31DbTsK3E5yXF6piWbVBWJU4eHpgbxTAvAkoTFFheb3jtAcf/+OkNjMoWWogNpcnLkqWlAReeuzWMpMJ02HlWhzkPPXmcux7P2HfJj/Hud72VjZvmeOC7j3LDjV8mGd7NlReJBwkK7LBjSxvrHL1+TpbYMKM8sWpY6hWBDpSYlzFXbjxWmrQwOrDccnePe+IS+4EOqi3IcMpGB1jcCL2BsENO8kMak52+kyp2C6r75FaTVe2kHBt0UUGuAuSNvLSSLtRy53UoKaWyFNeNuOJZ+K8/NU9v3CCKhNxQ9EcTBv0h/+6CXUQyPQ6jBdmUCyXy4JE+9z/R5/igEyqEK4fs3mI594w2u7Yo7nl8zBPPuVC/08gzP6PYvjFh9/Y2sixJspI3JOHmxcsGEGNJ7/DiSyNuuC+n/74MdV4bSiFIYpSw1JL6rSOa6aA3r8dIf9IRo/gF1d6/y/iRTqRZyGyM743DADGNG1gnuNwEjl3KiVEVxY0TXvO452PvnOPEICFJM9IYVnpDNszCrh1bQuYV3xQPEHd2gu+tZ1KUrA4mwXWjWJFGOkzaQy8wxfDiZfLREMBSQVF6iqp+jpXwEAMYz0Tafudl5B0ap6WVnBseMaz8bAO1Q+DetPOaNmOCiHXSpLFtG242o0wndJVeULOP7TJqqBIrvH+pKHvj4O6yCfkjiZspug4Lre6ouPQbFb/w9jmOrmiyLKbViFjtjzhvT4tWZ5YklnF3DUsDPhdjTL+pSVAb3FxcOBjICf6vmaCT85Xp+sUYMj6UbC8hINWmKGBiagMvr+RYW7Lcs/zTIcPw2i4qmQ51Tw6upCPV+NGEdMsW1PwMRT4kzf2Cat633uhhnJiiwBtFdKoKY0oCySIlAUpd9EccW/+w4NffNsvY1CKFdjMKFNb5e2bodmdCByh/Uw+MaiusDY/qVlcMMD3VaQeYF8IDTN9wjXyazhtk08EDfM3PTgqCF0jz9NKxMSKFeP6w5ZZRSfXRGRADrOkJAvmS1SxRYeq5ZbuFanu6abSg9NfaRpeNRCjmWMUYGSA6qfziASUuVII6FwQDfN+y81MFH3nzPHkl2FuEDzAcjbj4nFk6nQ6pLGCN8BPjhQRae4IgTjGCGEY2XNo6tuU1axOgaTd78hn1n4rBJBcohmPpPOsQOLQ4CL3C/U8a7p4HPtCp1zklXGoCouYMtHicELqtBL0lodmKFlTjnk0mKVtJKphZ1DArg+CSidBhTqhvoYRdza9rj3/IsvcLhg/86DwTUxOjAmxOrIx49XlzZFkSPuKoriByEOLu9ZLqr611wQNsNTVISLQn+ZIpaTIdQ07Zt9p8NRhcGUhy9aSx4+nnh6H7/Mb3Ch59RQzvnq1ZoTBlFga5Cu+tvBihDKoW0SewviHDlgXV/c5243o2EaQpBGdpKoyts7GQC9JJ1YsXURT4Oy37vlLygR9bh8gJggm848ixEVdeOhsGJ40sIgqlsGZ81k5w7bN4gZy8bKJOlPUJ1wTOVBwlNJnkpMoHj67zqgrJrz8OMUF/MObosmVuJubL3x7x5KUJ/Hg9fKkJhrXwE2NIZ2ADLU4zgXkZwugF1b57k7HLLhH5i8SUGCGAEdm2uJJAYVuHgaRs/w3HvtsK3v+WOUai+JiGwOKJEa+7pM24qA0QT5mgGvPVCTFsVty+kpB6mcAICTtA7tqfpJcxZahktfdIX6KkCohkSVCpD0lz8fiQDaJA8Z4bv9HjictTon/fClXHV5IwanGHF9VLGCnaQPOLvojZlKiRLKhND+0yZc8n1aSgFPmIbEoWeUoCqyGVFGiFf8Cx5wtjPvimLkVZMznSoh5bnvDGS1NWRwmNpqDDWupWl8HpSQehSb1xCYHA3kyJ4VBpQl4QUlrVihwJk/C3p4hUppVAwqi3mtPMkmDwm765xIPnpKifmsGKVKeSF8oz5I9rIUYgchPRzADdMORZUFsePd24gU7yiaEcGZywQk6yfk0uSmcY/rLZIN4wi31gzOl/ushPX5kxM9NkdaTCZl84MuT1lzQoqoR2u54Y1WWr9qLgjdNAFqNIEguJWgZNQrGK6mz6LzSNFiZ5nexCfpzSXeFQg2d7er2cNIlCFbr52yvcM6fwH5yrH7hWg8V7nShZxAUESqbQ0CDUQzNaUFse2WH8KE2kNhejnHK1qGNT6o0MmH16UuLT2baN6vGSDZ98nrddEPGKs2bojaIQp88fGnDZOU2azYTZrlQQFQ7h325e9jgRudpUFtSU4YavTzyVPl3Xrj+QTD+VIJ2aIGvusH7uYJhPw8rxpTuHPPPKGPWTbdIsRStp0y1WJlx1yQiUmjREqpVAV4mAakHNP7rTqFGcmJEh7xdoo0KZcsLcSOIQgsImQY+nhMU9EtH4owHvvECyvWLD+jaNRhw8YN+OhPnZhHWzcRAmysmtgaC1EyyMYjh5OeuvDgyRFklbEhhfofIl+a39rVSStTAJvchUQyVfHz1RMJ7k5Lnlpscril9uM/uKNlmSMZ6UFIV4MzhTUhWmnmm2M5BeoSVMqYzHH9lilHhAv8IOqoAGpeR5QYChoYAkaoTkJAJHbxzcUHDlMcu+7Z4nD3ku2DvLeGLIEs+Fe9t0O3FYaEh8QqxP3Viqkwg4JImFDSr53nLoyIQN6xp0pDy9HAk1fR5LuZNcIoYRA9TDFzHA0wcnYWx235Mlz70uZeaaucD8GKlmhahQpYZKF1MFdBvcv51AS+blQZe7oLKHNho1TBI9hmpYUUkirFHLlBVRZAIkhGOXkZBMi5cs7T9b5T1nKgaTkvl1s6GFXjwx4YoLuyEHyMlJ0Jcy95tKfddOTypAMMA0CZ6aH06FA2vYQR4lrxWO4qQnlY5Hn+lTGcvCYoX5tQ6NjQ2cKbBOB4+S1wqT5asi4BkVZ5A6yETZKuRKvKCyBzaZdNJI3MiSixg6jMVqRUosjYT8F0qKC8k0Uoo4ihjflbP7X0dcvsezPEpYP5dxdGnCZefOkEmizSI6LRXKoXhfXcLqz2u4/lTEt2aE2gGm0HkN/UzPImCKKb5/4UhOUUzo9+Gr45Li2k6QvwmNFwDYlAoT2O3ljWVsFjTOkmyEVpPN6NoAuq8SP3LYkShC67GSSEm0MLBEZE5AhMNIIqlkyCCAyVNel3PxoYJdGz2DiQw9YetpHbZvSEL9ltjetbXB6kgUYxLXdWy/XBnWQFZ9svJcgbXT8n2yaqz1EfUYTUSrnuXlIUpZvvtEyXczj/vlTkBLUVBqCgEa2rHpuEwge4ISNCVIUNw/CQ9bUMl31ptqySU69yhTa4ODeEU0QbYMmoBW3CCT3iDo7KQ0KsphRfm8R13X5wfnHDs3C3KDOGty1vZmOMXlgePCsxLyKiYvBfGJNqiuZ0JShig4pf8JQ2ctr6sh9MlwmJ58KKseen3DeDTm4KLhW4tQvC9FnZnVPKC4bpD5hiMLAE5gcAgBOSEpt1KlhWyJWFDZvdtNeXSY+EIQnwiaynr6HcSNImSQbzJSATxOhzlbWTjKRUO05CkeMXQfmXDF2TI6UwxNwmsunGcwLFka2jDtvfy8Dov1cDiAm0hZmlnE6rg+0WmuPaWBOim/CJlylJfEAZsrllcrXjo+FtEbtz5WsfraFC5KUPMRiN0DnghAYCq31wHR1uSehIYYQdhjJCEuqOSO3aZa6iV1eyUbFClRrQqThkYsqb0jlg8xXRlhTjjsoQq3LBydxTxVset4xUWneyqnOG3LDHu2ZwxHFQ8fGHP2jgbrZjOicEKetdJX09c1aqxFlfU/YYXFmLKkpw7mGFNxxo4ORWkZ9MeMxpbbHiw4tD2G84X0FKG/Rs+Ji6vg/gGki8sHzZBUtSkolzWIzqkjSrFsQamv7DTkw2RNCJVFnZo+kmFiHEQEJJTEoaYp7CjGHbaUL1XYnkWIFF8qqhcrGt8vuWgLIfnt3Nph99Y0uOvBxYLZmZTN8ylZ6um0I546KNNez4b5Zpj2TnW19SATG7RCi8sVx3qWPdubNBsJg8E4GOPOhw2PSF67MEO3NNWsRW9NiTfHQTXipKGKZRiaBRInt5OXY02Kk/w+08ILLKjm3eeb4sjhROCi6PcbyTy2mAQ6TAwgzNCMjKuCOs5S9UQAqbBHKtxImNb61AKGf6EketqwPpyA4oKzW1y8N6OqSoYTG6bA0ou0W5rZmQajiZtqhWrJXH1s9SehuYQjENeNk5il5Twgv+89XfH4wFNdFJN0k8D7F52CeEeDbEsWwiAo57RQbjHS0E+ChnEaFvK1LFbGkJ3Ggmp/5yKTLx5OvBkT6ZioaoS6KXKSZiqnE+NtFdpiSU6mV6APgjtcocaSN8Ti9QQ2MMdSTZZcLY097tgzH3PVhU2amXAA9UELRBUB00wnCaEWMEOocVPUN+39ZZ2Syfc/l/Psi2NeWLYsyaWJvdLO1iVW1OF5p0Rvj2ltSYmbUuoUhSj3N0H/AAAGZ0lEQVRDBbPIPDDkHheuHAWJv3AbYoBGtKDUP86buJslMnpygRmWqWpEM0pJgwLTkasq3PuR+yfVwOKOAy/EuJ4lMjmizZSmSaiH0O9JtIhhxhb/omf9kuLCHQnbNtQbLErHwUXLsZ5jx6aYHZsi1nXrkZggvaW+Z342pdPUPPrshG8+PmKyQeG2aVRXB5lLcBQJ8XaM36CItirS9QlaGFpdt/NhMIIPIspAwAS99ssUHU4tqPhrW02kdeJtic0telKRoWnLZQelEOSrpY9GAI3U+gZMmqT9LoODK3D4KMqZUB3kFtDLbV8Ng0OILCmiZ0qycU2vGlmMKBLkNHNPajwbO5pmKsjN0xvX93xmOxGL3lLsitCbRLAhISGlsj5ll3ii+YTktIRoowo8H4kmlmGogKHAZVpyUY+ELr+kWruWI4aoRCHyyHbDkEQWIqVQF5YmIiuTe1IijxNqS2pzRNyZY+Omszl8rMAe95QHjqCeeYFYbm4I5A3kydTC0/bXrmXkHNRoyhCJ+zVlbC3wsNYZMpqCAglVQWnyIctualQWfLcWoE8BWrg+01FEGyOSTQm6rSATlKdItKhZ65ZeGrvCZVOW2lLWJEGNBl25oNQD63OKJGPkkUt8kdOkU/FhfYZBDoW2EdY1mV93BmMTsWnuNJYPHeXEXQ8Qm5JEJsTS0xaqhp5Tnk8MUI9JprxAgNnTu35rfNlaiVoD+msyujUp3TQ3BlosU6imIhWZWxf0vAq3Z5R4k8DIaQjUN85qkJHLPcIp4qpkTC55oKXlOuOXlPrOhkOobJsSintcEi5OVAKD4iArl1gSmXlSRkwWx6ixhiJi1849DGzOif3Pi+4eL4ySXKuTUxbZtpTHMGQVQnIKgkS3P9XYiYY3JP0pzpUELAFSCSUVuDGhxmpRQ1B7xSJxAz0TE3Ujkhm5aCHeMb22J68RC8lVsuA8J9+UfDpskfe0YgAxTsu7eCb5Q6Xunf8XdPutCAyWEBhVRGXd+FhvAvoLXLpVdYIUZWjpMSNLIAW9I9nUDoSr63tUP8JPqsDBC+srd3UQ+lwqhNw7ChglFOOTQkmR55wzuyXA8EdHx+vQEHtIEyrSfTndhgpunnQidCsKYaGFLZXXBdm+PNIHYWfIjwKyvA45oBCNYEhGtSd4bSAphlHu3qGiW9a9w3U7n8aYGYxGjQgbFYmsBKiWhQdJvPQIAo/D/TqqKApVIhLdQJ4T+xZRY5YyaVC9sEg0McjA1Ql1vnY7TLrKKRcY4jAIGTWxizgrncObCU/Fk6DgCPcNJRElDiUQV3JCYomziGYcY2UmGa7ZraluBfXbIIERY2QS5F6ROxMEoFKllE1xuoREEk/5FX/d5CcVV9PS1279Q0/0PkwVMbJEI7lCKJaS+YCww0JvGWLk/q+oryQsHEZgZ+WJTJtkdjtmyzxxIyLf/zRqqUck+EGGK0Lml9LmndLqCTsiBhDXl2stcsPHl3ihq+quSJiY+oQluck9InnfWNNSSRh8BvhUxxBOlQEBSgkMfy46R9EzCLslMN4lgU9w4fTLF6LDxU9UH6nuqnHXh1tb9dvXf5KmersfjRIWZXENUVERqSrwgjIkCTK2ID7SNKXESI0tPXN6E0l7GyfKgt27RSp/kBMHnybqNLCxCCLkEmMGVVzfAJUSFC6o1iP4WioyvY0miVQImKxNPL8hoMBiZREvV2R9hdKOLJJCLcxVfW85SGGEVhMRlmiCXFXzkdP2Xe4oi06szrHlC9qaX7XvNTdML+5NIeh/6K6Lf7z9c24u+qAqZ7eo7ubUxxPllg6hBOxISQywt76wJLczhHCMpGaYhOJgActj2pu7FJGnalj0rMbqUX21VsuN8g5xFlMWg6mmV5KKxHtMZOWitsKK4dfNwOwsrZl1aGMYPf0M3khsiieUqES8UKHX2FLxymg6wq8ky08FzdJWW0fpRP6uxlh/R3TA/Xb1m/nd00t7/5aBI85+cfvu1lsvv6rfal7qGsU2jj8Xsfx9cIPahUOGcWiXkkmbJBRCYtG9iLkjoiq19MqC6MxNOIZoPcZFMq7u0l6/my27t/P9F5/ArhyrnyXgRSTuPQ99TbRtF+yax87Phw26lw7jH38qGFHNpuiGXIarkakY4eTttADzKpxojteYBCuXv6tV5fwBvehuqW6tHuKb9NbaDvn8fwGwo9zXxsX2kAAAAABJRU5ErkJggg==",
-		},
-	}
+func NewServerStatusRequestEvent(remoteAddr net.Addr, protocol int, response *status.Response) *ServerStatusRequestEvent {
+	return &ServerStatusRequestEvent{RemoteAddr: remoteAddr, Protocol: protocol, Response: response}
 }
@@ -2,6 +2,8 @@ package core
 
 import (
 	"github.com/rs/zerolog"
+	"gopro/core/component"
+	"gopro/core/event"
 	"gopro/core/proto"
 	"gopro/core/proto/packets"
 )
@@ -17,29 +19,88 @@ func newHandshakeHandler(deps *HandlerDependency, conn *Conn) *handshakeHandler
 }
 
 func (h *handshakeHandler) Handle(packet *proto.Packet) {
-	handshakePacket, err := packets.NewHandshake(packet)
+	var handshakePacket packets.Handshake
+	err := h.conn.ReadPacket(packet, &handshakePacket)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("failed to read handshake packet")
+		h.conn.Close()
 		return
 	}
 
 	nextState := byte(handshakePacket.NextState)
 
-	h.conn.ProtocolVersion = byte(handshakePacket.Protocol)
+	h.conn.ProtocolVersion = int(handshakePacket.Protocol)
+	h.conn.VirtualHost = virtualHost(string(handshakePacket.ServerAddress))
+
+	e := &event.ConnectionHandshakeEvent{
+		RemoteAddr:    h.conn.Conn.RemoteAddr(),
+		Protocol:      h.conn.ProtocolVersion,
+		ServerAddress: string(handshakePacket.ServerAddress),
+		ServerPort:    uint16(handshakePacket.ServerPort),
+		NextState:     nextState,
+	}
+	h.deps.EventBus.Fire(e)
+	if e.Cancelled() {
+		h.logger.Debug().Msg("handshake cancelled")
+		h.conn.Close()
+		return
+	}
+
+	if !h.allow(nextState) {
+		return
+	}
 
 	var handler PacketHandler
 	switch nextState {
-	case 1:
+	case proto.Status:
 		handler = newsStatusHandler(h.deps, h.conn)
-	case 2:
+	case proto.Login:
 		handler = newLoginHandler(h.deps, h.conn)
 	default:
 		{
 			h.logger.Error().Int("intent", int(nextState)).Msg("invalid handshake intent")
 			h.conn.Close()
+			return
 		}
 	}
 
 	h.conn.SwitchState(nextState)
 	h.conn.SwitchPacketHandler(handler)
 }
+
+// allow applies the rate limits of the listener to the status ping or login the client intends,
+// closing status pings over them and disconnecting logins.
+func (h *handshakeHandler) allow(intent byte) bool {
+	listener := h.conn.listener
+	config := listener.RateLimit()
+	addr := h.conn.Conn.RemoteAddr()
+
+	switch intent {
+	case proto.Status:
+		if !listener.limiter.allow(config, attemptStatus, addr) {
+			h.logger.Debug().Msg("Too many status pings, closing connection")
+			h.conn.Close()
+			return false
+		}
+	case proto.Login:
+		reason := ""
+		if !listener.limiter.allow(config, attemptLogin, addr) {
+			reason = "You are connecting too often, try again later."
+		} else if listener.limiter.throttle(config, addr) {
+			reason = "You are logging in too fast, wait a few seconds before reconnecting."
+		}
+
+		if reason != "" {
+			// floods would fill the log with the disconnects of Conn.Disconnect
+			h.logger.Debug().Str("reason", reason).Msg("Rate limited login")
+			h.conn.SwitchState(proto.Login)
+			if err := h.conn.WritePacket(packets.NewDisconnect(component.NewTextComponent(reason))); err != nil {
+				h.logger.Debug().Err(err).Str("packet", "disconnect").Msg("Error while sending packet")
+			}
+			h.conn.Close()
+			return false
+		}
+	}
+
+	return true
+}
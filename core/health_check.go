@@ -0,0 +1,153 @@
+package core
+
+import (
+	"gopro/core/event"
+	"gopro/core/proto"
+	"sync"
+	"time"
+)
+
+// proxyTaskOwner owns the tasks the proxy schedules for itself.
+const proxyTaskOwner = "gopro"
+
+// ServerHealth is what the last status ping found out about a server.
+type ServerHealth struct {
+	Online bool
+	// Latency is the round trip time of the ping
+	Latency    time.Duration
+	Players    int
+	MaxPlayers int
+	Version    string
+	Protocol   int
+	Checked    time.Time
+	// Err is why the server is down
+	Err error
+}
+
+// healthChecker pings the servers periodically, keeping their health by name.
+type healthChecker struct {
+	proxy *Proxy
+
+	mu     sync.RWMutex
+	health map[string]ServerHealth
+	task   *Task
+}
+
+func newHealthChecker(proxy *Proxy) *healthChecker {
+	return &healthChecker{proxy: proxy, health: make(map[string]ServerHealth)}
+}
+
+// start schedules the checks with the interval of the config, replacing the checks scheduled
+// before. Checks don't run if the config disables them.
+func (c *healthChecker) start() {
+	config := c.proxy.Config().HealthCheck
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.task != nil {
+		c.task.Cancel()
+		c.task = nil
+	}
+
+	if !config.Enabled {
+		c.health = make(map[string]ServerHealth)
+		return
+	}
+
+	interval := time.Duration(config.Interval) * time.Millisecond
+	task, err := c.proxy.scheduler.RunRepeating(proxyTaskOwner, 0, interval, c.checkAll)
+	if err != nil {
+		c.proxy.logger.Error().Err(err).Msg("Failed to schedule health checks")
+		return
+	}
+	c.task = task
+}
+
+// checkAll pings the servers side by side, firing ServerStatusChangeEvent for the servers that
+// went down or came back up.
+func (c *healthChecker) checkAll() {
+	timeout := time.Duration(c.proxy.Config().HealthCheck.Timeout) * time.Millisecond
+	servers := c.proxy.Servers()
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server *ServerInfo) {
+			defer wg.Done()
+			c.check(server, timeout)
+		}(server)
+	}
+	wg.Wait()
+
+	// servers that are no longer registered are forgotten
+	registered := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		registered[server.Name()] = true
+	}
+
+	c.mu.Lock()
+	for name := range c.health {
+		if !registered[name] {
+			delete(c.health, name)
+		}
+	}
+	c.mu.Unlock()
+}
+
+func (c *healthChecker) check(server *ServerInfo, timeout time.Duration) {
+	health := ServerHealth{Checked: time.Now()}
+
+	response, latency, err := pingServer(server, proto.MaximumProtocol, timeout, c.proxy.serverProxyHeader(nil, server))
+	if err != nil {
+		health.Err = err
+	} else {
+		health.Online = true
+		health.Latency = latency
+		health.Players = response.Players.Online
+		health.MaxPlayers = response.Players.Max
+		health.Version = response.Version.Name
+		health.Protocol = response.Version.Protocol
+	}
+
+	c.mu.Lock()
+	previous, known := c.health[server.Name()]
+	c.health[server.Name()] = health
+	c.mu.Unlock()
+
+	// servers count as online until the first check finds them down
+	wasOnline := !known || previous.Online
+	if wasOnline == health.Online {
+		return
+	}
+
+	logger := c.proxy.logger.With().Str("server", server.Name()).Logger()
+	if health.Online {
+		logger.Info().Dur("latency", latency).Msg("Server is up")
+	} else {
+		logger.Warn().Err(err).Msg("Server is down")
+	}
+
+	c.proxy.eventBus.Fire(&event.ServerStatusChangeEvent{Server: server, Online: health.Online})
+}
+
+func (c *healthChecker) get(server *ServerInfo) (ServerHealth, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	health, ok := c.health[server.Name()]
+	return health, ok
+}
+
+// ServerHealth is what the health checker last found out about the server. It returns false
+// until the server was checked, and while the config disables health checks.
+func (p *Proxy) ServerHealth(server *ServerInfo) (ServerHealth, bool) {
+	return p.health.get(server)
+}
+
+// ServerAvailable tells whether the server may take players, which it may unless the health
+// checker found it down.
+func (p *Proxy) ServerAvailable(server *ServerInfo) bool {
+	health, ok := p.health.get(server)
+	return !ok || health.Online
+}
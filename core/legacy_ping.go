@@ -0,0 +1,166 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"gopro/core/component"
+	"gopro/core/event"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Bytes starting the server list pings of clients before 1.7.
+const (
+	legacyPing = 0xFE
+	// legacyPingPayload follows legacyPing from 1.4 on
+	legacyPingPayload = 0x01
+	// legacyPluginMessage carries the MC|PingHost message 1.6 clients send after the payload
+	legacyPluginMessage = 0xFA
+	legacyKick          = 0xFF
+)
+
+// legacyPingTimeout is how long reading the rest of a legacy ping may take.
+const legacyPingTimeout = 5 * time.Second
+
+// Formats of legacy pings.
+const (
+	// legacyFormatBeta is the bare 0xFE of beta 1.8 to 1.3, answered with motd§online§max
+	legacyFormatBeta = iota
+	// legacyFormat1_4 is the 0xFE 0x01 of 1.4 and 1.5, and of 1.6 followed by MC|PingHost
+	legacyFormat1_4
+)
+
+// isLegacyPing tells whether the connection starts with a legacy ping instead of a handshake.
+// Handshakes start with their length, which can't start with 0xFE for any handshake that fits the
+// limits of its fields.
+func (c *Conn) isLegacyPing() bool {
+	first, err := c.reader.Peek(1)
+	return err == nil && first[0] == legacyPing
+}
+
+// handleLegacyPing answers the server list ping of a client before 1.7 with the status of the
+// proxy, firing ServerStatusRequestEvent, and closes the connection.
+func (p *Proxy) handleLegacyPing(conn *Conn) {
+	defer conn.Close()
+
+	_ = conn.Conn.SetReadDeadline(time.Now().Add(legacyPingTimeout))
+	protocol, format, err := readLegacyPing(conn)
+	if err != nil {
+		conn.Logger.Debug().Err(err).Msg("Failed to read legacy ping")
+		return
+	}
+	conn.Logger.Debug().Int("protocol", protocol).Msg("Handling legacy ping")
+
+	response := p.statusResponse(conn.listener, protocol)
+	e := event.NewServerStatusRequestEvent(conn.Conn.RemoteAddr(), protocol, response)
+	p.eventBus.Fire(e)
+
+	var reply string
+	if format == legacyFormatBeta {
+		// the fields are separated by §, so the MOTD can't have formatting
+		motd := strings.ReplaceAll(component.PlainText(&e.Response.Description, nil), "§", "")
+		reply = strings.Join([]string{motd, strconv.Itoa(e.Response.Players.Online), strconv.Itoa(e.Response.Players.Max)}, "§")
+	} else {
+		reply = strings.Join([]string{
+			"§1",
+			strconv.Itoa(e.Response.Version.Protocol),
+			e.Response.Version.Name,
+			component.SerializeLegacy(&e.Response.Description, '§'),
+			strconv.Itoa(e.Response.Players.Online),
+			strconv.Itoa(e.Response.Players.Max),
+		}, "\x00")
+	}
+
+	if _, err := conn.Conn.Write(legacyKickPacket(reply)); err != nil {
+		conn.Logger.Debug().Err(err).Msg("Failed to answer legacy ping")
+	}
+}
+
+// readLegacyPing reads the legacy ping, returning the protocol of the client, -1 if it didn't
+// tell, and the format to answer with.
+func readLegacyPing(conn *Conn) (int, int, error) {
+	if _, err := conn.reader.ReadByte(); err != nil {
+		return 0, 0, err
+	}
+
+	// like the vanilla server, the ping is of beta clients if nothing arrived with the 0xFE
+	if conn.reader.Buffered() == 0 {
+		return -1, legacyFormatBeta, nil
+	}
+
+	payload, err := conn.reader.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	if payload != legacyPingPayload {
+		return 0, 0, errors.New("invalid legacy ping payload")
+	}
+
+	// 1.4 and 1.5 clients stop here, 1.6 clients send MC|PingHost
+	if conn.reader.Buffered() == 0 {
+		return -1, legacyFormat1_4, nil
+	}
+
+	id, err := conn.reader.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	if id != legacyPluginMessage {
+		return 0, 0, errors.New("legacy ping isn't followed by a plugin message")
+	}
+
+	channel, err := readLegacyString(conn.reader)
+	if err != nil {
+		return 0, 0, err
+	}
+	if channel != "MC|PingHost" {
+		return 0, 0, errors.New("legacy ping plugin message isn't MC|PingHost")
+	}
+
+	var length uint16
+	if err := binary.Read(conn.reader, binary.BigEndian, &length); err != nil {
+		return 0, 0, err
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(conn.reader, data); err != nil {
+		return 0, 0, err
+	}
+	// the data starts with the protocol, followed by the host and port the client connected to
+	if len(data) == 0 {
+		return 0, 0, errors.New("empty MC|PingHost message")
+	}
+
+	return int(data[0]), legacyFormat1_4, nil
+}
+
+// readLegacyString reads a UTF-16BE string prefixed with its length in characters.
+func readLegacyString(r io.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+
+	chars := make([]uint16, length)
+	if err := binary.Read(r, binary.BigEndian, chars); err != nil {
+		return "", err
+	}
+
+	return string(utf16.Decode(chars)), nil
+}
+
+// legacyKickPacket is the kick packet legacy pings are answered with, holding the reply.
+func legacyKickPacket(reply string) []byte {
+	chars := utf16.Encode([]rune(reply))
+
+	packet := make([]byte, 3, 3+2*len(chars))
+	packet[0] = legacyKick
+	binary.BigEndian.PutUint16(packet[1:], uint16(len(chars)))
+	for _, char := range chars {
+		packet = binary.BigEndian.AppendUint16(packet, char)
+	}
+
+	return packet
+}
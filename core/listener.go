@@ -0,0 +1,230 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Listener is an address the proxy accepts clients on. Settings a listener leaves unset are the
+// ones of the config.
+type Listener struct {
+	proxy    *Proxy
+	listener net.Listener
+	limiter  *rateLimiter
+
+	mu     sync.RWMutex
+	config ListenerConfig
+	// trusted are the parsed trusted sources of ProxyProtocol
+	trusted []*net.IPNet
+}
+
+// configure sets the config of the listener, parsing the trusted sources of the PROXY protocol
+// settings it ends up with once instead of on every connection.
+func (l *Listener) configure(config ListenerConfig, proxyConfig *Config) {
+	proxyProtocol := proxyConfig.ProxyProtocol
+	if config.ProxyProtocol != nil {
+		proxyProtocol = *config.ProxyProtocol
+	}
+	// the networks were validated with the config
+	trusted, _ := parseTrusted(proxyProtocol.Trusted)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config = config
+	l.trusted = trusted
+}
+
+// trustedSources are the networks PROXY protocol headers are accepted from.
+func (l *Listener) trustedSources() []*net.IPNet {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.trusted
+}
+
+// Name identifies the listener, it is the bind address unless the config names it.
+func (l *Listener) Name() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.config.Name
+}
+
+// Addr is the address the listener accepts clients on.
+func (l *Listener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Config is the config of the listener as given, its proxy_protocol and rate_limit blocks
+// completed with the settings of the proxy config.
+func (l *Listener) Config() ListenerConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.config
+}
+
+// OnlineMode tells whether clients of the listener are authenticated with Mojang.
+func (l *Listener) OnlineMode() bool {
+	if online := l.Config().OnlineMode; online != nil {
+		return *online
+	}
+
+	return l.proxy.Config().OnlineMode
+}
+
+// MOTD is the MOTD markup clients of the listener see in the server list.
+func (l *Listener) MOTD() string {
+	if motd := l.Config().MOTD; motd != "" {
+		return motd
+	}
+
+	return l.proxy.Config().Status.MOTD
+}
+
+// ProxyProtocol is how the listener reads PROXY protocol headers.
+func (l *Listener) ProxyProtocol() ProxyProtocolConfig {
+	if config := l.Config().ProxyProtocol; config != nil {
+		return *config
+	}
+
+	return l.proxy.Config().ProxyProtocol
+}
+
+// RateLimit limits how often clients of the listener may connect.
+func (l *Listener) RateLimit() RateLimitConfig {
+	if config := l.Config().RateLimit; config != nil {
+		return *config
+	}
+
+	return l.proxy.Config().RateLimit
+}
+
+// ForcedHost lists the servers or groups players connecting with the host join first, nil if
+// the host isn't forced.
+func (l *Listener) ForcedHost(host string) []string {
+	hosts := l.Config().ForcedHosts
+	if hosts == nil {
+		hosts = l.proxy.Config().ForcedHosts
+	}
+
+	for forced, servers := range hosts {
+		if strings.EqualFold(forced, host) {
+			return servers
+		}
+	}
+
+	return nil
+}
+
+// serve accepts clients until the listener is closed.
+func (l *Listener) serve() {
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			l.proxy.logger.Info().Err(err).Str("listener", l.Name()).Msg("Error accepting connection")
+			continue
+		}
+
+		// connections over the cap are closed before they cost a goroutine
+		if !l.proxy.acquireConnection() {
+			l.proxy.logger.Debug().Str("listener", l.Name()).Str("address", conn.RemoteAddr().String()).Msg("Too many connections, closing connection")
+			_ = conn.Close()
+			continue
+		}
+
+		go func() {
+			defer l.proxy.releaseConnection()
+			l.proxy.handleConnection(l, conn)
+		}()
+	}
+}
+
+// listen binds the listeners of the config and accepts clients on them until the proxy shuts
+// down. No listener accepts clients if one can't be bound.
+func (p *Proxy) listen() error {
+	proxyConfig := p.Config()
+
+	var listeners []*Listener
+	for _, config := range proxyConfig.listeners() {
+		bound, err := net.Listen("tcp", config.Bind)
+		if err != nil {
+			for _, listener := range listeners {
+				_ = listener.listener.Close()
+			}
+			return err
+		}
+
+		listener := &Listener{proxy: p, listener: bound, limiter: newRateLimiter()}
+		listener.configure(config, proxyConfig)
+		listeners = append(listeners, listener)
+	}
+
+	p.mu.Lock()
+	p.listeners = listeners
+	p.mu.Unlock()
+
+	// the interval is a positive constant
+	_, _ = p.scheduler.RunRepeating(proxyTaskOwner, rateLimitCleanupInterval, rateLimitCleanupInterval, func() {
+		for _, listener := range listeners {
+			listener.limiter.cleanup(listener.RateLimit())
+		}
+	})
+
+	var wg sync.WaitGroup
+	for _, listener := range listeners {
+		p.logger.Info().Str("listener", listener.Name()).Msgf("Listening on %s", listener.Addr())
+
+		wg.Add(1)
+		go func(listener *Listener) {
+			defer wg.Done()
+			listener.serve()
+		}(listener)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// Listeners returns the listeners the proxy accepts clients on, in the order of the config.
+func (p *Proxy) Listeners() []*Listener {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return append([]*Listener(nil), p.listeners...)
+}
+
+// reloadListeners gives the listeners the settings of the config. Listeners can't be added,
+// removed or bound to another address without a restart.
+func (p *Proxy) reloadListeners(config *Config) {
+	listeners := p.Listeners()
+	configs := config.listeners()
+
+	for i, listener := range listeners {
+		if i >= len(configs) || configs[i].Bind != listener.Config().Bind {
+			p.logger.Warn().Str("listener", listener.Name()).Msg("Changes of listener binds apply once the proxy restarts")
+			// it may still use the trusted sources of the config
+			listener.configure(listener.Config(), config)
+			continue
+		}
+
+		listener.configure(configs[i], config)
+	}
+
+	if len(configs) > len(listeners) {
+		p.logger.Warn().Msg("New listeners start once the proxy restarts")
+	}
+}
+
+// virtualHost is the host the client connected with, as the handshake gives it. Forge clients
+// append markers after a null byte and some clients keep the trailing dot of the domain.
+func virtualHost(address string) string {
+	host, _, _ := strings.Cut(address, "\x00")
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -2,23 +2,28 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha1"
-	"encoding/hex"
-	"fmt"
+	"errors"
 	"github.com/rs/zerolog"
 	"gopro/core/component"
+	"gopro/core/event"
 	"gopro/core/proto"
+	"gopro/core/proto/auth"
 	"gopro/core/proto/encoding"
 	"gopro/core/proto/encryption"
 	"gopro/core/proto/packets"
-	"io"
-	"net/http"
-	"os"
-	"strings"
+	"time"
 )
 
+// loginEventTimeout is how long a connection waits for the handlers of a login event.
+const loginEventTimeout = 20 * time.Second
+
+// loginTimeout is how long a client may take from connecting to logging in, so idle connections
+// can't hold the cap of open connections.
+const loginTimeout = 30 * time.Second
+
 type loginHandler struct {
 	deps   *HandlerDependency
 	conn   *Conn
@@ -47,31 +52,35 @@ func (h *loginHandler) Handle(packet *proto.Packet) {
 }
 
 func (h *loginHandler) disconnect(reason *component.TextComponent) {
-	packet, err := packets.NewDisconnect(reason)
-	if err != nil {
-		h.logger.Error().Err(err).Str("packet", "disconnect").Msg("Error while initializing packet, closing connection")
-		h.conn.Close()
-		return
-	}
-	toSend, err := proto.Write(0x00, &packet.Reason)
-	if err != nil {
-		h.logger.Error().Err(err).Str("packet", "disconnect").Msg("Error while writing packet, closing connection")
-		h.conn.Close()
-		return
-	}
+	h.conn.Disconnect(reason)
+}
 
-	err = h.conn.SendPacket(toSend)
+// fire fires a login event, disconnecting the connection if its handlers don't complete in time.
+func (h *loginHandler) fire(e event.Event) bool {
+	// handlers may do slow lookups, only this connection waits for them
+	ctx, cancel := context.WithTimeout(context.Background(), loginEventTimeout)
+	defer cancel()
 
-	if err != nil {
-		h.logger.Error().Err(err).Str("packet", "disconnect").Msg("Error while sending packet, closing connection")
-		h.conn.Close()
-		return
+	if err := h.deps.EventBus.FireAsync(e).Await(ctx); err != nil {
+		h.logger.Warn().Err(err).Str("event", e.Name()).Msg("Login event didn't complete in time")
+		h.disconnect(component.NewTextComponent("Login timed out"))
+		return false
 	}
+
+	return true
 }
 
 func (h *loginHandler) handleLoginStart(packet *proto.Packet) {
 	h.logger.Debug().Msg("Handling Login Start")
-	ls, err := packets.NewLoginStart(packet)
+
+	// the login start of other versions may not even be readable
+	if !proto.Supported(h.conn.ProtocolVersion) {
+		h.disconnect(component.NewTextComponent("Unsupported version, please join with " + proto.SupportedVersions()))
+		return
+	}
+
+	var ls packets.LoginStart
+	err := h.conn.ReadPacket(packet, &ls)
 	if err != nil {
 		h.logger.Error().Err(err).Str("packet", "start").Msg("Error while reading packet, closing connection")
 		h.conn.Close()
@@ -80,6 +89,25 @@ func (h *loginHandler) handleLoginStart(packet *proto.Packet) {
 
 	h.username = string(ls.Name)
 
+	e := event.NewPreLoginEvent(h.conn.Conn.RemoteAddr(), h.username, h.conn.listener.OnlineMode())
+	if !h.fire(e) {
+		return
+	}
+
+	if e.Declined {
+		reason := e.DeclinedReason
+		if reason == nil {
+			reason = component.NewTextComponent("You are not allowed to join")
+		}
+		h.disconnect(reason)
+		return
+	}
+
+	if !e.OnlineMode {
+		h.finishLogin(auth.OfflineProfile(h.username), false)
+		return
+	}
+
 	h.writeEncryptionRequest()
 }
 
@@ -94,17 +122,7 @@ func (h *loginHandler) writeEncryptionRequest() {
 
 	h.token = token
 
-	packet := packets.NewEncryptionRequest(h.deps.Keypair.Public, token)
-	toSend, err := proto.Write(0x01, &packet.ServerId, &packet.PublicKey, &packet.VerifyToken)
-	if err != nil {
-		h.logger.Error().Err(err).Str("packet", "encryption_request").Msg("Error while writing packet, closing connection")
-		h.conn.Close()
-		return
-	}
-
-	//TODO: figure out whether to include the length of the public key inside the pubkey object instead of getting the len every time
-
-	err = h.conn.SendPacket(toSend)
+	err = h.conn.WritePacket(packets.NewEncryptionRequest(h.deps.Keypair.Public, token))
 	if err != nil {
 		h.logger.Error().Err(err).Str("packet", "encryption_request").Msg("Error while sending packet, closing connection")
 		h.conn.Close()
@@ -125,10 +143,12 @@ func (h *loginHandler) generateVerifyToken() ([]byte, error) {
 }
 
 func (h *loginHandler) handleEncryptionResponse(packet *proto.Packet) {
-	es, err := packets.NewEncryptionResponse(packet)
+	var es packets.EncryptionResponse
+	err := h.conn.ReadPacket(packet, &es)
 	if err != nil {
 		h.logger.Error().Err(err).Str("packet", "encryption_response").Msg("error while reading packet, closing connection")
 		h.conn.Close()
+		return
 	}
 
 	h.decrypt(&es.SharedSecret)
@@ -142,71 +162,146 @@ func (h *loginHandler) handleEncryptionResponse(packet *proto.Packet) {
 		return
 	}
 
-	//change the encryption state
-	h.conn.encryptedState = encryption.SharedKey
-	h.conn.sharedSecret = es.SharedSecret
+	if err := h.conn.StartEncrypting(es.SharedSecret); err != nil {
+		h.logger.Error().Err(err).Msg("error starting encryption, closing connection")
+		h.conn.Close()
+		return
+	}
 
 	//release the memory
 	h.token = nil
 
-	hash := h.makeHash()
-	res, err := http.Get(url + "username=" + h.username + "&serverId=" + hash)
-	fmt.Println(err)
-	fmt.Println(res.StatusCode)
-	resBody, err := io.ReadAll(res.Body)
+	result, err := auth.NewAuthenticator(h.username, es.SharedSecret, h.deps.Keypair.Public).Authenticate()
 	if err != nil {
-		fmt.Printf("client: could not read response body: %s\n", err)
-		os.Exit(1)
+		h.logger.Error().Err(err).Msg("error while authenticating, closing connection")
+		h.conn.Close()
+		return
 	}
-	fmt.Printf("client: response body: %s\n", resBody)
+
+	if result.Result != auth.Success {
+		h.logger.Debug().Msg("authentication failed")
+		h.disconnect(component.NewTextComponent("Failed to verify username!"))
+		return
+	}
+
+	h.logger.Debug().Stringer("uuid", result.ID).Msg("authenticated")
+	h.finishLogin(&result.GameProfile, true)
 }
 
-func (h *loginHandler) decrypt(ba *encoding.ByteArray) []byte {
-	decrypted, err := rsa.DecryptPKCS1v15(rand.Reader, h.deps.Keypair.Private, *ba)
+// finishLogin logs the player in with the profile and connects it to its first server.
+func (h *loginHandler) finishLogin(profile *auth.GameProfile, onlineMode bool) {
+	profileEvent := &event.GameProfileRequestEvent{Username: h.username, OnlineMode: onlineMode, Profile: profile}
+	if !h.fire(profileEvent) {
+		return
+	}
+	profile = profileEvent.Profile
+
+	proxy := h.deps.Proxy
+	if threshold := proxy.Config().CompressionThreshold; threshold >= 0 {
+		err := h.conn.WritePacket(&packets.SetCompression{Threshold: encoding.Varint(threshold)})
+		if err != nil {
+			h.logger.Error().Err(err).Str("packet", "set_compression").Msg("Error while sending packet, closing connection")
+			h.conn.Close()
+			return
+		}
+		h.conn.Threshold = threshold
+	}
+
+	player := newPlayer(proxy, h.conn, profile, onlineMode)
+	player.setupPermissions()
+	if !proxy.registerPlayer(player) {
+		h.disconnect(component.NewTextComponent("You are already connected to this proxy"))
+		return
+	}
+	h.conn.player = player
+	// players may stay as long as they like
+	_ = h.conn.Conn.SetReadDeadline(time.Time{})
+
+	err := h.conn.WritePacket(&packets.LoginSuccess{
+		UUID:       encoding.UUID(profile.ID),
+		Username:   encoding.String(profile.Name),
+		Properties: toPacketProperties(profile.Properties),
+	})
 	if err != nil {
-		h.logger.Error().Err(err).Msg("error decrypting encryption response data")
+		h.logger.Error().Err(err).Str("packet", "login_success").Msg("Error while sending packet, closing connection")
 		h.conn.Close()
-		return nil
+		return
 	}
 
-	*ba = decrypted
+	// from 1.20.2 on the client acknowledges the login before leaving the login state
+	if h.conn.ProtocolVersion < proto.ConfigurationProtocol {
+		h.conn.SwitchState(proto.Play)
+	}
+	h.conn.SwitchPacketHandler(newPlayHandler(h.deps, h.conn, player))
 
-	return decrypted
+	player.logger.Info().Stringer("uuid", profile.ID).Msg("Player logged in")
+
+	if !h.fire(&event.PostLoginEvent{Player: player}) {
+		return
+	}
+
+	h.connectInitialServer(player)
 }
 
-func (h *loginHandler) makeHash() string {
-	sha := sha1.New()
+func (h *loginHandler) connectInitialServer(player *Player) {
+	proxy := h.deps.Proxy
 
-	sha.Write(h.conn.sharedSecret)
-	sha.Write(h.deps.Keypair.Public)
-	hash := sha.Sum(nil)
+	e := &event.PlayerChooseInitialServerEvent{Player: player, InitialServer: asEventServer(proxy.initialServer(player, nil))}
+	proxy.eventBus.Fire(e)
 
-	negative := (hash[0] & 0x80) == 0x80
-	if negative {
-		hash = twosComplement(hash)
+	if e.InitialServer == nil {
+		h.disconnect(component.NewTextComponent("No server is available"))
+		return
 	}
 
-	// Trim away zeroes
-	res := strings.TrimLeft(hex.EncodeToString(hash), "0")
-	if negative {
-		res = "-" + res
-	}
+	// servers that refuse the player are followed by the next ones of the forced host and try list
+	server := proxy.resolveServer(e.InitialServer)
+	var tried []*ServerInfo
+	for {
+		err := player.Connect(server)
+		if err == nil {
+			return
+		}
+		player.logger.Info().Err(err).Str("server", server.Name()).Msg("Failed to connect to server")
 
-	return res
+		tried = append(tried, server)
+		if next := proxy.initialServer(player, tried); next != nil {
+			server = next
+			continue
+		}
+
+		var disconnected *DisconnectedError
+		if errors.As(err, &disconnected) {
+			h.disconnect(disconnected.Reason)
+		} else {
+			h.disconnect(component.NewTextComponent("Could not connect to " + server.Name()))
+		}
+		return
+	}
 }
 
-func twosComplement(p []byte) []byte {
-	carry := true
-	for i := len(p) - 1; i >= 0; i-- {
-		p[i] = ^p[i]
-		if carry {
-			carry = p[i] == 0xff
-			p[i]++
+func toPacketProperties(properties []auth.Properties) []packets.Property {
+	converted := make([]packets.Property, len(properties))
+	for i, property := range properties {
+		converted[i] = packets.Property{Name: encoding.String(property.Name), Value: encoding.String(property.Value)}
+		if property.Signature != "" {
+			signature := encoding.String(property.Signature)
+			converted[i].Signature = &signature
 		}
 	}
-	return p
+
+	return converted
 }
 
-//func (h *loginHandler) auth() bool {
-//
-//}
+func (h *loginHandler) decrypt(ba *encoding.ByteArray) []byte {
+	decrypted, err := rsa.DecryptPKCS1v15(rand.Reader, h.deps.Keypair.Private, *ba)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("error decrypting encryption response data")
+		h.conn.Close()
+		return nil
+	}
+
+	*ba = decrypted
+
+	return decrypted
+}
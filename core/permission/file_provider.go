@@ -0,0 +1,263 @@
+package permission
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DefaultGroup is the group every user is in.
+const DefaultGroup = "default"
+
+// Wildcard grants or denies every permission, "gopro.*" every permission starting with "gopro.".
+const Wildcard = "*"
+
+// FileProvider reads groups and users from a JSON file:
+//
+//	{
+//	  "groups": {
+//	    "default": {"permissions": {"gopro.command.server": true}},
+//	    "staff": {"inherits": ["default"], "permissions": {"gopro.command.*": true}, "servers": {"lobby": {"gopro.command.send": false}}}
+//	  },
+//	  "users": {
+//	    "069a79f4-44e9-4726-a5be-fca90e38aaf5": {"groups": ["staff"], "permissions": {"gopro.command.end": true}}
+//	  }
+//	}
+//
+// Users are found by uuid. Users listed by name only match Authenticated subjects in offline mode,
+// as names of online players change hands, and are logged when the file is read. The user's own permissions come before the
+// ones of its groups, which come before the ones they inherit, and DefaultGroup comes last. The
+// first of these that sets a permission decides it. Within each, the permissions of the server
+// the subject is on come first, and an exact permission comes before the wildcards that cover it,
+// the closest wildcard first.
+type FileProvider struct {
+	path   string
+	logger zerolog.Logger
+
+	mu   sync.RWMutex
+	data fileData
+}
+
+type fileData struct {
+	Groups map[string]*Group `json:"groups"`
+	Users  map[string]*User  `json:"users"`
+}
+
+// Permissions holds permissions that are set, globally and for single servers.
+type Permissions struct {
+	Permissions map[string]bool            `json:"permissions,omitempty"`
+	Servers     map[string]map[string]bool `json:"servers,omitempty"`
+}
+
+// Group is a named set of permissions, which inherits the permissions of other groups.
+type Group struct {
+	Permissions
+	Inherits []string `json:"inherits,omitempty"`
+}
+
+// User holds the permissions of a single player.
+type User struct {
+	Permissions
+	Groups []string `json:"groups,omitempty"`
+}
+
+// NewFileProvider reads the file at path, writing a default file there if there is none yet. The
+// default lets everybody use /server and /glist.
+func NewFileProvider(path string, logger zerolog.Logger) (*FileProvider, error) {
+	p := &FileProvider{path: path, logger: logger}
+	return p, p.Reload()
+}
+
+// Reload reads the file again. Functions created before see the new permissions.
+func (p *FileProvider) Reload() error {
+	data, err := os.ReadFile(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p.writeDefault()
+	}
+	if err != nil {
+		return err
+	}
+
+	var parsed fileData
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return fmt.Errorf("parsing %s: %w", p.path, err)
+	}
+	if err := parsed.validate(); err != nil {
+		return fmt.Errorf("%s: %w", p.path, err)
+	}
+
+	for name := range parsed.Users {
+		if _, err := uuid.Parse(name); err != nil {
+			p.logger.Warn().Str("file", p.path).Str("user", name).Msg("User is listed by name, which only matches players in offline mode")
+		}
+	}
+
+	p.mu.Lock()
+	p.data = parsed
+	p.mu.Unlock()
+
+	return nil
+}
+
+func (p *FileProvider) writeDefault() error {
+	defaults := fileData{
+		Groups: map[string]*Group{
+			DefaultGroup: {Permissions: Permissions{Permissions: map[string]bool{
+				"gopro.command.server": true,
+				"gopro.command.glist":  true,
+			}}},
+			"admin": {Inherits: []string{DefaultGroup}, Permissions: Permissions{Permissions: map[string]bool{Wildcard: true}}},
+		},
+		Users: map[string]*User{},
+	}
+
+	data, err := json.MarshalIndent(defaults, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.data = defaults
+	p.mu.Unlock()
+
+	return os.WriteFile(p.path, data, 0644)
+}
+
+func (d *fileData) validate() error {
+	for name, group := range d.Groups {
+		if group == nil {
+			return fmt.Errorf("group %s is null", name)
+		}
+		for _, inherited := range group.Inherits {
+			if _, ok := d.Groups[inherited]; !ok {
+				return fmt.Errorf("group %s inherits unknown group %s", name, inherited)
+			}
+		}
+	}
+
+	for name, user := range d.Users {
+		if user == nil {
+			return fmt.Errorf("user %s is null", name)
+		}
+		for _, group := range user.Groups {
+			if _, ok := d.Groups[group]; !ok {
+				return fmt.Errorf("user %s is in unknown group %s", name, group)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *FileProvider) CreateFunction(subject Subject) Function {
+	return func(permission string) Tristate {
+		return p.value(subject, permission)
+	}
+}
+
+func (p *FileProvider) value(subject Subject, permission string) Tristate {
+	var context Context
+	if contextual, ok := subject.(Contextual); ok {
+		context = contextual.PermissionContext()
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	visited := make(map[string]bool)
+
+	if user := p.user(subject); user != nil {
+		if value := user.value(permission, context); value != Undefined {
+			return value
+		}
+
+		for _, group := range user.Groups {
+			if value := p.groupValue(group, permission, context, visited); value != Undefined {
+				return value
+			}
+		}
+	}
+
+	return p.groupValue(DefaultGroup, permission, context, visited)
+}
+
+// groupValue looks the permission up in the group and the groups it inherits, depth first.
+// Groups already visited are skipped, which also ends inheritance cycles.
+func (p *FileProvider) groupValue(name string, permission string, context Context, visited map[string]bool) Tristate {
+	group, ok := p.data.Groups[name]
+	if !ok || visited[name] {
+		return Undefined
+	}
+	visited[name] = true
+
+	if value := group.value(permission, context); value != Undefined {
+		return value
+	}
+
+	for _, inherited := range group.Inherits {
+		if value := p.groupValue(inherited, permission, context, visited); value != Undefined {
+			return value
+		}
+	}
+
+	return Undefined
+}
+
+func (p *FileProvider) user(subject Subject) *User {
+	if identified, ok := subject.(interface{ UUID() uuid.UUID }); ok {
+		if user, ok := p.data.Users[identified.UUID().String()]; ok {
+			return user
+		}
+	}
+
+	// names of online players are given to others once they change them
+	if authenticated, ok := subject.(Authenticated); !ok || authenticated.OnlineMode() {
+		return nil
+	}
+
+	for name, user := range p.data.Users {
+		if strings.EqualFold(name, subject.Name()) {
+			return user
+		}
+	}
+
+	return nil
+}
+
+func (p *Permissions) value(permission string, context Context) Tristate {
+	if context.Server != "" {
+		if value := lookup(p.Servers[context.Server], permission); value != Undefined {
+			return value
+		}
+	}
+
+	return lookup(p.Permissions, permission)
+}
+
+// lookup finds the permission or the closest wildcard covering it.
+func lookup(permissions map[string]bool, permission string) Tristate {
+	if len(permissions) == 0 {
+		return Undefined
+	}
+
+	if value, ok := permissions[permission]; ok {
+		return Of(value)
+	}
+
+	for i := strings.LastIndexByte(permission, '.'); i >= 0; i = strings.LastIndexByte(permission[:i], '.') {
+		if value, ok := permissions[permission[:i+1]+Wildcard]; ok {
+			return Of(value)
+		}
+	}
+
+	if value, ok := permissions[Wildcard]; ok {
+		return Of(value)
+	}
+
+	return Undefined
+}
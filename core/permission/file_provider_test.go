@@ -0,0 +1,92 @@
+package permission
+
+import (
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testPlayer struct {
+	name   string
+	id     uuid.UUID
+	online bool
+}
+
+func (p *testPlayer) Name() string {
+	return p.name
+}
+
+func (p *testPlayer) UUID() uuid.UUID {
+	return p.id
+}
+
+func (p *testPlayer) OnlineMode() bool {
+	return p.online
+}
+
+const testUUID = "069a79f4-44e9-4726-a5be-fca90e38aaf5"
+
+func newTestProvider(t *testing.T, data string) *FileProvider {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "permissions.json")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := NewFileProvider(path, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return provider
+}
+
+func TestUsersAreFoundByUUID(t *testing.T) {
+	provider := newTestProvider(t, `{"users": {"`+testUUID+`": {"permissions": {"gopro.test": true}}}}`)
+
+	for _, online := range []bool{true, false} {
+		player := &testPlayer{name: "Notch", id: uuid.MustParse(testUUID), online: online}
+		if got := provider.CreateFunction(player)("gopro.test"); got != True {
+			t.Errorf("online mode %v: permission is %v, want true", online, got)
+		}
+	}
+}
+
+func TestUsersAreFoundByNameOnlyInOfflineMode(t *testing.T) {
+	provider := newTestProvider(t, `{"users": {"notch": {"permissions": {"gopro.test": true}}}}`)
+
+	offline := &testPlayer{name: "Notch", id: uuid.New()}
+	if got := provider.CreateFunction(offline)("gopro.test"); got != True {
+		t.Errorf("offline player has the permission %v, want true", got)
+	}
+
+	online := &testPlayer{name: "Notch", id: uuid.New(), online: true}
+	if got := provider.CreateFunction(online)("gopro.test"); got != Undefined {
+		t.Errorf("online player with a listed name has the permission %v, want undefined", got)
+	}
+}
+
+func TestUserPermissionsComeBeforeGroups(t *testing.T) {
+	provider := newTestProvider(t, `{
+		"groups": {
+			"default": {"permissions": {"gopro.*": true}},
+			"staff": {"inherits": ["default"], "permissions": {"gopro.command.*": false}}
+		},
+		"users": {"`+testUUID+`": {"groups": ["staff"], "permissions": {"gopro.command.end": true}}}
+	}`)
+	function := provider.CreateFunction(&testPlayer{name: "Notch", id: uuid.MustParse(testUUID), online: true})
+
+	for permission, want := range map[string]Tristate{
+		"gopro.command.end":  True,
+		"gopro.command.send": False,
+		"gopro.other":        True,
+		"other":              Undefined,
+	} {
+		if got := function(permission); got != want {
+			t.Errorf("%s is %v, want %v", permission, got, want)
+		}
+	}
+}
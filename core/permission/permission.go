@@ -0,0 +1,86 @@
+// Package permission decides what players and the console may do. Providers hand out a Function
+// per subject, which the proxy asks for the permissions the subject needs.
+package permission
+
+// Tristate is the value of a permission, which is Undefined unless it was set.
+type Tristate int8
+
+const (
+	Undefined Tristate = iota
+	True
+	False
+)
+
+// Of turns a set value into a Tristate.
+func Of(value bool) Tristate {
+	if value {
+		return True
+	}
+
+	return False
+}
+
+// Bool is the value of a set permission, false for Undefined.
+func (t Tristate) Bool() bool {
+	return t == True
+}
+
+func (t Tristate) String() string {
+	switch t {
+	case True:
+		return "true"
+	case False:
+		return "false"
+	default:
+		return "undefined"
+	}
+}
+
+// Function returns the value of a permission for the subject it was created for.
+type Function func(permission string) Tristate
+
+// None leaves every permission undefined.
+func None(string) Tristate {
+	return Undefined
+}
+
+// All grants every permission.
+func All(string) Tristate {
+	return True
+}
+
+// Subject is a player or the console, whose permissions are checked.
+type Subject interface {
+	Name() string
+}
+
+// Contextual subjects tell the context their permissions are checked in, like the server a
+// player is on.
+type Contextual interface {
+	PermissionContext() Context
+}
+
+// Authenticated subjects tell whether their name was verified. Players in online mode are
+// verified by Mojang, anybody may join with any name in offline mode.
+type Authenticated interface {
+	OnlineMode() bool
+}
+
+// Context is the situation a permission is checked in.
+type Context struct {
+	// Server is the name of the server the subject is on, empty if it isn't on one
+	Server string
+}
+
+// Provider creates the permission functions of subjects. Functions are asked for every check,
+// so providers may change the permissions of subjects after creating their functions.
+type Provider interface {
+	CreateFunction(subject Subject) Function
+}
+
+// ProviderFunc makes a Provider out of a function.
+type ProviderFunc func(subject Subject) Function
+
+func (f ProviderFunc) CreateFunction(subject Subject) Function {
+	return f(subject)
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"gopro/core/event"
+	"gopro/core/permission"
+	"strings"
+)
+
+// permissionsPath is where the default permission provider reads its groups and users from.
+const permissionsPath = "permissions.json"
+
+// PermissionSubject is a command source whose permissions can be checked, a player or the console.
+type PermissionSubject interface {
+	permission.Subject
+	// PermissionValue is the value of the permission, Undefined if it wasn't set
+	PermissionValue(permission string) permission.Tristate
+	// HasPermission tells whether the permission was granted
+	HasPermission(permission string) bool
+}
+
+// PermissionProvider is the provider the permissions of players are set up with, unless a
+// PermissionsSetupEvent handler sets another one.
+func (p *Proxy) PermissionProvider() permission.Provider {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.permissionProvider
+}
+
+// SetPermissionProvider replaces the permission provider, setting up the permissions of the
+// online players again.
+func (p *Proxy) SetPermissionProvider(provider permission.Provider) {
+	p.mu.Lock()
+	p.permissionProvider = provider
+	p.mu.Unlock()
+
+	for _, player := range p.Players() {
+		player.setupPermissions()
+	}
+}
+
+// loadPermissions sets up the file backed permission provider, which is reloaded with the config.
+func (p *Proxy) loadPermissions() {
+	provider, err := permission.NewFileProvider(permissionsPath, p.logger.With().Str("component", "permissions").Logger())
+	if err != nil {
+		p.logger.Error().Err(err).Str("file", permissionsPath).Msg("Failed to load permissions, nobody has any")
+	}
+
+	p.mu.Lock()
+	p.permissionProvider = provider
+	p.mu.Unlock()
+
+	event.Subscribe(p.eventBus, event.VeryEarly, func(*event.ProxyReloadEvent) {
+		if err := provider.Reload(); err != nil {
+			p.logger.Error().Err(err).Str("file", permissionsPath).Msg("Failed to reload permissions")
+		}
+	})
+}
+
+// setupPermissions fires PermissionsSetupEvent and creates the permission function of the player.
+func (p *Player) setupPermissions() {
+	e := &event.PermissionsSetupEvent{Subject: p, Provider: p.proxy.PermissionProvider()}
+	p.proxy.eventBus.Fire(e)
+
+	function := permission.Function(permission.None)
+	if e.Provider != nil {
+		function = e.Provider.CreateFunction(p)
+	}
+
+	p.mu.Lock()
+	p.permissions = function
+	p.mu.Unlock()
+}
+
+func (p *Player) PermissionValue(name string) permission.Tristate {
+	p.mu.Lock()
+	function := p.permissions
+	p.mu.Unlock()
+
+	if function == nil {
+		return permission.Undefined
+	}
+
+	return function(name)
+}
+
+func (p *Player) HasPermission(name string) bool {
+	return p.PermissionValue(name).Bool()
+}
+
+// PermissionContext tells providers the server the player is on.
+func (p *Player) PermissionContext() permission.Context {
+	var context permission.Context
+	if server := p.CurrentServer(); server != nil {
+		context.Server = server.Name()
+	}
+
+	return context
+}
+
+// The console has every permission.
+func (c *consoleSource) PermissionValue(string) permission.Tristate {
+	return permission.True
+}
+
+func (c *consoleSource) HasPermission(string) bool {
+	return true
+}
+
+// serverPermission is the permission needed to join a restricted server.
+func serverPermission(server *ServerInfo) string {
+	return "gopro.server." + strings.ToLower(server.Name())
+}
+
+// CanAccess tells whether the subject may join the server, which it may unless the server is
+// listed as restricted in the config and the subject lacks its permission.
+func (p *Proxy) CanAccess(subject PermissionSubject, server *ServerInfo) bool {
+	for _, restricted := range p.Config().Restricted {
+		if restricted == server.Name() {
+			return subject.HasPermission(serverPermission(server))
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"github.com/rs/zerolog"
+	"gopro/core/proto"
+	"gopro/core/proto/encoding"
+	"gopro/core/proto/packets"
+	"strings"
+)
+
+// playHandler forwards the packets of a logged in player to its server, following the state
+// changes of the client.
+type playHandler struct {
+	deps   *HandlerDependency
+	conn   *Conn
+	player *Player
+	logger zerolog.Logger
+}
+
+func newPlayHandler(deps *HandlerDependency, conn *Conn, player *Player) *playHandler {
+	return &playHandler{deps: deps, conn: conn, player: player, logger: conn.Logger.With().Str("handler", "play").Logger()}
+}
+
+func (h *playHandler) Handle(packet *proto.Packet) {
+	protocol := h.conn.ProtocolVersion
+
+	switch h.conn.State() {
+	case proto.Login:
+		{
+			// the proxy acknowledged the login of the server itself
+			if packet.ID == (&packets.LoginAcknowledged{}).ID(protocol) {
+				h.conn.SwitchState(proto.Configuration)
+			}
+			return
+		}
+	case proto.Configuration:
+		{
+			switch packet.ID {
+			case (&packets.AcknowledgeFinishConfiguration{}).ID(protocol):
+				h.conn.SwitchState(proto.Play)
+			case (&packets.ClientInformation{}).ID(protocol):
+				h.player.setClientInformation(packet)
+			}
+
+			if h.player.handlePluginMessage(h.conn, packet, true, h.player.CurrentServer()) {
+				return
+			}
+		}
+	case proto.Play:
+		{
+			if protocol >= proto.ConfigurationProtocol && packet.ID == (&packets.AcknowledgeConfiguration{}).ID(protocol) {
+				h.conn.SwitchState(proto.Configuration)
+
+				// a server asking for the configuration state gets the acknowledgement
+				if h.player.completeSwitch() {
+					return
+				}
+			}
+
+			if protocol >= packets.PlayProtocol && h.handleCommand(packet) {
+				return
+			}
+
+			if h.player.handlePluginMessage(h.conn, packet, true, h.player.CurrentServer()) {
+				return
+			}
+		}
+	}
+
+	h.player.forward(packet)
+}
+
+// handleCommand runs the commands of the proxy and completes them, returning false for
+// packets the server has to handle.
+func (h *playHandler) handleCommand(packet *proto.Packet) bool {
+	protocol := h.conn.ProtocolVersion
+	commands := h.deps.Proxy.commands
+
+	switch {
+	case packet.ID == (&packets.ChatCommand{}).ID(protocol),
+		protocol >= packets.SignedChatCommandProtocol && packet.ID == (&packets.SignedChatCommand{}).ID(protocol):
+		{
+			var chatCommand packets.ChatCommand
+			if err := h.conn.ReadPacket(packet, &chatCommand); err != nil {
+				return false
+			}
+
+			input := string(chatCommand.Command)
+			if !commands.Has(h.player, input) {
+				return false
+			}
+
+			// commands may wait for servers, which mustn't hold up the packets of the client
+			go func() {
+				_ = h.deps.Proxy.ExecuteCommand(h.player, input)
+			}()
+			return true
+		}
+	case packet.ID == (&packets.CommandSuggestionsRequest{}).ID(protocol):
+		{
+			var request packets.CommandSuggestionsRequest
+			if err := h.conn.ReadPacket(packet, &request); err != nil {
+				return false
+			}
+
+			input := strings.TrimPrefix(string(request.Text), "/")
+			if !commands.Has(h.player, input) {
+				return false
+			}
+
+			start, suggestions := commands.Suggest(h.player, input)
+			matches := make([]packets.SuggestionMatch, len(suggestions))
+			for i, suggestion := range suggestions {
+				matches[i] = packets.SuggestionMatch{Match: encoding.String(suggestion)}
+			}
+
+			offset := len(request.Text) - len(input)
+			response := &packets.CommandSuggestionsResponse{
+				TransactionID: request.TransactionID,
+				Start:         encoding.Varint(start + offset),
+				Length:        encoding.Varint(len(input) - start),
+				Matches:       matches,
+			}
+			if err := h.conn.WritePacket(response); err != nil {
+				h.logger.Debug().Err(err).Msg("Error sending command suggestions")
+			}
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,8 +1,315 @@
 package core
 
-import "github.com/google/uuid"
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"gopro/core/component"
+	"gopro/core/event"
+	"gopro/core/permission"
+	"gopro/core/proto"
+	"gopro/core/proto/auth"
+	"gopro/core/proto/packets"
+	"net"
+	"sync"
+)
 
+// ErrConnectionCancelled is returned by Connect when a ServerPreConnectEvent handler cancelled the connection.
+var ErrConnectionCancelled = errors.New("connection cancelled")
+
+// ErrNoPermission is returned by Connect for restricted servers the player lacks the permission of.
+var ErrNoPermission = errors.New("no permission")
+
+// Player is a client that logged in to the proxy.
 type Player struct {
-	UUID     uuid.UUID
-	Username string
+	proxy   *Proxy
+	conn    *Conn
+	profile *auth.GameProfile
+	logger  zerolog.Logger
+	// onlineMode is set if Mojang authenticated the player
+	onlineMode bool
+
+	mu        sync.Mutex
+	server    *serverConnection
+	switching *serverSwitch
+	// connecting is set while Connect runs, so only one connection is made at a time
+	connecting bool
+	// permissions is set up at login, before the player is registered
+	permissions permission.Function
+	// clientInformation is the last Client Information packet of the configuration state
+	clientInformation *proto.Packet
+}
+
+func newPlayer(proxy *Proxy, conn *Conn, profile *auth.GameProfile, onlineMode bool) *Player {
+	return &Player{
+		proxy:      proxy,
+		conn:       conn,
+		profile:    profile,
+		logger:     conn.Logger.With().Str("player", profile.Name).Logger(),
+		onlineMode: onlineMode,
+	}
+}
+
+func (p *Player) UUID() uuid.UUID {
+	return p.profile.ID
+}
+
+func (p *Player) Username() string {
+	return p.profile.Name
+}
+
+// Name is the username, naming the player as command source.
+func (p *Player) Name() string {
+	return p.profile.Name
+}
+
+// GameProfile is the profile the player logged in with, after GameProfileRequestEvent handlers ran.
+func (p *Player) GameProfile() *auth.GameProfile {
+	return p.profile
+}
+
+func (p *Player) RemoteAddr() net.Addr {
+	return p.conn.Conn.RemoteAddr()
+}
+
+func (p *Player) ProtocolVersion() int {
+	return p.conn.ProtocolVersion
+}
+
+// OnlineMode tells whether Mojang authenticated the player, which offline mode players aren't.
+func (p *Player) OnlineMode() bool {
+	return p.onlineMode
+}
+
+// Listener is the listener the player connected to.
+func (p *Player) Listener() *Listener {
+	return p.conn.listener
+}
+
+// VirtualHost is the host the player connected with, lowercased.
+func (p *Player) VirtualHost() string {
+	return p.conn.VirtualHost
+}
+
+// CurrentServer is the server the player plays on, nil while it isn't connected to one.
+func (p *Player) CurrentServer() *ServerInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.server == nil {
+		return nil
+	}
+
+	return p.server.server
+}
+
+// SendMessage shows the message in the chat of the player.
+func (p *Player) SendMessage(message *component.TextComponent) error {
+	if p.conn.State() != proto.Play || p.conn.ProtocolVersion < packets.PlayProtocol {
+		return errors.New("can't send messages to this player")
+	}
+
+	return p.conn.WritePacket(packets.NewSystemChat(message, false))
+}
+
+// Disconnect kicks the player from the proxy with the reason.
+func (p *Player) Disconnect(reason *component.TextComponent) {
+	p.conn.Disconnect(reason)
+}
+
+// Connect connects the player to the server, firing ServerPreConnectEvent, ServerConnectedEvent
+// and ServerPostConnectEvent. Errors of the server refusing the player are DisconnectedError.
+// Players on a server are moved through the configuration state, which needs 1.20.2 or newer;
+// the move completes, and ServerPostConnectEvent is fired, once the client acknowledged it.
+func (p *Player) Connect(server *ServerInfo) error {
+	p.mu.Lock()
+	if p.connecting || p.switching != nil {
+		p.mu.Unlock()
+		return errors.New("already connecting to a server")
+	}
+	p.connecting = true
+	p.mu.Unlock()
+
+	// a switch that was started stays reserved by p.switching until the client acknowledges it
+	defer func() {
+		p.mu.Lock()
+		p.connecting = false
+		p.mu.Unlock()
+	}()
+
+	previous := p.CurrentServer()
+	if previous != nil && p.conn.ProtocolVersion < proto.ConfigurationProtocol {
+		return errors.New("switching servers needs 1.20.2 or newer")
+	}
+
+	pre := &event.ServerPreConnectEvent{Player: p, Original: server, Target: server}
+	p.proxy.eventBus.Fire(pre)
+	if pre.Cancelled() || pre.Target == nil {
+		return ErrConnectionCancelled
+	}
+
+	target := p.proxy.resolveServer(pre.Target)
+	if target == previous {
+		return fmt.Errorf("already connected to %s", target.Name())
+	}
+	if !p.proxy.CanAccess(p, target) {
+		return fmt.Errorf("%w to join %s", ErrNoPermission, target.Name())
+	}
+	if p.proxy.serverFull(target) {
+		return fmt.Errorf("%w: %s", ErrServerFull, target.Name())
+	}
+	p.logger.Info().Str("server", target.Name()).Msg("Connecting to server")
+
+	sc, err := connectServer(p, target)
+	if err != nil {
+		return err
+	}
+
+	p.proxy.eventBus.Fire(&event.ServerConnectedEvent{Player: p, Server: target, Previous: asEventServer(previous)})
+
+	if previous == nil {
+		p.mu.Lock()
+		p.server = sc
+		p.mu.Unlock()
+
+		go sc.relay()
+
+		p.proxy.eventBus.Fire(&event.ServerPostConnectEvent{Player: p})
+		return nil
+	}
+
+	// the relay of the previous server stops once it isn't current, so nothing of it follows
+	// the switch to the configuration state
+	p.mu.Lock()
+	old := p.server
+	p.server = nil
+	p.switching = &serverSwitch{connection: sc, previous: previous}
+	err = p.conn.WritePacket(&packets.StartConfiguration{})
+	p.mu.Unlock()
+
+	if old != nil {
+		old.conn.Close()
+	}
+	if err != nil {
+		sc.conn.Close()
+		return err
+	}
+
+	return nil
+}
+
+// onServer tells whether the player is on the server or moving to it.
+func (p *Player) onServer(server *ServerInfo) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return (p.server != nil && p.server.server == server) ||
+		(p.switching != nil && p.switching.connection.server == server)
+}
+
+// fallback moves the player, whose server kicked it or went away, to the next server it may
+// join. Players that can't be moved are disconnected with the reason.
+func (p *Player) fallback(from *ServerInfo, reason *component.TextComponent) {
+	// only players in the play state can be sent through the configuration state again
+	if p.conn.ProtocolVersion >= proto.ConfigurationProtocol && p.conn.State() == proto.Play {
+		tried := []*ServerInfo{from}
+		for next := p.proxy.nextServer(p, tried); next != nil; next = p.proxy.nextServer(p, tried) {
+			err := p.Connect(next)
+			if err == nil {
+				p.logger.Info().Str("from", from.Name()).Str("server", next.Name()).Stringer("reason", reason).Msg("Moved to fallback server")
+				return
+			}
+
+			p.logger.Debug().Err(err).Str("server", next.Name()).Msg("Failed to connect to fallback server")
+			tried = append(tried, next)
+		}
+	}
+
+	p.Disconnect(reason)
+}
+
+// serverSwitch is a move to another server waiting for the client to enter the configuration state.
+type serverSwitch struct {
+	connection *serverConnection
+	previous   *ServerInfo
+}
+
+// completeSwitch moves the player to the server it is switching to, once it acknowledged the
+// configuration state. It returns false if the player isn't switching.
+func (p *Player) completeSwitch() bool {
+	p.mu.Lock()
+	switching := p.switching
+	p.switching = nil
+	if switching == nil {
+		p.mu.Unlock()
+		return false
+	}
+
+	sc := switching.connection
+	p.server = sc
+	information := p.clientInformation
+	p.mu.Unlock()
+
+	// the new server only learns the settings of the client from its first configuration
+	if information != nil {
+		if err := sc.conn.SendPacket(information); err != nil {
+			p.logger.Debug().Err(err).Msg("Error sending client information to server")
+		}
+	}
+
+	go sc.relay()
+
+	p.proxy.eventBus.Fire(&event.ServerPostConnectEvent{Player: p, Previous: switching.previous})
+	return true
+}
+
+// setClientInformation keeps the settings of the client to pass them on to the next server.
+func (p *Player) setClientInformation(packet *proto.Packet) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clientInformation = proto.Raw(packet.ID, packet.Payload())
+}
+
+// forward sends a packet of the client on to its server.
+func (p *Player) forward(packet *proto.Packet) {
+	p.mu.Lock()
+	sc := p.server
+	p.mu.Unlock()
+
+	if sc == nil {
+		p.logger.Debug().Int("id", int(packet.ID)).Msg("Dropping packet, not connected to a server")
+		return
+	}
+
+	if err := sc.conn.SendPacket(packet); err != nil {
+		p.logger.Debug().Err(err).Msg("Error forwarding packet to server")
+	}
+}
+
+// disconnectServer closes the connection to the current server, if there is one.
+func (p *Player) disconnectServer() {
+	p.mu.Lock()
+	sc := p.server
+	p.server = nil
+	switching := p.switching
+	p.switching = nil
+	p.mu.Unlock()
+
+	if sc != nil {
+		sc.conn.Close()
+	}
+	if switching != nil {
+		switching.connection.conn.Close()
+	}
+}
+
+// asEventServer keeps a nil server nil once it is an event.Server.
+func asEventServer(server *ServerInfo) event.Server {
+	if server == nil {
+		return nil
+	}
+
+	return server
 }
@@ -1,9 +1,90 @@
 package core
 
+import (
+	"fmt"
+	"github.com/rs/zerolog"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Plugins are the plugins compiled into the proxy, loaded in the order of their dependencies.
 var Plugins []Plugin
 
+// pluginIDPattern is what plugin ids are made of, as they name the data directory of the plugin.
+var pluginIDPattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// Plugin describes a plugin: its metadata, dependencies and lifecycle functions.
 type Plugin struct {
-	Name     string
+	// ID identifies the plugin for dependencies and lookups, the lowercased Name if empty
+	ID          string
+	Name        string
+	Version     string
+	Authors     []string
+	Description string
+	// Dependencies are the ids of the plugins that must be loaded before this one
+	Dependencies []string
+	// SoftDependencies are the ids of plugins loaded before this one if they are present
+	SoftDependencies []string
+	// API is what the plugin offers other plugins, which get it through Proxy.Plugin
+	API any
+
 	Init     func(p *Proxy) error
 	Shutdown func(p *Proxy) error
 }
+
+// RegisterPlugin adds a plugin compiled into the proxy, usually from an init function.
+func RegisterPlugin(plugin Plugin) {
+	Plugins = append(Plugins, plugin)
+}
+
+func (plugin Plugin) id() string {
+	if plugin.ID != "" {
+		return plugin.ID
+	}
+
+	return strings.ToLower(strings.ReplaceAll(plugin.Name, " ", "-"))
+}
+
+// PluginContainer is a plugin the proxy knows of, with the data directory and logger it gets.
+type PluginContainer struct {
+	Plugin Plugin
+
+	id      string
+	dataDir string
+	logger  zerolog.Logger
+	// loaded is read by commands and other plugins while plugins load and shut down
+	loaded atomic.Bool
+}
+
+func (c *PluginContainer) ID() string {
+	return c.id
+}
+
+// DataDir is the directory the plugin keeps its files in, created before it is initialized.
+func (c *PluginContainer) DataDir() string {
+	return c.dataDir
+}
+
+// Logger is the logger of the plugin, its messages are tagged with its id.
+func (c *PluginContainer) Logger() zerolog.Logger {
+	return c.logger
+}
+
+// API is what the plugin offers other plugins.
+func (c *PluginContainer) API() any {
+	return c.Plugin.API
+}
+
+// Loaded tells whether the plugin was initialized without errors and isn't shut down.
+func (c *PluginContainer) Loaded() bool {
+	return c.loaded.Load()
+}
+
+func (c *PluginContainer) String() string {
+	if c.Plugin.Version == "" {
+		return c.id
+	}
+
+	return fmt.Sprintf("%s %s", c.id, c.Plugin.Version)
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"strings"
+)
+
+// loadPluginFiles loads the Go plugins (.so) and WebAssembly plugins (.wasm) in the directory.
+// Files that can't be loaded are logged and skipped.
+func (p *Proxy) loadPluginFiles(dir string) []Plugin {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		p.logger.Error().Err(err).Str("directory", dir).Msg("Failed to read plugin directory")
+		return nil
+	}
+
+	var plugins []Plugin
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+
+		var loaded Plugin
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".so":
+			loaded, err = openGoPlugin(path)
+		case ".wasm":
+			loaded, err = p.openWasmPlugin(path)
+		default:
+			continue
+		}
+
+		if err != nil {
+			p.logger.Error().Err(err).Str("file", path).Msg("Failed to load plugin file")
+			continue
+		}
+
+		p.logger.Debug().Str("file", path).Str("plugin", loaded.id()).Msg("Found plugin file")
+		plugins = append(plugins, loaded)
+	}
+
+	return plugins
+}
+
+// openGoPlugin opens a plugin built with -buildmode=plugin, which exports its descriptor as
+//
+//	var Plugin = core.Plugin{...}
+//
+// Go plugins only load into a proxy built with the same Go version and the same version of
+// every package they share, gopro/core included.
+func openGoPlugin(path string) (Plugin, error) {
+	opened, err := plugin.Open(path)
+	if err != nil {
+		return Plugin{}, err
+	}
+
+	symbol, err := opened.Lookup("Plugin")
+	if err != nil {
+		return Plugin{}, err
+	}
+
+	descriptor, ok := symbol.(*Plugin)
+	if !ok {
+		return Plugin{}, fmt.Errorf("symbol Plugin is a %T, not a *core.Plugin", symbol)
+	}
+
+	if descriptor.ID == "" && descriptor.Name == "" {
+		descriptor.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	return *descriptor, nil
+}
@@ -0,0 +1,240 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"gopro/core/command"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// pluginDataRoot is the directory holding the data directories of the plugins.
+const pluginDataRoot = "plugins"
+
+// pluginManager loads the plugins in the order of their dependencies and shuts them down in reverse.
+type pluginManager struct {
+	proxy *Proxy
+
+	mu      sync.RWMutex
+	plugins map[string]*PluginContainer
+	// order is the load order, which is reversed for shutting down
+	order []*PluginContainer
+}
+
+func newPluginManager(proxy *Proxy) *pluginManager {
+	return &pluginManager{proxy: proxy, plugins: make(map[string]*PluginContainer)}
+}
+
+// load initializes the plugins, logging the ones that can't be loaded.
+func (m *pluginManager) load(plugins []Plugin) {
+	order, err := loadOrder(plugins)
+	if err != nil {
+		m.proxy.logger.Error().Err(err).Msg("Some plugins can't be loaded")
+	}
+
+	for _, plugin := range order {
+		id := plugin.id()
+		container := &PluginContainer{
+			Plugin:  plugin,
+			id:      id,
+			dataDir: filepath.Join(pluginDataRoot, id),
+			logger:  m.proxy.logger.With().Str("plugin", id).Logger(),
+		}
+
+		m.mu.Lock()
+		m.plugins[id] = container
+		m.order = append(m.order, container)
+		m.mu.Unlock()
+
+		commands := make(map[string]*command.Node)
+		for _, node := range m.proxy.commands.All() {
+			commands[node.Name()] = node
+		}
+		channels := m.proxy.channels.Channels()
+
+		if err := m.init(container); err != nil {
+			m.proxy.logger.Error().Err(err).Str("plugin", id).Msg("Error loading plugin")
+			m.unload(id, commands, channels)
+			continue
+		}
+
+		container.loaded.Store(true)
+		container.logger.Info().Str("version", plugin.Version).Msg("Plugin loaded")
+	}
+}
+
+func (m *pluginManager) init(container *PluginContainer) error {
+	for _, dependency := range container.Plugin.Dependencies {
+		if loaded := m.get(dependency); loaded == nil || !loaded.Loaded() {
+			return fmt.Errorf("dependency %s failed to load", dependency)
+		}
+	}
+
+	if err := os.MkdirAll(container.dataDir, 0755); err != nil {
+		return err
+	}
+
+	if container.Plugin.Init == nil {
+		return nil
+	}
+
+	// handlers of the plugin are logged with its id when they misbehave
+	return m.proxy.eventBus.NameSubscriptions(container.id, func() error {
+		return container.Plugin.Init(m.proxy)
+	})
+}
+
+// unload removes what a plugin that failed to load left behind: its handlers and tasks, and the
+// commands and channels registered since the commands and channels from before its Init.
+func (m *pluginManager) unload(id string, commands map[string]*command.Node, channels []string) {
+	m.proxy.eventBus.UnsubscribeOwner(id)
+	m.proxy.scheduler.CancelAll(id)
+
+	for _, node := range m.proxy.commands.All() {
+		previous, ok := commands[node.Name()]
+		if !ok {
+			m.proxy.commands.Unregister(node.Name())
+		} else if previous != node {
+			// the plugin replaced a command, which comes back
+			m.proxy.commands.Register(previous)
+		}
+	}
+
+	for _, channel := range m.proxy.channels.Channels() {
+		if !slices.Contains(channels, channel) {
+			m.proxy.channels.Unregister(channel)
+		}
+	}
+}
+
+// shutdown shuts the loaded plugins down, dependents before their dependencies.
+func (m *pluginManager) shutdown() {
+	m.mu.RLock()
+	order := append([]*PluginContainer(nil), m.order...)
+	m.mu.RUnlock()
+
+	for i := len(order) - 1; i >= 0; i-- {
+		container := order[i]
+		if !container.loaded.CompareAndSwap(true, false) {
+			continue
+		}
+
+		if container.Plugin.Shutdown != nil {
+			if err := container.Plugin.Shutdown(m.proxy); err != nil {
+				m.proxy.logger.Error().Err(err).Str("plugin", container.id).Msg("Error shutting down plugin")
+			}
+		}
+
+		// tasks the plugin left behind would run without it
+		m.proxy.scheduler.CancelAll(container.id)
+	}
+}
+
+func (m *pluginManager) get(id string) *PluginContainer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.plugins[id]
+}
+
+func (m *pluginManager) all() []*PluginContainer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]*PluginContainer(nil), m.order...)
+}
+
+// loadOrder sorts the plugins so every plugin comes after its dependencies, keeping the
+// declaration order otherwise. Plugins with invalid ids, missing dependencies or dependency
+// cycles are left out, the returned error tells why.
+func loadOrder(plugins []Plugin) ([]Plugin, error) {
+	var errs []error
+
+	byID := make(map[string]Plugin, len(plugins))
+	var ids []string
+	for _, plugin := range plugins {
+		id := plugin.id()
+		if !pluginIDPattern.MatchString(id) {
+			errs = append(errs, fmt.Errorf("plugin %q: invalid id, ids are made of a-z, 0-9, _ and -", id))
+			continue
+		}
+		if _, ok := byID[id]; ok {
+			errs = append(errs, fmt.Errorf("plugin %s: registered twice", id))
+			continue
+		}
+		byID[id] = plugin
+		ids = append(ids, id)
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+		failed
+	)
+	states := make(map[string]int, len(ids))
+	var order []Plugin
+
+	// visit adds the plugin after its dependencies, the path is the chain of plugins leading to it
+	var visit func(id string, path []string) bool
+	visit = func(id string, path []string) bool {
+		switch states[id] {
+		case done:
+			return true
+		case failed:
+			return false
+		case visiting:
+			cycle := append(append([]string(nil), path[indexOf(path, id):]...), id)
+			errs = append(errs, fmt.Errorf("plugin %s: dependency cycle %s", id, strings.Join(cycle, " -> ")))
+			return false
+		}
+
+		states[id] = visiting
+		path = append(path, id)
+		plugin := byID[id]
+
+		ok := true
+		for _, dependency := range plugin.Dependencies {
+			if _, present := byID[dependency]; !present {
+				errs = append(errs, fmt.Errorf("plugin %s: missing dependency %s", id, dependency))
+				ok = false
+				continue
+			}
+			ok = visit(dependency, path) && ok
+		}
+		for _, dependency := range plugin.SoftDependencies {
+			// a failed soft dependency doesn't keep the plugin from loading, neither does a soft cycle
+			if _, present := byID[dependency]; present && states[dependency] != visiting {
+				visit(dependency, path)
+			}
+		}
+
+		if !ok || states[id] == failed {
+			states[id] = failed
+			return false
+		}
+
+		states[id] = done
+		order = append(order, plugin)
+		return true
+	}
+
+	for _, id := range ids {
+		visit(id, nil)
+	}
+
+	return order, errors.Join(errs...)
+}
+
+func indexOf(path []string, id string) int {
+	for i, elem := range path {
+		if elem == id {
+			return i
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"errors"
+	"gopro/core/command"
+	"gopro/core/event"
+	"os"
+	"slices"
+	"testing"
+)
+
+// inTempDir runs the test in a temporary directory, which takes the data directories of plugins.
+func inTempDir(t *testing.T) {
+	t.Helper()
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(dir)
+	})
+}
+
+func TestFailedPluginLeavesNothingBehind(t *testing.T) {
+	inTempDir(t)
+	p := NewProxy(false, DefaultConfig())
+	commands := p.commands.All()
+	glist := commands[slices.IndexFunc(commands, func(node *command.Node) bool {
+		return node.Name() == "glist"
+	})]
+
+	called := false
+	p.plugins.load([]Plugin{{
+		ID: "broken",
+		Init: func(p *Proxy) error {
+			event.Subscribe(p.eventBus, event.Moderate, func(e *event.ProxyReloadEvent) { called = true })
+			p.commands.Register(command.Literal("broken"))
+			p.commands.Register(command.Literal("glist"))
+			_ = p.channels.Register("broken:channel")
+			return errors.New("broken")
+		},
+	}})
+
+	p.eventBus.Fire(&event.ProxyReloadEvent{})
+	if called {
+		t.Error("handler of the plugin is still subscribed")
+	}
+
+	for _, node := range p.commands.All() {
+		if node.Name() == "broken" {
+			t.Error("command of the plugin is still registered")
+		}
+		if node.Name() == "glist" && node != glist {
+			t.Error("command the plugin replaced wasn't registered again")
+		}
+	}
+
+	if p.channels.Registered("broken:channel") {
+		t.Error("channel of the plugin is still registered")
+	}
+	if !p.channels.Registered(BungeeCordChannel) {
+		t.Error("channel registered before the plugin was unregistered")
+	}
+
+	if plugin := p.Plugin("broken"); plugin == nil || plugin.Loaded() {
+		t.Error("plugin is loaded")
+	}
+}
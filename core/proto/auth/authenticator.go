@@ -1,13 +1,20 @@
 package auth
 
-import "net/http"
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
 
 const (
 	Success = Result(iota)
 	Fail
 	AuthServerUnavailible
 
-	url = "https://sessionserver.mojang.com/session/minecraft/hasJoined?"
+	sessionServer = "https://sessionserver.mojang.com/session/minecraft/hasJoined?"
 )
 
 type Authenticator struct {
@@ -23,42 +30,80 @@ func NewAuthenticator(user string, sharedSecret []byte, publicKey []byte) *Authe
 type Result byte
 
 type AuthenticationResult struct {
-	Result     Result
-	ID         string      `json:"id"`
-	Name       string      `json:"name"`
-	Properties *Properties `json:"properties"`
+	Result Result `json:"-"`
+	GameProfile
 }
 
 type Properties struct {
+	Name      string `json:"name"`
 	Value     string `json:"value"`
-	Signature string `json:"signature"`
+	Signature string `json:"signature,omitempty"`
 }
 
 func (a Authenticator) Authenticate() (*AuthenticationResult, error) {
-	requestUrl := url + "name=" + a.user + "&serverId=" + a.generateServerHash()
-	resp, err := http.Get(requestUrl)
+	query := url.Values{}
+	query.Set("username", a.user)
+	query.Set("serverId", a.generateServerHash())
+
+	resp, err := http.Get(sessionServer + query.Encode())
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	result := &AuthenticationResult{}
 
 	switch resp.StatusCode {
 	case 200:
 		{
+			if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+				return nil, err
+			}
 			result.Result = Success
 		}
 	case 204:
 		{
 			result.Result = Fail
 		}
-	case 503:
+	default:
 		{
 			result.Result = AuthServerUnavailible
 		}
 	}
+
+	return result, nil
 }
 
+// generateServerHash makes Minecraft's hex digest of the (empty) server id, shared secret and public key.
 func (a Authenticator) generateServerHash() string {
-	return ""
+	sha := sha1.New()
+
+	sha.Write(a.sharedSecret)
+	sha.Write(a.publicKey)
+	hash := sha.Sum(nil)
+
+	negative := (hash[0] & 0x80) == 0x80
+	if negative {
+		hash = twosComplement(hash)
+	}
+
+	// Trim away zeroes
+	res := strings.TrimLeft(hex.EncodeToString(hash), "0")
+	if negative {
+		res = "-" + res
+	}
+
+	return res
+}
+
+func twosComplement(p []byte) []byte {
+	carry := true
+	for i := len(p) - 1; i >= 0; i-- {
+		p[i] = ^p[i]
+		if carry {
+			carry = p[i] == 0xff
+			p[i]++
+		}
+	}
+	return p
 }
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"crypto/md5"
+	"github.com/google/uuid"
+)
+
+// GameProfile is the identity of a player: its uuid, name and properties like the signed skin.
+type GameProfile struct {
+	ID         uuid.UUID    `json:"id"`
+	Name       string       `json:"name"`
+	Properties []Properties `json:"properties"`
+}
+
+// OfflineProfile makes the profile an offline mode server gives the player, without properties.
+func OfflineProfile(name string) *GameProfile {
+	return &GameProfile{ID: OfflineUUID(name), Name: name}
+}
+
+// OfflineUUID is the name based (version 3) uuid of "OfflinePlayer:<name>", like vanilla servers make it.
+func OfflineUUID(name string) uuid.UUID {
+	id := uuid.UUID(md5.Sum([]byte("OfflinePlayer:" + name)))
+	id[6] = id[6]&0x0f | 0x30
+	id[8] = id[8]&0x3f | 0x80
+
+	return id
+}
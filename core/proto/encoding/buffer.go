@@ -23,7 +23,8 @@ func (b *Buffer) Read(types1 ...DataType) error {
 	return nil
 }
 
-func (b *Buffer) write(id byte, types1 ...DataType) error {
+// WriteWithID writes the id as a Varint followed by the types.
+func (b *Buffer) WriteWithID(id byte, types1 ...DataType) error {
 	Varint(int(id)).Write(b)
 	for _, typ := range types1 {
 		//write the data
@@ -45,7 +46,7 @@ func (b *Buffer) ReadByte() (byte, error) {
 }
 
 func (b *Buffer) ReadBytes(amount int) ([]byte, error) {
-	if b.index > (len(b.Data) - 1) {
+	if amount < 0 || b.index+amount > len(b.Data) {
 		return nil, io.EOF
 	}
 
@@ -55,12 +56,32 @@ func (b *Buffer) ReadBytes(amount int) ([]byte, error) {
 	return bb, nil
 }
 
-func (b *Buffer) WriteByte(byt ...byte) {
+// Unread returns the data not read yet without consuming it.
+func (b *Buffer) Unread() []byte {
+	if b.index >= len(b.Data) {
+		return nil
+	}
+
+	return b.Data[b.index:]
+}
+
+func (b *Buffer) Remaining() []byte {
+	if b.index >= len(b.Data) {
+		return nil
+	}
+
+	bb := b.Data[b.index:]
+	b.index = len(b.Data)
+
+	return bb
+}
+
+func (b *Buffer) WriteBytes(byt ...byte) {
 	b.Data = append(b.Data, byt...)
 }
 
 func (b *Buffer) WriteWithLength(id byte, types1 ...DataType) error {
-	err := b.write(id, types1...)
+	err := b.WriteWithID(id, types1...)
 	if err != nil {
 		return err
 	}
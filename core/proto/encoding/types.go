@@ -2,7 +2,7 @@ package encoding
 
 import (
 	"errors"
-	"unicode/utf16"
+	"io"
 )
 
 type (
@@ -11,6 +11,11 @@ type (
 		Write(buffer *Buffer)
 		Skip(buffer *Buffer) error
 	}
+	// VersionedType is implemented by types whose encoding depends on the protocol version.
+	VersionedType interface {
+		ReadVersioned(buffer *Buffer, protocol int) error
+		WriteVersioned(buffer *Buffer, protocol int) error
+	}
 	Byte      byte
 	Varint    int32
 	UShort    uint16
@@ -18,6 +23,7 @@ type (
 	String    string
 	ByteArray []byte
 	Boolean   bool
+	UUID      [16]byte
 )
 
 func (b *Byte) Read(buffer *Buffer) error {
@@ -30,7 +36,7 @@ func (b *Byte) Read(buffer *Buffer) error {
 }
 
 func (b Byte) Write(buffer *Buffer) {
-	buffer.WriteByte(byte(b))
+	buffer.WriteBytes(byte(b))
 }
 
 func (b Byte) Skip(buffer *Buffer) error {
@@ -65,11 +71,11 @@ func (v Varint) Write(buffer *Buffer) {
 	number := int32(v)
 	for {
 		if (number & ^0x7F) == 0 {
-			buffer.WriteByte(byte(number))
+			buffer.WriteBytes(byte(number))
 			return
 		}
 
-		buffer.WriteByte(byte(number&0x7F | 0x80))
+		buffer.WriteBytes(byte(number&0x7F | 0x80))
 
 		number >>= 7
 	}
@@ -90,6 +96,27 @@ func (v Varint) Skip(buffer *Buffer) error {
 	return nil
 }
 
+// ReadVarint reads a Varint from a stream, for reading packet lengths off a connection.
+func ReadVarint(r io.ByteReader) (int, error) {
+	var result int
+	var shift uint
+
+	for i := 0; i < 5; i++ {
+		current, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		result |= int(current&0x7F) << shift
+
+		if current&0x80 == 0 {
+			return int(int32(result)), nil
+		}
+		shift += 7
+	}
+
+	return 0, errors.New("varint too big")
+}
+
 func (v Varint) WriteIntoSlice(slice *[]byte) {
 	number := int32(v)
 	for {
@@ -139,7 +166,7 @@ func (v *UShort) Read(buffer *Buffer) error {
 
 func (v UShort) Write(buffer *Buffer) {
 	val := uint16(v)
-	buffer.WriteByte(byte(val>>8), byte(val))
+	buffer.WriteBytes(byte(val>>8), byte(val))
 }
 
 func (v UShort) Skip(buffer *Buffer) error {
@@ -165,7 +192,7 @@ func (v *Long) Read(buffer *Buffer) error {
 func (v Long) Write(buffer *Buffer) {
 	val := int64(v)
 
-	buffer.WriteByte(byte(val>>56), byte(val>>48), byte(val>>40), byte(val>>32),
+	buffer.WriteBytes(byte(val>>56), byte(val>>48), byte(val>>40), byte(val>>32),
 		byte(val>>24), byte(val>>16), byte(val>>8), byte(val))
 }
 
@@ -200,12 +227,11 @@ func (v *String) Read(buffer *Buffer) error {
 
 func (v String) Write(buffer *Buffer) {
 	val := string(v)
-	utf16Length := len(utf16.Encode([]rune(val)))
 
-	// Write the length as a Varint
-	Varint(utf16Length).Write(buffer)
+	// Write the length in bytes as a Varint
+	Varint(len(val)).Write(buffer)
 
-	buffer.WriteByte([]byte(val)...)
+	buffer.WriteBytes([]byte(val)...)
 }
 
 func (v String) Skip(buffer *Buffer) error {
@@ -239,7 +265,7 @@ func (b *ByteArray) Read(buffer *Buffer) error {
 func (b ByteArray) Write(buffer *Buffer) {
 	leng := Varint(len(b))
 	leng.Write(buffer)
-	buffer.WriteByte(b...)
+	buffer.WriteBytes(b...)
 }
 
 func (b ByteArray) Skip(buffer *Buffer) error {
@@ -263,9 +289,9 @@ func (b *Boolean) Read(buffer *Buffer) error {
 
 func (b Boolean) Write(buffer *Buffer) {
 	if b {
-		buffer.WriteByte(1)
+		buffer.WriteBytes(1)
 	} else {
-		buffer.WriteByte(0)
+		buffer.WriteBytes(0)
 	}
 }
 
@@ -273,3 +299,23 @@ func (b Boolean) Skip(buffer *Buffer) error {
 	buffer.index++
 	return nil
 }
+
+func (u *UUID) Read(buffer *Buffer) error {
+	bytes, err := buffer.ReadBytes(16)
+	if err != nil {
+		return err
+	}
+
+	copy(u[:], bytes)
+
+	return nil
+}
+
+func (u UUID) Write(buffer *Buffer) {
+	buffer.WriteBytes(u[:]...)
+}
+
+func (u UUID) Skip(buffer *Buffer) error {
+	buffer.index += 16
+	return nil
+}
@@ -0,0 +1,47 @@
+package encryption
+
+import "crypto/cipher"
+
+// cfb8 is AES in 8 bit cipher feedback mode, which Minecraft uses and crypto/cipher doesn't implement.
+type cfb8 struct {
+	block   cipher.Block
+	iv      []byte
+	tmp     []byte
+	decrypt bool
+}
+
+// NewCFB8Encrypter returns a stream encrypting with the block, the key doubling as iv.
+func NewCFB8Encrypter(block cipher.Block, iv []byte) cipher.Stream {
+	return newCFB8(block, iv, false)
+}
+
+// NewCFB8Decrypter returns a stream decrypting with the block, the key doubling as iv.
+func NewCFB8Decrypter(block cipher.Block, iv []byte) cipher.Stream {
+	return newCFB8(block, iv, true)
+}
+
+func newCFB8(block cipher.Block, iv []byte, decrypt bool) *cfb8 {
+	return &cfb8{
+		block:   block,
+		iv:      append([]byte(nil), iv...),
+		tmp:     make([]byte, block.BlockSize()),
+		decrypt: decrypt,
+	}
+}
+
+func (x *cfb8) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		x.block.Encrypt(x.tmp, x.iv)
+		in := src[i]
+		out := in ^ x.tmp[0]
+
+		copy(x.iv, x.iv[1:])
+		if x.decrypt {
+			x.iv[len(x.iv)-1] = in
+		} else {
+			x.iv[len(x.iv)-1] = out
+		}
+
+		dst[i] = out
+	}
+}
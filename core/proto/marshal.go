@@ -0,0 +1,441 @@
+package proto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopro/core/proto/encoding"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Definition is implemented by packet structs that know their own packet id.
+type Definition interface {
+	ID(protocol int) byte
+}
+
+// Packet structs are (un)marshalled field by field in declaration order. A field is
+// encoded according to its `mc` tag:
+//
+//	mc:"<kind>[,optional][,since=<protocol>][,until=<protocol>]"
+//
+// kind may be omitted when the field already is an encoding.DataType, an encoding.VersionedType
+// or a nested struct.
+// Otherwise it is one of the names in kinds, plus "json" (the value is sent as a JSON
+// encoded string) and "rest" (a []byte that swallows the rest of the packet).
+// optional fields must be pointers and are prefixed with a boolean telling whether they are present.
+// since and until restrict the field to protocol versions in [since, until).
+// Slices other than []byte are prefixed with their length as a varint.
+// Fields tagged with mc:"-" and unexported fields are ignored.
+
+var kinds = map[string]reflect.Type{
+	"byte":   reflect.TypeOf(encoding.Byte(0)),
+	"bool":   reflect.TypeOf(encoding.Boolean(false)),
+	"varint": reflect.TypeOf(encoding.Varint(0)),
+	"ushort": reflect.TypeOf(encoding.UShort(0)),
+	"long":   reflect.TypeOf(encoding.Long(0)),
+	"string": reflect.TypeOf(encoding.String("")),
+	"bytes":  reflect.TypeOf(encoding.ByteArray(nil)),
+	"uuid":   reflect.TypeOf(encoding.UUID{}),
+}
+
+var dataType = reflect.TypeOf((*encoding.DataType)(nil)).Elem()
+var versionedType = reflect.TypeOf((*encoding.VersionedType)(nil)).Elem()
+
+// writer is the part of encoding.DataType that is implemented on the value receiver.
+type writer interface {
+	Write(buffer *encoding.Buffer)
+}
+
+// versionedWriter is the part of encoding.VersionedType that is implemented on the value receiver.
+type versionedWriter interface {
+	WriteVersioned(buffer *encoding.Buffer, protocol int) error
+}
+
+type field struct {
+	index    int
+	name     string
+	codec    codec
+	optional bool
+	since    int
+	until    int
+}
+
+type codec interface {
+	read(buffer *encoding.Buffer, protocol int, v reflect.Value) error
+	write(buffer *encoding.Buffer, protocol int, v reflect.Value) error
+}
+
+var structCodecs sync.Map
+
+// Marshal writes the fields of v into a new packet with the given id.
+func Marshal(id byte, protocol int, v any) (*Packet, error) {
+	val := reflect.Indirect(reflect.ValueOf(v))
+	if val.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot marshal %T, expected a struct", v)
+	}
+
+	c, err := codecForStruct(val.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	pk := Packet{ID: id, buffer: encoding.NewBuffer([]byte{})}
+	if err := c.write(pk.buffer, protocol, val); err != nil {
+		return nil, err
+	}
+
+	// the fields are already in the buffer, move them behind the id and length
+	data := rawBytes(pk.buffer.Data)
+	pk.buffer.Data = []byte{}
+	if err := pk.Write(&data); err != nil {
+		return nil, err
+	}
+
+	return &pk, nil
+}
+
+// MarshalDefinition is Marshal using the id of the definition.
+func MarshalDefinition(protocol int, def Definition) (*Packet, error) {
+	return Marshal(def.ID(protocol), protocol, def)
+}
+
+// Unmarshal reads the remaining packet data into the struct v points to.
+func (packet *Packet) Unmarshal(protocol int, v any) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Pointer || ptr.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unmarshal into %T, expected a pointer to a struct", v)
+	}
+
+	c, err := codecForStruct(ptr.Elem().Type())
+	if err != nil {
+		return err
+	}
+
+	return c.read(packet.buffer, protocol, ptr.Elem())
+}
+
+type rawBytes []byte
+
+func (r *rawBytes) Read(buffer *encoding.Buffer) error {
+	*r = append((*r)[:0], buffer.Remaining()...)
+	return nil
+}
+
+func (r rawBytes) Write(buffer *encoding.Buffer) {
+	buffer.WriteBytes(r...)
+}
+
+func (r rawBytes) Skip(buffer *encoding.Buffer) error {
+	buffer.Remaining()
+	return nil
+}
+
+type structCodec struct {
+	fields []field
+	// ready is closed once the fields are parsed. The codec is cached before, so fields of
+	// recursive structs get it instead of parsing the struct again.
+	ready chan struct{}
+	err   error
+}
+
+func codecForStruct(t reflect.Type) (*structCodec, error) {
+	if c, ok := structCodecs.Load(t); ok {
+		return c.(*structCodec), nil
+	}
+
+	c := &structCodec{ready: make(chan struct{})}
+	if cached, loaded := structCodecs.LoadOrStore(t, c); loaded {
+		return cached.(*structCodec), nil
+	}
+	defer close(c.ready)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, tagged := f.Tag.Lookup("mc")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+
+		parsed, err := parseField(f, tag, tagged)
+		if err != nil {
+			c.err = fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+			structCodecs.Delete(t)
+			return nil, c.err
+		}
+
+		parsed.index = i
+		c.fields = append(c.fields, parsed)
+	}
+
+	return c, nil
+}
+
+// wait waits for the fields of the codec, which another goroutine may still be parsing.
+func (c *structCodec) wait() error {
+	<-c.ready
+	return c.err
+}
+
+func parseField(f reflect.StructField, tag string, tagged bool) (field, error) {
+	fd := field{name: f.Name}
+	kind := ""
+
+	if tagged {
+		parts := strings.Split(tag, ",")
+		kind = parts[0]
+		for _, opt := range parts[1:] {
+			key, value, _ := strings.Cut(opt, "=")
+			switch key {
+			case "optional":
+				fd.optional = true
+			case "since", "until":
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return fd, fmt.Errorf("invalid %s protocol %q", key, value)
+				}
+				if key == "since" {
+					fd.since = n
+				} else {
+					fd.until = n
+				}
+			default:
+				return fd, fmt.Errorf("unknown option %q", key)
+			}
+		}
+	}
+
+	t := f.Type
+	if fd.optional {
+		if t.Kind() != reflect.Pointer {
+			return fd, errors.New("optional fields must be pointers")
+		}
+		t = t.Elem()
+	}
+
+	c, err := codecFor(t, kind)
+	if err != nil {
+		return fd, err
+	}
+
+	fd.codec = c
+	return fd, nil
+}
+
+func codecFor(t reflect.Type, kind string) (codec, error) {
+	switch kind {
+	case "json":
+		return jsonCodec{}, nil
+	case "rest":
+		if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uint8 {
+			return nil, errors.New("rest fields must be byte slices")
+		}
+		return restCodec{}, nil
+	case "":
+	default:
+		kindType, ok := kinds[kind]
+		if !ok {
+			return nil, fmt.Errorf("unknown kind %q", kind)
+		}
+		if !t.ConvertibleTo(kindType) {
+			return nil, fmt.Errorf("%s is not convertible to %s", t, kind)
+		}
+		return convertCodec{kind: kindType}, nil
+	}
+
+	switch {
+	case reflect.PointerTo(t).Implements(versionedType):
+		return versionedCodec{}, nil
+	case reflect.PointerTo(t).Implements(dataType):
+		return dataTypeCodec{}, nil
+	case t.Kind() == reflect.Struct:
+		return codecForStruct(t)
+	case t.Kind() == reflect.Slice:
+		elem, err := codecFor(t.Elem(), "")
+		if err != nil {
+			return nil, err
+		}
+		return sliceCodec{elem: elem}, nil
+	}
+
+	return nil, fmt.Errorf("%s has no mc kind", t)
+}
+
+func (f field) present(protocol int) bool {
+	return (f.since == 0 || protocol >= f.since) && (f.until == 0 || protocol < f.until)
+}
+
+func (c *structCodec) read(buffer *encoding.Buffer, protocol int, v reflect.Value) error {
+	if err := c.wait(); err != nil {
+		return err
+	}
+
+	for _, f := range c.fields {
+		if !f.present(protocol) {
+			continue
+		}
+
+		target := v.Field(f.index)
+		if f.optional {
+			var present encoding.Boolean
+			if err := present.Read(buffer); err != nil {
+				return fmt.Errorf("reading %s: %w", f.name, err)
+			}
+			if !present {
+				target.SetZero()
+				continue
+			}
+			target.Set(reflect.New(target.Type().Elem()))
+			target = target.Elem()
+		}
+
+		if err := f.codec.read(buffer, protocol, target); err != nil {
+			return fmt.Errorf("reading %s: %w", f.name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *structCodec) write(buffer *encoding.Buffer, protocol int, v reflect.Value) error {
+	if err := c.wait(); err != nil {
+		return err
+	}
+
+	for _, f := range c.fields {
+		if !f.present(protocol) {
+			continue
+		}
+
+		source := v.Field(f.index)
+		if f.optional {
+			encoding.Boolean(!source.IsNil()).Write(buffer)
+			if source.IsNil() {
+				continue
+			}
+			source = source.Elem()
+		}
+
+		if err := f.codec.write(buffer, protocol, source); err != nil {
+			return fmt.Errorf("writing %s: %w", f.name, err)
+		}
+	}
+
+	return nil
+}
+
+type dataTypeCodec struct{}
+
+func (dataTypeCodec) read(buffer *encoding.Buffer, _ int, v reflect.Value) error {
+	return v.Addr().Interface().(encoding.DataType).Read(buffer)
+}
+
+func (dataTypeCodec) write(buffer *encoding.Buffer, _ int, v reflect.Value) error {
+	v.Interface().(writer).Write(buffer)
+	return nil
+}
+
+type versionedCodec struct{}
+
+func (versionedCodec) read(buffer *encoding.Buffer, protocol int, v reflect.Value) error {
+	return v.Addr().Interface().(encoding.VersionedType).ReadVersioned(buffer, protocol)
+}
+
+func (versionedCodec) write(buffer *encoding.Buffer, protocol int, v reflect.Value) error {
+	return v.Interface().(versionedWriter).WriteVersioned(buffer, protocol)
+}
+
+type convertCodec struct {
+	kind reflect.Type
+}
+
+func (c convertCodec) read(buffer *encoding.Buffer, _ int, v reflect.Value) error {
+	value := reflect.New(c.kind)
+	if err := value.Interface().(encoding.DataType).Read(buffer); err != nil {
+		return err
+	}
+
+	v.Set(value.Elem().Convert(v.Type()))
+	return nil
+}
+
+func (c convertCodec) write(buffer *encoding.Buffer, _ int, v reflect.Value) error {
+	v.Convert(c.kind).Interface().(writer).Write(buffer)
+	return nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) read(buffer *encoding.Buffer, _ int, v reflect.Value) error {
+	var s encoding.String
+	if err := s.Read(buffer); err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(s), v.Addr().Interface())
+}
+
+func (jsonCodec) write(buffer *encoding.Buffer, _ int, v reflect.Value) error {
+	val, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+
+	encoding.String(val).Write(buffer)
+	return nil
+}
+
+type restCodec struct{}
+
+func (restCodec) read(buffer *encoding.Buffer, _ int, v reflect.Value) error {
+	v.SetBytes(append([]byte(nil), buffer.Remaining()...))
+	return nil
+}
+
+func (restCodec) write(buffer *encoding.Buffer, _ int, v reflect.Value) error {
+	buffer.WriteBytes(v.Bytes()...)
+	return nil
+}
+
+type sliceCodec struct {
+	elem codec
+}
+
+func (c sliceCodec) read(buffer *encoding.Buffer, protocol int, v reflect.Value) error {
+	var length encoding.Varint
+	if err := length.Read(buffer); err != nil {
+		return err
+	}
+
+	// every element takes a byte at least, longer arrays can't be in the packet
+	if length < 0 || int(length) > len(buffer.Unread()) {
+		return fmt.Errorf("invalid array length %d", length)
+	}
+
+	// the slice grows with the elements read, the length alone doesn't allocate
+	slice := reflect.MakeSlice(v.Type(), 0, 0)
+	elem := reflect.New(v.Type().Elem()).Elem()
+	for i := 0; i < int(length); i++ {
+		elem.SetZero()
+		if err := c.elem.read(buffer, protocol, elem); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, elem)
+	}
+
+	v.Set(slice)
+	return nil
+}
+
+func (c sliceCodec) write(buffer *encoding.Buffer, protocol int, v reflect.Value) error {
+	encoding.Varint(v.Len()).Write(buffer)
+	for i := 0; i < v.Len(); i++ {
+		if err := c.elem.write(buffer, protocol, v.Index(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
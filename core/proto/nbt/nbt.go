@@ -0,0 +1,311 @@
+package nbt
+
+import (
+	"errors"
+	"fmt"
+	"gopro/core/proto/encoding"
+	"math"
+	"sort"
+)
+
+const (
+	TagEnd = byte(iota)
+	TagByte
+	TagShort
+	TagInt
+	TagLong
+	TagFloat
+	TagDouble
+	TagByteArray
+	TagString
+	TagList
+	TagCompound
+	TagIntArray
+	TagLongArray
+)
+
+// maxDepth limits the nesting of lists and compounds read from the network.
+const maxDepth = 512
+
+// Write writes value as network NBT, which since 1.20.2 has no name on the root tag.
+//
+// Values map to tags as follows: bool and int8 to Byte, int16 to Short, int32 and int to Int,
+// int64 to Long, float32 to Float, float64 to Double, []byte to ByteArray, string to String,
+// []any to List, map[string]any to Compound, []int32 to IntArray and []int64 to LongArray.
+func Write(buffer *encoding.Buffer, value any) error {
+	id, err := tagOf(value)
+	if err != nil {
+		return err
+	}
+
+	buffer.WriteBytes(id)
+	return writePayload(buffer, value)
+}
+
+// Read reads network NBT, mapping tags to the types listed on Write. Bytes are read as int8.
+func Read(buffer *encoding.Buffer) (any, error) {
+	id, err := buffer.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	return readPayload(buffer, id, 0)
+}
+
+func tagOf(value any) (byte, error) {
+	switch value.(type) {
+	case bool, int8:
+		return TagByte, nil
+	case int16:
+		return TagShort, nil
+	case int32, int:
+		return TagInt, nil
+	case int64:
+		return TagLong, nil
+	case float32:
+		return TagFloat, nil
+	case float64:
+		return TagDouble, nil
+	case []byte:
+		return TagByteArray, nil
+	case string:
+		return TagString, nil
+	case []any:
+		return TagList, nil
+	case map[string]any:
+		return TagCompound, nil
+	case []int32:
+		return TagIntArray, nil
+	case []int64:
+		return TagLongArray, nil
+	}
+
+	return TagEnd, fmt.Errorf("no nbt tag for %T", value)
+}
+
+func writePayload(buffer *encoding.Buffer, value any) error {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			buffer.WriteBytes(1)
+		} else {
+			buffer.WriteBytes(0)
+		}
+	case int8:
+		buffer.WriteBytes(byte(v))
+	case int16:
+		encoding.UShort(v).Write(buffer)
+	case int32:
+		writeInt(buffer, v)
+	case int:
+		writeInt(buffer, int32(v))
+	case int64:
+		encoding.Long(v).Write(buffer)
+	case float32:
+		writeInt(buffer, int32(math.Float32bits(v)))
+	case float64:
+		encoding.Long(math.Float64bits(v)).Write(buffer)
+	case []byte:
+		writeInt(buffer, int32(len(v)))
+		buffer.WriteBytes(v...)
+	case string:
+		return writeString(buffer, v)
+	case []any:
+		return writeList(buffer, v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			id, err := tagOf(v[key])
+			if err != nil {
+				return err
+			}
+			buffer.WriteBytes(id)
+			if err := writeString(buffer, key); err != nil {
+				return err
+			}
+			if err := writePayload(buffer, v[key]); err != nil {
+				return err
+			}
+		}
+		buffer.WriteBytes(TagEnd)
+	case []int32:
+		writeInt(buffer, int32(len(v)))
+		for _, i := range v {
+			writeInt(buffer, i)
+		}
+	case []int64:
+		writeInt(buffer, int32(len(v)))
+		for _, l := range v {
+			encoding.Long(l).Write(buffer)
+		}
+	default:
+		return fmt.Errorf("no nbt tag for %T", value)
+	}
+
+	return nil
+}
+
+func writeList(buffer *encoding.Buffer, list []any) error {
+	if len(list) == 0 {
+		buffer.WriteBytes(TagEnd)
+		writeInt(buffer, 0)
+		return nil
+	}
+
+	id, err := tagOf(list[0])
+	if err != nil {
+		return err
+	}
+
+	for _, elem := range list[1:] {
+		if elemID, _ := tagOf(elem); elemID != id {
+			return errors.New("nbt lists must hold elements of one type")
+		}
+	}
+
+	buffer.WriteBytes(id)
+	writeInt(buffer, int32(len(list)))
+	for _, elem := range list {
+		if err := writePayload(buffer, elem); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeInt(buffer *encoding.Buffer, i int32) {
+	buffer.WriteBytes(byte(i>>24), byte(i>>16), byte(i>>8), byte(i))
+}
+
+func readInt(buffer *encoding.Buffer) (int32, error) {
+	b, err := buffer.ReadBytes(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return int32(b[0])<<24 | int32(b[1])<<16 | int32(b[2])<<8 | int32(b[3]), nil
+}
+
+func readLength(buffer *encoding.Buffer) (int, error) {
+	length, err := readInt(buffer)
+	if err != nil {
+		return 0, err
+	}
+
+	if length < 0 {
+		return 0, errors.New("negative nbt length")
+	}
+
+	return int(length), nil
+}
+
+func readPayload(buffer *encoding.Buffer, id byte, depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, errors.New("nbt nested too deep")
+	}
+
+	switch id {
+	case TagByte:
+		b, err := buffer.ReadByte()
+		return int8(b), err
+	case TagShort:
+		var s encoding.UShort
+		err := s.Read(buffer)
+		return int16(s), err
+	case TagInt:
+		return readInt(buffer)
+	case TagLong:
+		var l encoding.Long
+		err := l.Read(buffer)
+		return int64(l), err
+	case TagFloat:
+		i, err := readInt(buffer)
+		return math.Float32frombits(uint32(i)), err
+	case TagDouble:
+		var l encoding.Long
+		err := l.Read(buffer)
+		return math.Float64frombits(uint64(l)), err
+	case TagByteArray:
+		length, err := readLength(buffer)
+		if err != nil {
+			return nil, err
+		}
+		b, err := buffer.ReadBytes(length)
+		return append([]byte(nil), b...), err
+	case TagString:
+		return readString(buffer)
+	case TagList:
+		elemID, err := buffer.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length, err := readLength(buffer)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]any, 0, min(length, 1024))
+		for i := 0; i < length; i++ {
+			elem, err := readPayload(buffer, elemID, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, elem)
+		}
+		return list, nil
+	case TagCompound:
+		compound := make(map[string]any)
+		for {
+			elemID, err := buffer.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if elemID == TagEnd {
+				return compound, nil
+			}
+			key, err := readString(buffer)
+			if err != nil {
+				return nil, err
+			}
+			compound[key], err = readPayload(buffer, elemID, depth+1)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case TagIntArray:
+		length, err := readLength(buffer)
+		if err != nil {
+			return nil, err
+		}
+		ints := make([]int32, 0, min(length, 1024))
+		for i := 0; i < length; i++ {
+			v, err := readInt(buffer)
+			if err != nil {
+				return nil, err
+			}
+			ints = append(ints, v)
+		}
+		return ints, nil
+	case TagLongArray:
+		length, err := readLength(buffer)
+		if err != nil {
+			return nil, err
+		}
+		longs := make([]int64, 0, min(length, 1024))
+		for i := 0; i < length; i++ {
+			var l encoding.Long
+			if err := l.Read(buffer); err != nil {
+				return nil, err
+			}
+			longs = append(longs, int64(l))
+		}
+		return longs, nil
+	}
+
+	return nil, fmt.Errorf("unknown nbt tag %d", id)
+}
@@ -0,0 +1,79 @@
+package nbt
+
+import (
+	"errors"
+	"gopro/core/proto/encoding"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// NBT strings are in Java's modified UTF-8: NUL takes two bytes and characters outside the
+// BMP are written as two three byte surrogates.
+
+func writeString(buffer *encoding.Buffer, s string) error {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == 0:
+			out = append(out, 0xC0, 0x80)
+		case r >= 0x10000:
+			r1, r2 := utf16.EncodeRune(r)
+			out = appendSurrogate(out, r1)
+			out = appendSurrogate(out, r2)
+		default:
+			out = utf8.AppendRune(out, r)
+		}
+	}
+
+	if len(out) > 0xFFFF {
+		return errors.New("nbt string too long")
+	}
+
+	encoding.UShort(len(out)).Write(buffer)
+	buffer.WriteBytes(out...)
+	return nil
+}
+
+func appendSurrogate(out []byte, r rune) []byte {
+	return append(out, byte(0xE0|(r>>12)), byte(0x80|((r>>6)&0x3F)), byte(0x80|(r&0x3F)))
+}
+
+func readString(buffer *encoding.Buffer) (string, error) {
+	var length encoding.UShort
+	if err := length.Read(buffer); err != nil {
+		return "", err
+	}
+
+	b, err := buffer.ReadBytes(int(length))
+	if err != nil {
+		return "", err
+	}
+
+	runes := make([]rune, 0, len(b))
+	for i := 0; i < len(b); {
+		switch {
+		case b[i] < 0x80:
+			runes = append(runes, rune(b[i]))
+			i++
+		case b[i]&0xE0 == 0xC0 && i+1 < len(b):
+			runes = append(runes, rune(b[i]&0x1F)<<6|rune(b[i+1]&0x3F))
+			i += 2
+		case b[i]&0xF0 == 0xE0 && i+2 < len(b):
+			runes = append(runes, rune(b[i]&0x0F)<<12|rune(b[i+1]&0x3F)<<6|rune(b[i+2]&0x3F))
+			i += 3
+		default:
+			return "", errors.New("invalid modified utf-8 in nbt string")
+		}
+	}
+
+	// joins the surrogate pairs
+	return string(utf16.Decode(runesToUTF16(runes))), nil
+}
+
+func runesToUTF16(runes []rune) []uint16 {
+	units := make([]uint16, len(runes))
+	for i, r := range runes {
+		units[i] = uint16(r)
+	}
+	return units
+}
@@ -33,14 +33,38 @@ func (packet *Packet) Read(types ...encoding.DataType) error {
 	return packet.buffer.Read(types...)
 }
 
+// Raw makes a packet out of an id and already encoded data, for forwarding packets as they are.
+func Raw(id byte, payload []byte) *Packet {
+	pk := Packet{ID: id, buffer: encoding.NewBuffer([]byte{})}
+	data := rawBytes(payload)
+	_ = pk.Write(&data)
+
+	return &pk
+}
+
 func (packet *Packet) Write(types ...encoding.DataType) error {
-	return packet.buffer.WriteWithLength(packet.ID, types...)
+	return packet.buffer.WriteWithID(packet.ID, types...)
 }
 
+// Bytes returns the packet framed with its length, as it is sent without compression.
 func (packet *Packet) Bytes() []byte {
+	length := encoding.Varint(len(packet.buffer.Data))
+	framed := make([]byte, 0, length.Len()+len(packet.buffer.Data))
+	length.WriteIntoSlice(&framed)
+
+	return append(framed, packet.buffer.Data...)
+}
+
+// Body returns the id and data of the packet without framing.
+func (packet *Packet) Body() []byte {
 	return packet.buffer.Data
 }
 
+// Payload returns the data of the packet that wasn't read yet.
+func (packet *Packet) Payload() []byte {
+	return packet.buffer.Unread()
+}
+
 func (packet *Packet) Skip(types ...encoding.DataType) error {
 	err := packet.buffer.Skip(types...)
 	if err != nil {
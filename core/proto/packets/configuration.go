@@ -0,0 +1,88 @@
+package packets
+
+import (
+	"gopro/core/component"
+	"gopro/core/proto/encoding"
+)
+
+// ConfigDisconnect is the Disconnect packet of the configuration state added in 1.20.2.
+type ConfigDisconnect struct {
+	Reason component.TextComponent
+}
+
+var configDisconnectIDs = idTable{
+	{764, 0x01},
+	{766, 0x02},
+}
+
+// FinishConfiguration is sent by the server when the client may enter the play state.
+type FinishConfiguration struct{}
+
+var finishConfigurationIDs = idTable{
+	{764, 0x02},
+	{766, 0x03},
+}
+
+// ClientInformation holds the settings of the client, like its locale and view distance. It is
+// kept encoded to be sent again to the next server.
+type ClientInformation struct {
+	Data []byte `mc:"rest"`
+}
+
+// ConfigClientboundPluginMessage is a custom payload from the server in the configuration state.
+type ConfigClientboundPluginMessage struct {
+	Channel encoding.String
+	Data    []byte `mc:"rest"`
+}
+
+var configClientboundPluginMessageIDs = idTable{
+	{764, 0x00},
+	{766, 0x01},
+}
+
+// ConfigServerboundPluginMessage is a custom payload from the client in the configuration state.
+type ConfigServerboundPluginMessage struct {
+	Channel encoding.String
+	Data    []byte `mc:"rest"`
+}
+
+var configServerboundPluginMessageIDs = idTable{
+	{764, 0x01},
+	{766, 0x02},
+}
+
+// AcknowledgeFinishConfiguration is sent by the client when it enters the play state.
+type AcknowledgeFinishConfiguration struct{}
+
+var acknowledgeFinishConfigurationIDs = idTable{
+	{764, 0x02},
+	{766, 0x03},
+}
+
+func NewConfigDisconnect(reason *component.TextComponent) *ConfigDisconnect {
+	return &ConfigDisconnect{Reason: *reason}
+}
+
+func (*ConfigDisconnect) ID(protocol int) byte {
+	return configDisconnectIDs.of(protocol)
+}
+
+func (*AcknowledgeFinishConfiguration) ID(protocol int) byte {
+	return acknowledgeFinishConfigurationIDs.of(protocol)
+}
+
+func (*FinishConfiguration) ID(protocol int) byte {
+	return finishConfigurationIDs.of(protocol)
+}
+
+func (*ClientInformation) ID(int) byte {
+	return 0x00
+}
+
+func (*ConfigClientboundPluginMessage) ID(protocol int) byte {
+	return configClientboundPluginMessageIDs.of(protocol)
+}
+
+func (*ConfigServerboundPluginMessage) ID(protocol int) byte {
+	return configServerboundPluginMessageIDs.of(protocol)
+}
@@ -0,0 +1,20 @@
+package packets
+
+// idTable maps the first protocol version a packet id is used in to that id, in ascending
+// protocol order. Versions before the first entry use the first id.
+type idTable []struct {
+	since int
+	id    byte
+}
+
+func (t idTable) of(protocol int) byte {
+	id := t[0].id
+	for _, entry := range t {
+		if protocol < entry.since {
+			break
+		}
+		id = entry.id
+	}
+
+	return id
+}
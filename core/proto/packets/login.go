@@ -2,22 +2,36 @@ package packets
 
 import (
 	"gopro/core/component"
-	"gopro/core/proto"
 	"gopro/core/proto/encoding"
 )
 
 type LoginStart struct {
 	Name encoding.String
+	// 1.19 to 1.19.2 let the client sign its chat with a key it sends here
+	SignatureData *SignatureData `mc:",optional,since=759,until=761"`
+	// 1.19.1 added the uuid as optional, 1.20.2 made it mandatory
+	OptionalUUID *encoding.UUID `mc:",optional,since=760,until=764"`
+	PlayerUUID   encoding.UUID  `mc:",since=764"`
 }
 
+// SignatureData is the chat signing key clients from 1.19 to 1.19.2 may send on login.
+type SignatureData struct {
+	Timestamp int64 `mc:"long"`
+	PublicKey encoding.ByteArray
+	Signature encoding.ByteArray
+}
+
+// Disconnect is the login state Disconnect, which keeps sending JSON on every version.
 type Disconnect struct {
-	Reason encoding.String
+	Reason *component.TextComponent `mc:"json"`
 }
 
 type EncryptionRequest struct {
 	ServerId    encoding.String
 	PublicKey   encoding.ByteArray
 	VerifyToken encoding.ByteArray
+	// 1.20.5 added whether the client authenticates with Mojang
+	ShouldAuthenticate bool `mc:"bool,since=766"`
 }
 
 type EncryptionResponse struct {
@@ -25,37 +39,87 @@ type EncryptionResponse struct {
 	VerifyToken  encoding.ByteArray
 }
 
-func NewLoginStart(pk *proto.Packet) (*LoginStart, error) {
-	var ls LoginStart
+// LoginSuccess ends the login state, from 1.20.2 on once the client acknowledges it.
+type LoginSuccess struct {
+	UUID       encoding.UUID
+	Username   encoding.String
+	Properties []Property
+	// only sent from 1.20.5 to 1.21.1
+	StrictErrorHandling bool `mc:"bool,since=766,until=768"`
+}
+
+// Property is a property of a game profile, usually the signed skin.
+type Property struct {
+	Name      encoding.String
+	Value     encoding.String
+	Signature *encoding.String `mc:",optional"`
+}
 
-	err := pk.Read(&ls.Name)
-	return &ls, err
+type SetCompression struct {
+	Threshold encoding.Varint
 }
 
-func NewDisconnect(component *component.TextComponent) (*Disconnect, error) {
-	s, err := component.Serialize()
-	if err != nil {
-		return &Disconnect{}, err
-	}
+// LoginPluginRequest is a custom query of the server, answered with a LoginPluginResponse.
+type LoginPluginRequest struct {
+	MessageID encoding.Varint
+	Channel   encoding.String
+	Data      []byte `mc:"rest"`
+}
+
+type LoginPluginResponse struct {
+	MessageID  encoding.Varint
+	Successful bool   `mc:"bool"`
+	Data       []byte `mc:"rest"`
+}
 
-	return &Disconnect{Reason: encoding.String(s)}, nil
+// LoginAcknowledged moves the connection to the configuration state, from 1.20.2 on.
+type LoginAcknowledged struct{}
+
+func NewDisconnect(reason *component.TextComponent) *Disconnect {
+	return &Disconnect{Reason: reason}
 }
 
 func NewEncryptionRequest(pub []byte, verifyToken []byte) *EncryptionRequest {
 	return &EncryptionRequest{
-		ServerId:    "",
-		PublicKey:   pub,
-		VerifyToken: verifyToken,
+		ServerId:           "",
+		PublicKey:          pub,
+		VerifyToken:        verifyToken,
+		ShouldAuthenticate: true,
 	}
 }
 
-func NewEncryptionResponse(pk *proto.Packet) (*EncryptionResponse, error) {
-	var er EncryptionResponse
+func (*LoginStart) ID(int) byte {
+	return 0x00
+}
+
+func (*Disconnect) ID(int) byte {
+	return 0x00
+}
+
+func (*EncryptionRequest) ID(int) byte {
+	return 0x01
+}
+
+func (*EncryptionResponse) ID(int) byte {
+	return 0x01
+}
+
+func (*LoginSuccess) ID(int) byte {
+	return 0x02
+}
+
+func (*SetCompression) ID(int) byte {
+	return 0x03
+}
 
-	err := pk.Read(
-		&er.SharedSecret,
-		&er.VerifyToken,
-	)
+func (*LoginPluginRequest) ID(int) byte {
+	return 0x04
+}
+
+func (*LoginPluginResponse) ID(int) byte {
+	return 0x02
+}
 
-	return &er, err
+func (*LoginAcknowledged) ID(int) byte {
+	return 0x03
 }
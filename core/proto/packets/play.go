@@ -0,0 +1,200 @@
+package packets
+
+import (
+	"gopro/core/component"
+	"gopro/core/proto/encoding"
+)
+
+// PlayProtocol is the oldest protocol version (1.20.1) the ids of the play packets are known for.
+const PlayProtocol = 763
+
+type PlayDisconnect struct {
+	Reason component.TextComponent
+}
+
+type SystemChat struct {
+	Content component.TextComponent
+	Overlay bool `mc:"bool"`
+}
+
+var playDisconnectIDs = idTable{
+	{763, 0x1A},
+	{764, 0x1B},
+	{766, 0x1D},
+	{770, 0x1C},
+}
+
+var systemChatIDs = idTable{
+	{763, 0x64},
+	{764, 0x67},
+	{765, 0x69},
+	{766, 0x6C},
+	{768, 0x73},
+	{770, 0x72},
+}
+
+// StartConfiguration moves the client back to the configuration state, from 1.20.2 on.
+type StartConfiguration struct{}
+
+// AcknowledgeConfiguration is the answer of the client to StartConfiguration.
+type AcknowledgeConfiguration struct{}
+
+// SignedChatCommandProtocol is the first protocol version (1.20.5) with a separate packet for signed commands.
+const SignedChatCommandProtocol = 766
+
+// ChatCommand is a command the player ran, without the leading slash. The signatures following
+// the command before 1.20.5 are left unread.
+type ChatCommand struct {
+	Command encoding.String
+}
+
+// SignedChatCommand is a command with signed arguments, from 1.20.5 on.
+type SignedChatCommand struct {
+	Command encoding.String
+}
+
+// CommandSuggestionsRequest asks for the completions of the text, which starts with a slash.
+type CommandSuggestionsRequest struct {
+	TransactionID encoding.Varint
+	Text          encoding.String
+}
+
+type CommandSuggestionsResponse struct {
+	TransactionID encoding.Varint
+	// Start and Length are the part of the text the matches replace
+	Start   encoding.Varint
+	Length  encoding.Varint
+	Matches []SuggestionMatch
+}
+
+type SuggestionMatch struct {
+	Match   encoding.String
+	Tooltip *component.TextComponent `mc:",optional"`
+}
+
+// Commands is the Brigadier command graph of the server, kept encoded for the command package.
+type Commands struct {
+	Data []byte `mc:"rest"`
+}
+
+// ClientboundPluginMessage is a custom payload from the server, like minecraft:brand.
+type ClientboundPluginMessage struct {
+	Channel encoding.String
+	Data    []byte `mc:"rest"`
+}
+
+// ServerboundPluginMessage is a custom payload from the client.
+type ServerboundPluginMessage struct {
+	Channel encoding.String
+	Data    []byte `mc:"rest"`
+}
+
+var clientboundPluginMessageIDs = idTable{
+	{763, 0x17},
+	{764, 0x18},
+	{766, 0x19},
+	{770, 0x18},
+}
+
+var serverboundPluginMessageIDs = idTable{
+	{763, 0x0D},
+	{764, 0x0F},
+	{765, 0x10},
+	{766, 0x12},
+	{768, 0x14},
+}
+
+var startConfigurationIDs = idTable{
+	{764, 0x65},
+	{765, 0x67},
+	{766, 0x69},
+	{768, 0x70},
+	{770, 0x6F},
+}
+
+var acknowledgeConfigurationIDs = idTable{
+	{764, 0x0B},
+	{766, 0x0C},
+	{768, 0x0E},
+}
+
+var chatCommandIDs = idTable{
+	{763, 0x04},
+	{768, 0x05},
+}
+
+var signedChatCommandIDs = idTable{
+	{766, 0x05},
+	{768, 0x06},
+}
+
+var commandSuggestionsRequestIDs = idTable{
+	{763, 0x09},
+	{764, 0x0A},
+	{766, 0x0B},
+	{768, 0x0D},
+}
+
+var commandSuggestionsResponseIDs = idTable{
+	{763, 0x0F},
+	{764, 0x10},
+	{770, 0x0F},
+}
+
+var commandsIDs = idTable{
+	{763, 0x10},
+	{764, 0x11},
+	{770, 0x10},
+}
+
+func NewPlayDisconnect(reason *component.TextComponent) *PlayDisconnect {
+	return &PlayDisconnect{Reason: *reason}
+}
+
+func NewSystemChat(content *component.TextComponent, overlay bool) *SystemChat {
+	return &SystemChat{Content: *content, Overlay: overlay}
+}
+
+func (*PlayDisconnect) ID(protocol int) byte {
+	return playDisconnectIDs.of(protocol)
+}
+
+func (*SystemChat) ID(protocol int) byte {
+	return systemChatIDs.of(protocol)
+}
+
+func (*StartConfiguration) ID(protocol int) byte {
+	return startConfigurationIDs.of(protocol)
+}
+
+func (*AcknowledgeConfiguration) ID(protocol int) byte {
+	return acknowledgeConfigurationIDs.of(protocol)
+}
+
+func (*ChatCommand) ID(protocol int) byte {
+	return chatCommandIDs.of(protocol)
+}
+
+func (*SignedChatCommand) ID(protocol int) byte {
+	return signedChatCommandIDs.of(protocol)
+}
+
+func (*CommandSuggestionsRequest) ID(protocol int) byte {
+	return commandSuggestionsRequestIDs.of(protocol)
+}
+
+func (*CommandSuggestionsResponse) ID(protocol int) byte {
+	return commandSuggestionsResponseIDs.of(protocol)
+}
+
+func (*Commands) ID(protocol int) byte {
+	return commandsIDs.of(protocol)
+}
+
+func (*ClientboundPluginMessage) ID(protocol int) byte {
+	return clientboundPluginMessageIDs.of(protocol)
+}
+
+func (*ServerboundPluginMessage) ID(protocol int) byte {
+	return serverboundPluginMessageIDs.of(protocol)
+}
@@ -1,38 +1,32 @@
 package packets
 
 import (
-	"encoding/json"
-	"gopro/core/proto"
-	"gopro/core/proto/encoding"
 	"gopro/core/proto/status"
 )
 
 type StatusResponse struct {
-	JSONResponse encoding.String
+	Response *status.Response `mc:"json"`
 }
 
 type StatusPing struct {
-	Payload encoding.Long
+	Payload int64 `mc:"long"`
 }
 
-func NewStatusResponse(response *status.Response) (*StatusResponse, error) {
-	val, err := json.Marshal(response)
-
-	if err != nil {
-		return nil, err
-	}
+func NewStatusResponse(response *status.Response) *StatusResponse {
+	return &StatusResponse{Response: response}
+}
 
-	return &StatusResponse{JSONResponse: encoding.String(val)}, nil
+func (*StatusResponse) ID(int) byte {
+	return 0x00
 }
 
-func NewStatusPing(pk *proto.Packet) (*StatusPing, error) {
-	var sp StatusPing
+func (*StatusPing) ID(int) byte {
+	return 0x01
+}
 
-	if err := pk.Read(
-		&sp.Payload,
-	); err != nil {
-		return &sp, err
-	}
+// StatusRequest asks the server for its StatusResponse.
+type StatusRequest struct{}
 
-	return &sp, nil
+func (*StatusRequest) ID(int) byte {
+	return 0x00
 }
@@ -0,0 +1,13 @@
+package proto
+
+// The connection states, the handshake's next state being one of Status and Login.
+const (
+	Handshake = byte(iota)
+	Status
+	Login
+	Configuration
+	Play
+)
+
+// ConfigurationProtocol is the first protocol version (1.20.2) with the configuration state.
+const ConfigurationProtocol = 764
@@ -0,0 +1,40 @@
+package proto
+
+import "fmt"
+
+// The protocol versions the proxy supports, 1.20.1 to 1.21.5.
+const (
+	MinimumProtocol = 763
+	MaximumProtocol = 770
+)
+
+// versionNames are the newest game versions of the supported protocol versions.
+var versionNames = map[int]string{
+	763: "1.20.1",
+	764: "1.20.2",
+	765: "1.20.4",
+	766: "1.20.6",
+	767: "1.21.1",
+	768: "1.21.3",
+	769: "1.21.4",
+	770: "1.21.5",
+}
+
+// Supported tells whether the proxy speaks the protocol version.
+func Supported(protocol int) bool {
+	return protocol >= MinimumProtocol && protocol <= MaximumProtocol
+}
+
+// VersionName is the game version of the protocol version, like 1.20.4 for 765.
+func VersionName(protocol int) string {
+	if name, ok := versionNames[protocol]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("protocol %d", protocol)
+}
+
+// SupportedVersions describes the range of supported game versions, as shown in the server list.
+func SupportedVersions() string {
+	return VersionName(MinimumProtocol) + "-" + VersionName(MaximumProtocol)
+}
@@ -1,34 +1,120 @@
 package core
 
 import (
+	"fmt"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+	"gopro/core/command"
+	"gopro/core/component"
 	"gopro/core/event"
+	"gopro/core/permission"
 	"gopro/core/proto/encryption"
 	"io"
 	"net"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
+// configPath is where the proxy reads its config from.
+const configPath = "config.json"
+
 type Proxy struct {
 	debug  bool
 	logger zerolog.Logger
+	output *logOutput
+	// restoreConsole gives the terminal back, set while the console reads from it
+	restoreConsole func()
+
+	eventBus  *event.Bus
+	keypair   *encryption.Keypair
+	plugins   *pluginManager
+	scheduler *Scheduler
+	commands  *command.Dispatcher
+	channels  *ChannelRegistrar
+	// statusCache keeps the statuses of the backends for the server list
+	statusCache *statusCache
+	health      *healthChecker
+	// permissionProvider is guarded by mu
+	permissionProvider permission.Provider
+	console            *consoleSource
+
+	mu        sync.RWMutex
+	config    *Config
+	favicon   string
+	servers   map[string]*ServerInfo
+	players   map[uuid.UUID]*Player
+	listeners []*Listener
+	groups    map[string]*ServerGroup
+	// strategies are the balancing strategies of groups by name
+	strategies map[string]BalancingStrategy
 
-	eventBus *event.Bus
-	keypair  *encryption.Keypair
+	// connections counts the open connections of clients, rejectedConnections the ones over the cap
+	connections         atomic.Int64
+	rejectedConnections atomic.Uint64
+
+	shutdownOnce sync.Once
+	// shutdown is closed once Shutdown handled the players and plugins
+	shutdown chan struct{}
 }
 
 type HandlerDependency struct {
 	EventBus *event.Bus
 	Keypair  *encryption.Keypair
+	Proxy    *Proxy
 }
 
-func NewProxy(debug bool) *Proxy {
-	return &Proxy{debug: debug, logger: createLogger(debug), eventBus: event.NewEventBus()}
+func NewProxy(debug bool, config *Config) *Proxy {
+	output := &logOutput{out: os.Stderr}
+	logger := createLogger(debug, output)
+	p := &Proxy{
+		debug:    debug,
+		logger:   logger,
+		output:   output,
+		config:   config,
+		eventBus: event.NewEventBus(logger),
+		servers:  make(map[string]*ServerInfo),
+		groups:   configGroups(config),
+		players:  make(map[uuid.UUID]*Player),
+		shutdown: make(chan struct{}),
+	}
+	p.strategies = make(map[string]BalancingStrategy)
+	p.plugins = newPluginManager(p)
+	p.scheduler = newScheduler(logger)
+	p.commands = command.NewDispatcher()
+	p.channels = newChannelRegistrar()
+	p.statusCache = newStatusCache()
+	p.health = newHealthChecker(p)
+	p.console = &consoleSource{logger: logger.With().Str("component", "console").Logger()}
+	p.registerBuiltinCommands()
+	p.registerBungeeCordChannel()
+	p.registerBuiltinStrategies()
+
+	for name, addr := range config.Servers {
+		resolved, err := net.ResolveTCPAddr("tcp", addr)
+		if err != nil {
+			logger.Error().Err(err).Str("server", name).Msg("Failed to resolve server address")
+			continue
+		}
+		p.RegisterServer(NewServerInfo(name, resolved))
+	}
+
+	return p
 }
 
 func start(options startupOptions) {
-	proxy := NewProxy(options.debug)
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		logger := createLogger(options.debug, os.Stderr)
+		logger.Panic().Err(err).Msg("Failed to load config")
+	}
+
+	proxy := NewProxy(options.debug, config)
 
 	keypair, err := encryption.MakeKeypairBytes()
 	if err != nil {
@@ -41,61 +127,303 @@ func start(options startupOptions) {
 		proxy.logger.Debug().Msg("Debug mode enabled")
 	}
 
+	proxy.loadFavicon()
+	proxy.loadPermissions()
 	proxy.loadPlugins()
-	err = proxy.listen(":25565")
+	proxy.eventBus.Fire(&event.ProxyInitializeEvent{})
+	proxy.health.start()
+
+	go proxy.shutdownOnSignal()
+	proxy.startConsole()
+	defer proxy.stopConsole()
+
+	err = proxy.listen()
 	if err != nil {
 		proxy.logger.Panic().Err(err).Msg("Failed to start listener")
 	}
+
+	// the listeners close first, the players and plugins are still being handled
+	<-proxy.shutdown
 }
 
-func (p *Proxy) Shutdown() {
-	p.shutdownPlugins()
+// EventBus is the bus plugins subscribe their handlers on.
+func (p *Proxy) EventBus() *event.Bus {
+	return p.eventBus
+}
+
+// Scheduler runs the delayed, repeating and asynchronous tasks of plugins.
+func (p *Proxy) Scheduler() *Scheduler {
+	return p.scheduler
+}
+
+// Commands is the dispatcher of the commands the proxy runs itself instead of its servers.
+func (p *Proxy) Commands() *command.Dispatcher {
+	return p.commands
+}
+
+// ChannelRegistrar holds the plugin message channels plugins handle.
+func (p *Proxy) ChannelRegistrar() *ChannelRegistrar {
+	return p.channels
+}
+
+// Console is the source of the commands run from the console.
+func (p *Proxy) Console() command.Source {
+	return p.console
+}
+
+// Config is the configuration of the proxy, which is replaced by reloading it.
+func (p *Proxy) Config() *Config {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.config
 }
 
-func (p *Proxy) listen(addr string) error {
-	listener, err := net.Listen("tcp", addr)
+// Reload reads the config again and registers its servers and groups, firing ProxyReloadEvent.
+// Servers and groups that are gone from the config are unregistered, the ones registered by
+// plugins are kept.
+// Changing the bind address needs a restart.
+func (p *Proxy) Reload() error {
+	config, err := LoadConfig(configPath)
 	if err != nil {
-		panic("Error while starting listener: " + err.Error())
+		return err
 	}
-	defer func(listener net.Listener) {
-		err := listener.Close()
+
+	servers := make(map[string]*ServerInfo, len(config.Servers))
+	for name, addr := range config.Servers {
+		resolved, err := net.ResolveTCPAddr("tcp", addr)
 		if err != nil {
-			p.logger.Panic().Err(err).Msg("Failed to close listener")
+			return fmt.Errorf("resolving server %s: %w", name, err)
 		}
-	}(listener)
-	p.logger.Info().Msgf("Listening on %s", addr)
+		servers[name] = NewServerInfo(name, resolved)
+	}
 
-	for {
+	p.mu.Lock()
+	previous := p.config
+	for name := range previous.Servers {
+		existing, ok := p.servers[name]
+		delete(p.servers, name)
 
-		conn, err := listener.Accept()
-		if err != nil {
-			p.logger.Info().Err(err).Msg("Error accepting connection")
-			continue
+		// players keep comparing equal to the servers they are on
+		if server, kept := servers[name]; ok && kept && existing.Addr().String() == server.Addr().String() {
+			servers[name] = existing
 		}
+	}
+	for name, server := range servers {
+		p.servers[name] = server
+	}
+	for name := range previous.Groups {
+		delete(p.groups, name)
+	}
+	for name, group := range configGroups(config) {
+		p.groups[name] = group
+	}
+	p.config = config
+	p.mu.Unlock()
+
+	p.loadFavicon()
+	p.health.start()
+	p.reloadListeners(config)
+
+	p.logger.Info().Int("servers", len(servers)).Msg("Reloaded config")
+	p.eventBus.Fire(&event.ProxyReloadEvent{})
+	return nil
+}
+
+// Shutdown disconnects all players, shuts the plugins down and stops the listeners. Only the first call does anything.
+func (p *Proxy) Shutdown() {
+	p.shutdownOnce.Do(func() {
+		p.logger.Info().Msg("Shutting down")
+		p.eventBus.Fire(&event.ProxyShutdownEvent{})
+
+		for _, listener := range p.Listeners() {
+			_ = listener.listener.Close()
+		}
+
+		p.scheduler.CancelAll(proxyTaskOwner)
+		for _, player := range p.Players() {
+			player.Disconnect(component.NewTextComponent("The proxy is shutting down"))
+		}
+
+		p.shutdownPlugins()
+		close(p.shutdown)
+	})
+}
+
+func (p *Proxy) shutdownOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
 
-		go p.handleConnection(conn)
+	p.Shutdown()
+}
+
+// RegisterServer adds the server, replacing a server of the same name.
+func (p *Proxy) RegisterServer(server *ServerInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.servers[server.Name()] = server
+}
+
+// Server returns the registered server of the name, nil if there is none.
+func (p *Proxy) Server(name string) *ServerInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.servers[name]
+}
+
+// Servers returns the registered servers sorted by name.
+func (p *Proxy) Servers() []*ServerInfo {
+	p.mu.RLock()
+	servers := make([]*ServerInfo, 0, len(p.servers))
+	for _, server := range p.servers {
+		servers = append(servers, server)
 	}
+	p.mu.RUnlock()
+
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].Name() < servers[j].Name()
+	})
+
+	return servers
 }
 
-func (p *Proxy) loadPlugins() {
-	for _, plugin := range Plugins {
-		err := plugin.Init(p)
-		if err != nil {
-			p.logger.Error().Err(err).Msg("Error loading plugin")
+// resolveServer turns a server set by an event handler into a ServerInfo.
+func (p *Proxy) resolveServer(server event.Server) *ServerInfo {
+	if info, ok := server.(*ServerInfo); ok {
+		return info
+	}
+
+	if info := p.Server(server.Name()); info != nil {
+		return info
+	}
+
+	return NewServerInfo(server.Name(), server.Addr())
+}
+
+// initialServer is the server to connect the player to on join: the first one it can join of
+// the forced host it connected with, else of Try, skipping the servers tried already.
+func (p *Proxy) initialServer(player *Player, tried []*ServerInfo) *ServerInfo {
+	for _, name := range player.conn.listener.ForcedHost(player.conn.VirtualHost) {
+		if server := p.pickServer(player, name, tried); server != nil {
+			return server
 		}
 	}
+
+	return p.nextServer(player, tried)
 }
 
-func (p *Proxy) shutdownPlugins() {
-	for _, plugin := range Plugins {
-		err := plugin.Shutdown(p)
-		if err != nil {
-			p.logger.Error().Err(err).Msg("Error shutting down plugin")
+// nextServer is the first server of Try the player can join, skipping the servers tried
+// already. Groups of Try give the server their strategy picks. It returns nil if there is none.
+func (p *Proxy) nextServer(player *Player, tried []*ServerInfo) *ServerInfo {
+	for _, name := range p.Config().Try {
+		if server := p.pickServer(player, name, tried); server != nil {
+			return server
 		}
 	}
+
+	return nil
+}
+
+func containsServer(servers []*ServerInfo, server *ServerInfo) bool {
+	for _, s := range servers {
+		if s == server {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Player returns the online player of the uuid, nil if there is none.
+func (p *Proxy) Player(id uuid.UUID) *Player {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.players[id]
+}
+
+// PlayerByName returns the online player of the name, ignoring case, nil if there is none.
+func (p *Proxy) PlayerByName(name string) *Player {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, player := range p.players {
+		if strings.EqualFold(player.Username(), name) {
+			return player
+		}
+	}
+
+	return nil
+}
+
+// Players returns the online players.
+func (p *Proxy) Players() []*Player {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	players := make([]*Player, 0, len(p.players))
+	for _, player := range p.players {
+		players = append(players, player)
+	}
+
+	return players
+}
+
+func (p *Proxy) PlayerCount() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return len(p.players)
+}
+
+// registerPlayer adds the player, returning false if a player of the same uuid is online.
+func (p *Proxy) registerPlayer(player *Player) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.players[player.UUID()]; ok {
+		return false
+	}
+
+	p.players[player.UUID()] = player
+	return true
+}
+
+func (p *Proxy) unregisterPlayer(player *Player) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.players[player.UUID()] == player {
+		delete(p.players, player.UUID())
+	}
 }
 
-func createLogger(debug bool) zerolog.Logger {
+// loadPlugins loads the plugins compiled in and the plugin files of the plugins directory.
+func (p *Proxy) loadPlugins() {
+	plugins := append([]Plugin(nil), Plugins...)
+	plugins = append(plugins, p.loadPluginFiles(pluginDataRoot)...)
+
+	p.plugins.load(plugins)
+}
+
+func (p *Proxy) shutdownPlugins() {
+	p.plugins.shutdown()
+}
+
+// Plugin returns the plugin of the id, nil if there is none. Plugins call each other through its API.
+func (p *Proxy) Plugin(id string) *PluginContainer {
+	return p.plugins.get(id)
+}
+
+// Plugins returns the plugins in the order they were loaded.
+func (p *Proxy) Plugins() []*PluginContainer {
+	return p.plugins.all()
+}
+
+func createLogger(debug bool, out io.Writer) zerolog.Logger {
 	var level zerolog.Level
 	if debug {
 		level = zerolog.DebugLevel
@@ -103,43 +431,65 @@ func createLogger(debug bool) zerolog.Logger {
 		level = zerolog.InfoLevel
 	}
 
-	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).Level(level).With().Timestamp().Logger()
+	logger := zerolog.New(zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339}).Level(level).With().Timestamp().Logger()
 	return logger
 }
 
-func (p *Proxy) handleConnection(conn net.Conn) {
-	wrapped := Wrap(conn, p.logger.With().Str("address", conn.RemoteAddr().String()).Logger(), &HandlerDependency{EventBus: p.eventBus, Keypair: p.keypair})
+func (p *Proxy) handleConnection(listener *Listener, conn net.Conn) {
+	if listener.ProxyProtocol().Enabled {
+		if trustedSource(conn.RemoteAddr(), listener.trustedSources()) {
+			proxied, err := readProxyHeader(conn)
+			if err != nil {
+				p.logger.Debug().Err(err).Str("balancer", conn.RemoteAddr().String()).Msg("Failed to read PROXY protocol header, closing connection")
+				_ = conn.Close()
+				return
+			}
+			conn = proxied
+		}
+	}
+
+	wrapped := Wrap(conn, p.logger.With().Str("listener", listener.Name()).Str("address", conn.RemoteAddr().String()).Logger(), &HandlerDependency{EventBus: p.eventBus, Keypair: p.keypair, Proxy: p})
+	wrapped.listener = listener
 
 	defer func() {
 		wrapped.Close()
+
+		if player := wrapped.player; player != nil {
+			player.disconnectServer()
+			p.unregisterPlayer(player)
+			p.eventBus.Fire(&event.DisconnectEvent{Player: player})
+			player.logger.Info().Msg("Player disconnected")
+		}
 	}()
 
 	wrapped.Logger.Debug().Msg("New connection")
+	_ = conn.SetReadDeadline(time.Now().Add(loginTimeout))
 
 	p.handlePackets(wrapped)
 }
 
 func (p *Proxy) handlePackets(conn *Conn) {
+	if conn.isLegacyPing() {
+		if !conn.listener.limiter.allow(conn.listener.RateLimit(), attemptStatus, conn.Conn.RemoteAddr()) {
+			conn.Logger.Debug().Msg("Too many status pings, closing connection")
+			return
+		}
+
+		p.handleLegacyPing(conn)
+		return
+	}
+
 	for {
 		packet, err := conn.Read()
 		if err != nil {
-			if err == io.EOF {
+			if err == io.EOF || conn.Closed() {
 				conn.Logger.Debug().Msg("Connection closed")
-				conn.Close()
+			} else {
+				conn.Logger.Debug().Err(err).Msg("Error reading packet, closing connection")
 			}
 			return
 		}
 
-		if packet == nil {
-			return
-		}
-
 		conn.currentHandler.Handle(packet)
-
-		if err != nil {
-			conn.Logger.Error().Err(err).Msg("Error handling packet, connection closed")
-			conn.Close()
-			return
-		}
 	}
 }
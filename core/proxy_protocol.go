@@ -0,0 +1,255 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyHeaderTimeout is how long a load balancer may take to send the PROXY protocol header.
+const proxyHeaderTimeout = 5 * time.Second
+
+// proxyV2Signature starts the binary header of version 2 of the PROXY protocol.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// Commands and address families of PROXY protocol v2 headers.
+const (
+	proxyV2Local = 0x20
+	proxyV2Proxy = 0x21
+	proxyV2TCP4  = 0x11
+	proxyV2TCP6  = 0x21
+)
+
+// proxyV1MaxLength is the longest a version 1 header may be, the line break included.
+const proxyV1MaxLength = 107
+
+// proxiedConn is a connection accepted from a load balancer, having the addresses the balancer
+// got the connection from and to.
+type proxiedConn struct {
+	net.Conn
+	reader *bufio.Reader
+	remote net.Addr
+	local  net.Addr
+}
+
+func (c *proxiedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (c *proxiedConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *proxiedConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// parseTrusted parses the addresses and CIDR ranges PROXY protocol headers are accepted from.
+func parseTrusted(trusted []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(trusted))
+	for _, entry := range trusted {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", entry)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// trustedSource tells whether the connection comes from an address PROXY protocol headers are
+// accepted from.
+func trustedSource(addr net.Addr, networks []*net.IPNet) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	for _, network := range networks {
+		if network.Contains(tcp.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readProxyHeader reads the PROXY protocol v1 or v2 header the connection starts with, returning
+// the connection with the addresses of the header. Headers of health checks of the balancer
+// (LOCAL and UNKNOWN) keep the addresses of the connection.
+func readProxyHeader(conn net.Conn) (net.Conn, error) {
+	_ = conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	proxied := &proxiedConn{Conn: conn, reader: bufio.NewReader(conn), remote: conn.RemoteAddr(), local: conn.LocalAddr()}
+
+	start, err := proxied.reader.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.Equal(start, proxyV2Signature):
+		err = proxied.readV2()
+	case bytes.HasPrefix(start, []byte("PROXY ")):
+		err = proxied.readV1()
+	default:
+		err = errors.New("connection doesn't start with a PROXY protocol header")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return proxied, nil
+}
+
+// readV1 reads a header like "PROXY TCP4 192.0.2.1 192.0.2.2 56324 25565\r\n".
+func (c *proxiedConn) readV1() error {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyV1MaxLength {
+			return errors.New("PROXY protocol v1 header is too long")
+		}
+
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return fmt.Errorf("invalid PROXY protocol v1 header %q", strings.TrimSpace(string(line)))
+	}
+
+	remote, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return err
+	}
+	local, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return err
+	}
+
+	c.remote, c.local = remote, local
+	return nil
+}
+
+func parseV1Addr(host string, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid PROXY protocol address %q", host)
+	}
+
+	number, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY protocol port %q", port)
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(number)}, nil
+}
+
+// readV2 reads a binary header: the signature, the version and command, the address family, the
+// length of the rest and the addresses, followed by extensions that are skipped.
+func (c *proxiedConn) readV2() error {
+	header := make([]byte, len(proxyV2Signature)+4)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return err
+	}
+
+	command, family := header[12], header[13]
+	data := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return err
+	}
+
+	switch command {
+	case proxyV2Local:
+		return nil
+	case proxyV2Proxy:
+	default:
+		return fmt.Errorf("invalid PROXY protocol v2 command 0x%02x", command)
+	}
+
+	var size int
+	switch family {
+	case proxyV2TCP4:
+		size = net.IPv4len
+	case proxyV2TCP6:
+		size = net.IPv6len
+	default:
+		// other families, like unix sockets, have no addresses worth keeping
+		return nil
+	}
+
+	if len(data) < 2*size+4 {
+		return errors.New("PROXY protocol v2 header is too short for its addresses")
+	}
+
+	c.remote = &net.TCPAddr{IP: net.IP(data[:size]), Port: int(binary.BigEndian.Uint16(data[2*size:]))}
+	c.local = &net.TCPAddr{IP: net.IP(data[size : 2*size]), Port: int(binary.BigEndian.Uint16(data[2*size+2:]))}
+	return nil
+}
+
+// serverProxyHeader is the PROXY protocol header to start connections to the server with, nil if
+// the config doesn't send them. Connections of the proxy itself, without client, are LOCAL.
+func (p *Proxy) serverProxyHeader(client net.Addr, server *ServerInfo) []byte {
+	if !p.Config().ProxyProtocol.SendToServers {
+		return nil
+	}
+
+	return proxyV2Header(client, server.Addr())
+}
+
+// proxyV2Header is the PROXY protocol v2 header telling a server that the connection of the
+// proxy to it is of the client.
+func proxyV2Header(client net.Addr, server net.Addr) []byte {
+	header := append([]byte(nil), proxyV2Signature...)
+
+	source, sourceOK := client.(*net.TCPAddr)
+	destination, destinationOK := server.(*net.TCPAddr)
+	if !sourceOK || !destinationOK {
+		return append(header, proxyV2Local, 0x00, 0x00, 0x00)
+	}
+
+	family := byte(proxyV2TCP6)
+	sourceIP, destinationIP := source.IP.To16(), destination.IP.To16()
+	if source.IP.To4() != nil && destination.IP.To4() != nil {
+		family = proxyV2TCP4
+		sourceIP, destinationIP = source.IP.To4(), destination.IP.To4()
+	}
+
+	header = append(header, proxyV2Proxy, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(2*len(sourceIP)+4))
+	header = append(header, sourceIP...)
+	header = append(header, destinationIP...)
+	header = binary.BigEndian.AppendUint16(header, uint16(source.Port))
+	header = binary.BigEndian.AppendUint16(header, uint16(destination.Port))
+
+	return header
+}
@@ -0,0 +1,208 @@
+package core
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+// readHeader reads the PROXY protocol header of a connection sending data, returning the
+// connection and what follows the header.
+func readHeader(t *testing.T, data []byte) (net.Conn, []byte, error) {
+	t.Helper()
+
+	client, server := net.Pipe()
+	go func() {
+		_, _ = client.Write(data)
+		_ = client.Close()
+	}()
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	conn, err := readProxyHeader(server)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rest, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("reading after the header: %v", err)
+	}
+
+	return conn, rest, nil
+}
+
+func TestReadProxyHeaderV1(t *testing.T) {
+	conn, rest, err := readHeader(t, []byte("PROXY TCP4 192.0.2.1 192.0.2.2 56324 25565\r\nhandshake"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := conn.RemoteAddr().String(); got != "192.0.2.1:56324" {
+		t.Errorf("remote address is %s, want 192.0.2.1:56324", got)
+	}
+	if got := conn.LocalAddr().String(); got != "192.0.2.2:25565" {
+		t.Errorf("local address is %s, want 192.0.2.2:25565", got)
+	}
+	if string(rest) != "handshake" {
+		t.Errorf("read %q after the header, want handshake", rest)
+	}
+}
+
+func TestReadProxyHeaderV1TCP6(t *testing.T) {
+	conn, _, err := readHeader(t, []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 25565\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := conn.RemoteAddr().String(); got != "[2001:db8::1]:56324" {
+		t.Errorf("remote address is %s, want [2001:db8::1]:56324", got)
+	}
+}
+
+func TestReadProxyHeaderV1UnknownKeepsAddresses(t *testing.T) {
+	conn, rest, err := readHeader(t, []byte("PROXY UNKNOWN\r\nping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conn.RemoteAddr().Network() != "pipe" {
+		t.Errorf("remote address is %s, want the one of the connection", conn.RemoteAddr())
+	}
+	if string(rest) != "ping" {
+		t.Errorf("read %q after the header, want ping", rest)
+	}
+}
+
+func TestReadProxyHeaderV1Invalid(t *testing.T) {
+	for name, header := range map[string]string{
+		"missing fields":  "PROXY TCP4 192.0.2.1 192.0.2.2 56324\r\n",
+		"invalid family":  "PROXY UDP4 192.0.2.1 192.0.2.2 56324 25565\r\n",
+		"invalid address": "PROXY TCP4 192.0.2.x 192.0.2.2 56324 25565\r\n",
+		"invalid port":    "PROXY TCP4 192.0.2.1 192.0.2.2 65536 25565\r\n",
+		"too long":        "PROXY TCP4 " + string(make([]byte, proxyV1MaxLength)) + "\r\n",
+		"truncated":       "PROXY TCP4 192.0.2.1 192.0.2.2",
+	} {
+		if _, _, err := readHeader(t, []byte(header)); err == nil {
+			t.Errorf("%s: header was accepted", name)
+		}
+	}
+}
+
+func TestReadProxyHeaderV2(t *testing.T) {
+	client := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324}
+	server := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 25565}
+
+	conn, rest, err := readHeader(t, append(proxyV2Header(client, server), "handshake"...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := conn.RemoteAddr().String(); got != "192.0.2.1:56324" {
+		t.Errorf("remote address is %s, want 192.0.2.1:56324", got)
+	}
+	if got := conn.LocalAddr().String(); got != "192.0.2.2:25565" {
+		t.Errorf("local address is %s, want 192.0.2.2:25565", got)
+	}
+	if string(rest) != "handshake" {
+		t.Errorf("read %q after the header, want handshake", rest)
+	}
+}
+
+func TestReadProxyHeaderV2TCP6(t *testing.T) {
+	client := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324}
+	server := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 25565}
+
+	conn, _, err := readHeader(t, proxyV2Header(client, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := conn.RemoteAddr().String(); got != "[2001:db8::1]:56324" {
+		t.Errorf("remote address is %s, want [2001:db8::1]:56324", got)
+	}
+}
+
+func TestReadProxyHeaderV2LocalKeepsAddresses(t *testing.T) {
+	conn, _, err := readHeader(t, proxyV2Header(nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conn.RemoteAddr().Network() != "pipe" {
+		t.Errorf("remote address is %s, want the one of the connection", conn.RemoteAddr())
+	}
+}
+
+func TestReadProxyHeaderV2Invalid(t *testing.T) {
+	client := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324}
+	server := &net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 25565}
+	header := proxyV2Header(client, server)
+
+	invalidCommand := append([]byte(nil), header...)
+	invalidCommand[12] = 0x22
+
+	// the length covers the addresses, which are cut short
+	short := append([]byte(nil), header[:len(proxyV2Signature)+4]...)
+	short[15] = 4
+	short = append(short, 192, 0, 2, 1)
+
+	for name, data := range map[string][]byte{
+		"truncated header":    header[:len(proxyV2Signature)+2],
+		"truncated addresses": header[:len(header)-3],
+		"invalid command":     invalidCommand,
+		"short addresses":     short,
+	} {
+		if _, _, err := readHeader(t, data); err == nil {
+			t.Errorf("%s: header was accepted", name)
+		}
+	}
+}
+
+func TestReadProxyHeaderRejectsOtherData(t *testing.T) {
+	if _, _, err := readHeader(t, []byte("\x10\x00\xfa\x05\x09localhost")); err == nil {
+		t.Error("connection without header was accepted")
+	}
+}
+
+func TestTrustedSource(t *testing.T) {
+	networks, err := parseTrusted([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for address, want := range map[string]bool{
+		"10.1.2.3":    true,
+		"192.0.2.1":   true,
+		"192.0.2.2":   false,
+		"2001:db8::5": true,
+		"2001:db9::5": false,
+	} {
+		addr := &net.TCPAddr{IP: net.ParseIP(address), Port: 25565}
+		if got := trustedSource(addr, networks); got != want {
+			t.Errorf("trustedSource(%s) = %v, want %v", address, got, want)
+		}
+	}
+
+	if trustedSource(&net.TCPAddr{IP: net.ParseIP("10.1.2.3")}, nil) {
+		t.Error("source is trusted without trusted networks")
+	}
+}
+
+func TestProxyProtocolNeedsTrustedSources(t *testing.T) {
+	config := ProxyProtocolConfig{Enabled: true}
+	if err := config.validate(); err == nil {
+		t.Error("PROXY protocol without trusted sources was accepted")
+	}
+
+	config.Trusted = []string{"10.0.0.0/8"}
+	if err := config.validate(); err != nil {
+		t.Errorf("PROXY protocol with trusted sources was rejected: %v", err)
+	}
+
+	config.Trusted = []string{"10.0.0.0/33"}
+	if err := config.validate(); err == nil {
+		t.Error("invalid trusted source was accepted")
+	}
+}
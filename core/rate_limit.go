@@ -0,0 +1,240 @@
+package core
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rateLimitCleanupInterval is how often the buckets of addresses that stopped connecting are dropped.
+const rateLimitCleanupInterval = time.Minute
+
+// Kinds of connection attempts that are rate limited.
+const (
+	attemptStatus = "status"
+	attemptLogin  = "login"
+)
+
+// RateLimitStats counts the connections the rate limits rejected.
+type RateLimitStats struct {
+	// Status counts the status pings over the status limits, which were closed
+	Status uint64
+	// Login counts the logins over the login limits
+	Login uint64
+	// Throttled counts the logins made within the login throttle of the previous one
+	Throttled uint64
+	// Connections counts the connections over the cap of open connections
+	Connections uint64
+}
+
+// tokenBucket holds the attempts an address or subnet may make. It refills at the rate of its
+// config up to the burst.
+type tokenBucket struct {
+	config BucketConfig
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens of the time since the last refill, telling whether there is one to take.
+func (b *tokenBucket) refill(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.config.Rate
+	if b.tokens > float64(b.config.Burst) {
+		b.tokens = float64(b.config.Burst)
+	}
+	b.last = now
+
+	return b.tokens >= 1
+}
+
+// full tells whether the bucket refilled completely, so it can be dropped.
+func (b *tokenBucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.config.Rate >= float64(b.config.Burst)
+}
+
+// rateLimiter limits the status pings and logins of the clients of a listener.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	// logins are the times of the last logins by address
+	logins map[string]time.Time
+
+	status    atomic.Uint64
+	login     atomic.Uint64
+	throttled atomic.Uint64
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*tokenBucket), logins: make(map[string]time.Time)}
+}
+
+// allow takes an attempt of the kind from the buckets of the address and of its subnet. Both
+// need to have one left, rejected attempts don't take any.
+func (r *rateLimiter) allow(config RateLimitConfig, kind string, addr net.Addr) bool {
+	if !config.Enabled {
+		return true
+	}
+
+	ip, ok := addrIP(addr)
+	if !ok {
+		return true
+	}
+
+	limits := config.Status
+	if kind == attemptLogin {
+		limits = config.Login
+	}
+
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var buckets []*tokenBucket
+	if limits.IP.Burst > 0 {
+		buckets = append(buckets, r.bucket(kind+"/"+ip.String(), limits.IP, now))
+	}
+	if limits.Subnet.Burst > 0 {
+		buckets = append(buckets, r.bucket(kind+"/"+subnetKey(ip, config), limits.Subnet, now))
+	}
+
+	for _, bucket := range buckets {
+		if !bucket.refill(now) {
+			if kind == attemptLogin {
+				r.login.Add(1)
+			} else {
+				r.status.Add(1)
+			}
+			return false
+		}
+	}
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+
+	return true
+}
+
+// bucket returns the bucket of the key with the limits of the config, a full one if there is
+// none yet.
+func (r *rateLimiter) bucket(key string, config BucketConfig, now time.Time) *tokenBucket {
+	bucket, ok := r.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(config.Burst), last: now}
+		r.buckets[key] = bucket
+	}
+	bucket.config = config
+
+	return bucket
+}
+
+// throttle tells whether the address logged in less than the login throttle ago, remembering the
+// time of the login otherwise.
+func (r *rateLimiter) throttle(config RateLimitConfig, addr net.Addr) bool {
+	if !config.Enabled || config.LoginThrottle <= 0 {
+		return false
+	}
+
+	ip, ok := addrIP(addr)
+	if !ok {
+		return false
+	}
+
+	now := time.Now()
+	window := time.Duration(config.LoginThrottle) * time.Millisecond
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if last, ok := r.logins[ip.String()]; ok && now.Sub(last) < window {
+		r.throttled.Add(1)
+		return true
+	}
+
+	r.logins[ip.String()] = now
+	return false
+}
+
+// cleanup drops the buckets that refilled and the logins outside the throttle.
+func (r *rateLimiter) cleanup(config RateLimitConfig) {
+	now := time.Now()
+	window := time.Duration(config.LoginThrottle) * time.Millisecond
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, bucket := range r.buckets {
+		if bucket.full(now) {
+			delete(r.buckets, key)
+		}
+	}
+	for address, last := range r.logins {
+		if now.Sub(last) >= window {
+			delete(r.logins, address)
+		}
+	}
+}
+
+func (r *rateLimiter) stats() RateLimitStats {
+	return RateLimitStats{Status: r.status.Load(), Login: r.login.Load(), Throttled: r.throttled.Load()}
+}
+
+func addrIP(addr net.Addr) (net.IP, bool) {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return nil, false
+	}
+
+	if ip := tcp.IP.To4(); ip != nil {
+		return ip, true
+	}
+
+	return tcp.IP, true
+}
+
+// subnetKey is the subnet of the address, with the prefix length of the config for its family.
+func subnetKey(ip net.IP, config RateLimitConfig) string {
+	prefix, bits := config.IPv6Subnet, 128
+	if len(ip) == net.IPv4len {
+		prefix, bits = config.IPv4Subnet, 32
+	}
+
+	return ip.Mask(net.CIDRMask(prefix, bits)).String() + "/" + strconv.Itoa(prefix)
+}
+
+// RateLimitStats counts the connections the rate limits of the listener rejected. The cap of open
+// connections is of the proxy, its count is in the stats of the proxy.
+func (l *Listener) RateLimitStats() RateLimitStats {
+	return l.limiter.stats()
+}
+
+// RateLimitStats counts the connections the rate limits of all listeners rejected.
+func (p *Proxy) RateLimitStats() RateLimitStats {
+	stats := RateLimitStats{Connections: p.rejectedConnections.Load()}
+	for _, listener := range p.Listeners() {
+		listenerStats := listener.RateLimitStats()
+		stats.Status += listenerStats.Status
+		stats.Login += listenerStats.Login
+		stats.Throttled += listenerStats.Throttled
+	}
+
+	return stats
+}
+
+// acquireConnection counts a new connection, returning false if the cap of open connections of
+// the config is reached.
+func (p *Proxy) acquireConnection() bool {
+	limit := p.Config().MaxConnections
+	if p.connections.Add(1) > int64(limit) && limit > 0 {
+		p.connections.Add(-1)
+		p.rejectedConnections.Add(1)
+		return false
+	}
+
+	return true
+}
+
+func (p *Proxy) releaseConnection() {
+	p.connections.Add(-1)
+}
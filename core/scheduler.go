@@ -0,0 +1,236 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of scheduled tasks.
+const (
+	TaskLater     = "later"
+	TaskRepeating = "repeating"
+	TaskAsync     = "async"
+)
+
+// ErrInvalidInterval is returned by RunRepeating for intervals that aren't positive.
+var ErrInvalidInterval = errors.New("interval has to be positive")
+
+// Scheduler runs delayed, repeating and asynchronous tasks owned by plugins. The tasks of a
+// plugin are cancelled when it shuts down.
+type Scheduler struct {
+	logger zerolog.Logger
+
+	mu     sync.Mutex
+	tasks  map[uint64]*Task
+	nextID uint64
+}
+
+// Task is a scheduled task. Cancelling stops delayed and repeating tasks from running again,
+// asynchronous tasks see the cancellation through their context.
+type Task struct {
+	scheduler *Scheduler
+	id        uint64
+	owner     string
+	kind      string
+	created   time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// TaskInfo describes a running task, for debugging.
+type TaskInfo struct {
+	ID      uint64
+	Owner   string
+	Kind    string
+	Created time.Time
+}
+
+func newScheduler(logger zerolog.Logger) *Scheduler {
+	return &Scheduler{logger: logger.With().Str("component", "scheduler").Logger(), tasks: make(map[uint64]*Task)}
+}
+
+// RunLater runs task once after the delay.
+func (s *Scheduler) RunLater(owner string, delay time.Duration, task func()) *Task {
+	t := s.add(owner, TaskLater)
+
+	go func() {
+		defer t.finish()
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-t.ctx.Done():
+		case <-timer.C:
+			t.run(func(context.Context) { task() })
+		}
+	}()
+
+	return t
+}
+
+// RunRepeating runs task after the delay and then every interval until it is cancelled. A run
+// that panics is reported and doesn't stop the following ones. The interval has to be positive.
+func (s *Scheduler) RunRepeating(owner string, delay time.Duration, interval time.Duration, task func()) (*Task, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	t := s.add(owner, TaskRepeating)
+
+	go func() {
+		defer t.finish()
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-timer.C:
+			t.run(func(context.Context) { task() })
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-t.ctx.Done():
+				return
+			case <-ticker.C:
+				t.run(func(context.Context) { task() })
+			}
+		}
+	}()
+
+	return t, nil
+}
+
+// RunAsync runs task on its own goroutine right away. The context is cancelled with the task,
+// long running tasks should return once it is.
+func (s *Scheduler) RunAsync(owner string, task func(ctx context.Context)) *Task {
+	t := s.add(owner, TaskAsync)
+
+	go func() {
+		defer t.finish()
+		t.run(task)
+	}()
+
+	return t
+}
+
+// Tasks lists the tasks that are scheduled or running, oldest first.
+func (s *Scheduler) Tasks() []TaskInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos := make([]TaskInfo, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		infos = append(infos, TaskInfo{ID: t.id, Owner: t.owner, Kind: t.kind, Created: t.created})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+
+	return infos
+}
+
+// CancelAll cancels the tasks of the owner.
+func (s *Scheduler) CancelAll(owner string) {
+	s.mu.Lock()
+	var owned []*Task
+	for _, t := range s.tasks {
+		if t.owner == owner {
+			owned = append(owned, t)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, t := range owned {
+		t.Cancel()
+	}
+
+	if len(owned) > 0 {
+		s.logger.Debug().Str("plugin", owner).Int("tasks", len(owned)).Msg("Cancelled tasks")
+	}
+}
+
+func (s *Scheduler) add(owner string, kind string) *Task {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	t := &Task{
+		scheduler: s,
+		id:        s.nextID,
+		owner:     owner,
+		kind:      kind,
+		created:   time.Now(),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	s.tasks[t.id] = t
+
+	return t
+}
+
+func (t *Task) ID() uint64 {
+	return t.id
+}
+
+// Owner is the id of the plugin owning the task.
+func (t *Task) Owner() string {
+	return t.owner
+}
+
+func (t *Task) Kind() string {
+	return t.kind
+}
+
+func (t *Task) Cancel() {
+	t.cancel()
+}
+
+// Cancelled tells whether the task was cancelled.
+func (t *Task) Cancelled() bool {
+	return t.ctx.Err() != nil
+}
+
+// Done is closed once the task won't run anymore.
+func (t *Task) Done() <-chan struct{} {
+	return t.done
+}
+
+// run runs the task, reporting a panic instead of crashing the proxy.
+func (t *Task) run(task func(ctx context.Context)) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.scheduler.logger.Error().Str("plugin", t.owner).Uint64("task", t.id).Str("kind", t.kind).
+				Interface("panic", r).Bytes("stack", debug.Stack()).Msg("Task panicked")
+		}
+	}()
+
+	task(t.ctx)
+}
+
+func (t *Task) finish() {
+	t.cancel()
+
+	t.scheduler.mu.Lock()
+	delete(t.scheduler.tasks, t.id)
+	t.scheduler.mu.Unlock()
+
+	close(t.done)
+}
@@ -2,14 +2,20 @@ package core
 
 import "net"
 
-type Server struct {
-}
-
+// ServerInfo is a backend server registered with the proxy.
 type ServerInfo struct {
-	Name string
-	Addr net.Addr
+	name string
+	addr net.Addr
 }
 
 func NewServerInfo(name string, addr net.Addr) *ServerInfo {
-	return &ServerInfo{Name: name, Addr: addr}
+	return &ServerInfo{name: name, addr: addr}
+}
+
+func (s *ServerInfo) Name() string {
+	return s.name
+}
+
+func (s *ServerInfo) Addr() net.Addr {
+	return s.addr
 }
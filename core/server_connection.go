@@ -0,0 +1,293 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopro/core/component"
+	"gopro/core/proto"
+	"gopro/core/proto/auth"
+	"gopro/core/proto/encoding"
+	"gopro/core/proto/packets"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// connectTimeout is how long connecting to a backend server may take.
+const connectTimeout = 5 * time.Second
+
+// DisconnectedError is returned when a server refused the login of a player.
+type DisconnectedError struct {
+	Server *ServerInfo
+	Reason *component.TextComponent
+}
+
+func (e *DisconnectedError) Error() string {
+	return fmt.Sprintf("disconnected by %s: %s", e.Server.Name(), e.Reason)
+}
+
+// serverConnection is the connection of a player to a backend server.
+type serverConnection struct {
+	server *ServerInfo
+	player *Player
+	conn   *Conn
+	// kickReason is the reason the server disconnected the player with, kept by the relay
+	kickReason *component.TextComponent
+}
+
+// connectServer logs the player in to the server, leaving the connection in the state the
+// client is in after its own login.
+func connectServer(player *Player, server *ServerInfo) (*serverConnection, error) {
+	netConn, err := net.DialTimeout("tcp", server.Addr().String(), connectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := newConn(netConn, player.logger.With().Str("server", server.Name()).Logger())
+	conn.ProtocolVersion = player.ProtocolVersion()
+
+	if header := player.proxy.serverProxyHeader(player.RemoteAddr(), server); header != nil {
+		if _, err := netConn.Write(header); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	sc := &serverConnection{server: server, player: player, conn: conn}
+
+	_ = netConn.SetDeadline(time.Now().Add(connectTimeout))
+	if err := sc.login(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = netConn.SetDeadline(time.Time{})
+
+	return sc, nil
+}
+
+func (sc *serverConnection) login() error {
+	host, port, err := sc.hostPort()
+	if err != nil {
+		return err
+	}
+
+	err = sc.conn.WritePacket(&packets.Handshake{
+		Protocol:      encoding.Varint(sc.conn.ProtocolVersion),
+		ServerAddress: encoding.String(host),
+		ServerPort:    encoding.UShort(port),
+		NextState:     encoding.Varint(proto.Login),
+	})
+	if err != nil {
+		return err
+	}
+	sc.conn.SwitchState(proto.Login)
+
+	id := encoding.UUID(sc.player.UUID())
+	err = sc.conn.WritePacket(&packets.LoginStart{
+		Name:         encoding.String(sc.player.Username()),
+		OptionalUUID: &id,
+		PlayerUUID:   id,
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		packet, err := sc.conn.Read()
+		if err != nil {
+			return err
+		}
+
+		switch packet.ID {
+		case 0x00:
+			{
+				var disconnect packets.Disconnect
+				if err := sc.conn.ReadPacket(packet, &disconnect); err != nil {
+					return err
+				}
+				return &DisconnectedError{Server: sc.server, Reason: disconnect.Reason}
+			}
+		case 0x01:
+			{
+				return fmt.Errorf("%s is in online mode", sc.server.Name())
+			}
+		case 0x02:
+			{
+				if sc.conn.ProtocolVersion >= proto.ConfigurationProtocol {
+					if err := sc.conn.WritePacket(&packets.LoginAcknowledged{}); err != nil {
+						return err
+					}
+					sc.conn.SwitchState(proto.Configuration)
+				} else {
+					sc.conn.SwitchState(proto.Play)
+				}
+				return nil
+			}
+		case 0x03:
+			{
+				var compression packets.SetCompression
+				if err := sc.conn.ReadPacket(packet, &compression); err != nil {
+					return err
+				}
+				sc.conn.Threshold = int(compression.Threshold)
+			}
+		case 0x04:
+			{
+				if err := sc.handleLoginPluginRequest(packet); err != nil {
+					return err
+				}
+			}
+		default:
+			{
+				return fmt.Errorf("unexpected login packet 0x%02x", packet.ID)
+			}
+		}
+	}
+}
+
+// hostPort is the address sent in the handshake, carrying the player for legacy forwarding.
+func (sc *serverConnection) hostPort() (string, uint16, error) {
+	host, portStr, err := net.SplitHostPort(sc.server.Addr().String())
+	if err != nil {
+		return "", 0, err
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if sc.player.proxy.Config().Forwarding != ForwardingLegacy {
+		return host, uint16(port), nil
+	}
+
+	ip, _, err := net.SplitHostPort(sc.player.RemoteAddr().String())
+	if err != nil {
+		return "", 0, err
+	}
+
+	properties := sc.player.GameProfile().Properties
+	if properties == nil {
+		properties = []auth.Properties{}
+	}
+
+	propertiesJSON, err := json.Marshal(properties)
+	if err != nil {
+		return "", 0, err
+	}
+
+	forwarded := strings.Join([]string{
+		host,
+		ip,
+		strings.ReplaceAll(sc.player.UUID().String(), "-", ""),
+		string(propertiesJSON),
+	}, "\x00")
+
+	return forwarded, uint16(port), nil
+}
+
+// handleLoginPluginRequest tells the server the proxy doesn't understand its query.
+func (sc *serverConnection) handleLoginPluginRequest(packet *proto.Packet) error {
+	var request packets.LoginPluginRequest
+	if err := sc.conn.ReadPacket(packet, &request); err != nil {
+		return err
+	}
+
+	sc.conn.Logger.Debug().Str("channel", string(request.Channel)).Msg("Answering login plugin request")
+	return sc.conn.WritePacket(&packets.LoginPluginResponse{MessageID: request.MessageID, Successful: false})
+}
+
+// relay forwards the packets of the server to the player until one of them disconnects.
+func (sc *serverConnection) relay() {
+	for {
+		packet, err := sc.conn.Read()
+		if err != nil {
+			break
+		}
+
+		packet = sc.handle(packet)
+		if packet == nil {
+			continue
+		}
+
+		// checking and sending under the lock keeps a switch to another server from slipping in between
+		sc.player.mu.Lock()
+		if sc.player.server != sc {
+			sc.player.mu.Unlock()
+			break
+		}
+		err = sc.player.conn.SendPacket(packet)
+		sc.player.mu.Unlock()
+
+		if err != nil {
+			break
+		}
+	}
+
+	sc.conn.Close()
+
+	// the player stays if it was moved to another server meanwhile
+	sc.player.mu.Lock()
+	current := sc.player.server == sc
+	sc.player.mu.Unlock()
+
+	if current && !sc.player.conn.Closed() {
+		reason := sc.kickReason
+		if reason == nil {
+			reason = component.NewTextComponent("Lost connection to " + sc.server.Name())
+		}
+		sc.player.fallback(sc.server, reason)
+	}
+}
+
+// handle follows the state changes of the server and adds the commands of the proxy to the
+// command tree it sends, returning the packet to pass on to the client. Plugin messages handled
+// by the proxy and disconnects, which move the player to a fallback server, return nil.
+func (sc *serverConnection) handle(packet *proto.Packet) *proto.Packet {
+	protocol := sc.conn.ProtocolVersion
+
+	if sc.player.handlePluginMessage(sc.conn, packet, false, sc.server) {
+		return nil
+	}
+
+	switch sc.conn.State() {
+	case proto.Configuration:
+		switch packet.ID {
+		case (&packets.FinishConfiguration{}).ID(protocol):
+			sc.conn.SwitchState(proto.Play)
+		case (&packets.ConfigDisconnect{}).ID(protocol):
+			var disconnect packets.ConfigDisconnect
+			if err := sc.conn.ReadPacket(packet, &disconnect); err == nil {
+				sc.kickReason = &disconnect.Reason
+				return nil
+			}
+		}
+	case proto.Play:
+		if protocol < packets.PlayProtocol {
+			return packet
+		}
+
+		switch packet.ID {
+		case (&packets.StartConfiguration{}).ID(protocol):
+			if protocol >= proto.ConfigurationProtocol {
+				sc.conn.SwitchState(proto.Configuration)
+			}
+		case (&packets.PlayDisconnect{}).ID(protocol):
+			var disconnect packets.PlayDisconnect
+			if err := sc.conn.ReadPacket(packet, &disconnect); err == nil {
+				sc.kickReason = &disconnect.Reason
+				return nil
+			}
+		case (&packets.Commands{}).ID(protocol):
+			data, err := sc.player.proxy.commands.Inject(packet.Payload(), sc.player)
+			if err != nil {
+				sc.player.logger.Debug().Err(err).Msg("Failed to add proxy commands to command tree")
+				return packet
+			}
+			return proto.Raw(packet.ID, data)
+		}
+	}
+
+	return packet
+}
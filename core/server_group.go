@@ -0,0 +1,224 @@
+package core
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ErrServerFull is returned by Connect for servers that have as many players as their capacity.
+var ErrServerFull = errors.New("server is full")
+
+// Strategies of picking the server of a group, more can be registered with RegisterStrategy.
+const (
+	StrategyRoundRobin   = "round_robin"
+	StrategyLeastPlayers = "least_players"
+	StrategyRandom       = "random"
+	StrategyLowestPing   = "lowest_ping"
+)
+
+// BalancingStrategy picks the server of the group to send the player to. The candidates are
+// the members of the group the player may join, in the order of the group, and never empty.
+type BalancingStrategy func(group *ServerGroup, player *Player, candidates []*ServerInfo) *ServerInfo
+
+// ServerGroup is a set of servers players are balanced between. Groups can be used wherever
+// players are sent to a server by name: the try list, fallbacks and /server.
+type ServerGroup struct {
+	name     string
+	servers  []string
+	strategy string
+
+	// next is the count of picks of the round robin strategy
+	next atomic.Uint64
+}
+
+// NewServerGroup makes a group of the servers of the names, picking one by the strategy.
+func NewServerGroup(name string, strategy string, servers ...string) *ServerGroup {
+	return &ServerGroup{name: name, servers: servers, strategy: strategy}
+}
+
+func (g *ServerGroup) Name() string {
+	return g.name
+}
+
+// Servers are the names of the members of the group.
+func (g *ServerGroup) Servers() []string {
+	return append([]string(nil), g.servers...)
+}
+
+func (g *ServerGroup) Strategy() string {
+	return g.strategy
+}
+
+func roundRobin(group *ServerGroup, _ *Player, candidates []*ServerInfo) *ServerInfo {
+	return candidates[(group.next.Add(1)-1)%uint64(len(candidates))]
+}
+
+func randomServer(_ *ServerGroup, _ *Player, candidates []*ServerInfo) *ServerInfo {
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// leastPlayers picks the server with the fewest players on it, the first one of a tie.
+func (p *Proxy) leastPlayers(_ *ServerGroup, _ *Player, candidates []*ServerInfo) *ServerInfo {
+	best, fewest := candidates[0], math.MaxInt
+	for _, server := range candidates {
+		if count := p.ServerPlayerCount(server); count < fewest {
+			best, fewest = server, count
+		}
+	}
+
+	return best
+}
+
+// lowestPing picks the server the health checker measured the lowest latency of. Servers that
+// weren't checked yet come last.
+func (p *Proxy) lowestPing(_ *ServerGroup, _ *Player, candidates []*ServerInfo) *ServerInfo {
+	best, lowest := candidates[0], time.Duration(math.MaxInt64)
+	for _, server := range candidates {
+		health, ok := p.ServerHealth(server)
+		if ok && health.Online && health.Latency < lowest {
+			best, lowest = server, health.Latency
+		}
+	}
+
+	return best
+}
+
+func (p *Proxy) registerBuiltinStrategies() {
+	p.RegisterStrategy(StrategyRoundRobin, roundRobin)
+	p.RegisterStrategy(StrategyLeastPlayers, p.leastPlayers)
+	p.RegisterStrategy(StrategyRandom, randomServer)
+	p.RegisterStrategy(StrategyLowestPing, p.lowestPing)
+}
+
+// RegisterStrategy makes the strategy usable by groups under the name, replacing a strategy of
+// the same name.
+func (p *Proxy) RegisterStrategy(name string, strategy BalancingStrategy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.strategies[name] = strategy
+}
+
+// Strategy returns the strategy of the name, nil if there is none.
+func (p *Proxy) Strategy(name string) BalancingStrategy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.strategies[name]
+}
+
+// RegisterGroup adds the group, replacing a group of the same name.
+func (p *Proxy) RegisterGroup(group *ServerGroup) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.groups[group.Name()] = group
+}
+
+// Group returns the registered group of the name, nil if there is none.
+func (p *Proxy) Group(name string) *ServerGroup {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.groups[name]
+}
+
+// Groups returns the registered groups sorted by name.
+func (p *Proxy) Groups() []*ServerGroup {
+	p.mu.RLock()
+	groups := make([]*ServerGroup, 0, len(p.groups))
+	for _, group := range p.groups {
+		groups = append(groups, group)
+	}
+	p.mu.RUnlock()
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name() < groups[j].Name()
+	})
+
+	return groups
+}
+
+// configGroups makes the groups of the config.
+func configGroups(config *Config) map[string]*ServerGroup {
+	groups := make(map[string]*ServerGroup, len(config.Groups))
+	for name, group := range config.Groups {
+		strategy := group.Strategy
+		if strategy == "" {
+			strategy = StrategyRoundRobin
+		}
+		groups[name] = NewServerGroup(name, strategy, group.Servers...)
+	}
+
+	return groups
+}
+
+// ServerPlayerCount is the number of players on the server, players moving to it included.
+func (p *Proxy) ServerPlayerCount(server *ServerInfo) int {
+	count := 0
+	for _, player := range p.Players() {
+		if player.onServer(server) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// serverFull tells whether the server has as many players as the capacity the config gives it.
+func (p *Proxy) serverFull(server *ServerInfo) bool {
+	capacity, ok := p.Config().Capacity[server.Name()]
+	return ok && p.ServerPlayerCount(server) >= capacity
+}
+
+// canJoin tells whether the player may be sent to the server: it is up, not full and the player
+// has access to it.
+func (p *Proxy) canJoin(player *Player, server *ServerInfo) bool {
+	return p.ServerAvailable(server) && p.CanAccess(player, server) && !p.serverFull(server)
+}
+
+// pickServer resolves the name of a server or group to the server to send the player to,
+// skipping the servers tried already. It returns nil if there is none the player can join.
+func (p *Proxy) pickServer(player *Player, name string, tried []*ServerInfo) *ServerInfo {
+	if server := p.Server(name); server != nil {
+		if containsServer(tried, server) || !p.canJoin(player, server) {
+			return nil
+		}
+
+		return server
+	}
+
+	group := p.Group(name)
+	if group == nil {
+		return nil
+	}
+
+	var candidates []*ServerInfo
+	for _, member := range group.servers {
+		server := p.Server(member)
+		if server != nil && !containsServer(tried, server) && p.canJoin(player, server) {
+			candidates = append(candidates, server)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	strategy := p.Strategy(group.strategy)
+	if strategy == nil {
+		p.logger.Warn().Str("group", group.Name()).Str("strategy", group.strategy).Msg("Unknown balancing strategy, using round robin")
+		strategy = roundRobin
+	}
+
+	server := strategy(group, player, candidates)
+	if !containsServer(candidates, server) {
+		p.logger.Error().Str("group", group.Name()).Str("strategy", group.strategy).Msg("Balancing strategy picked a server that isn't a candidate")
+		return candidates[0]
+	}
+
+	return server
+}
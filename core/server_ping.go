@@ -0,0 +1,146 @@
+package core
+
+import (
+	"errors"
+	"github.com/rs/zerolog"
+	"gopro/core/proto"
+	"gopro/core/proto/encoding"
+	"gopro/core/proto/packets"
+	"gopro/core/proto/status"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// pingServer asks the server for its status like clients do for the server list, returning it
+// with the round trip time of a ping after it. The PROXY protocol header is sent first unless nil.
+func pingServer(server *ServerInfo, protocol int, timeout time.Duration, proxyHeader []byte) (*status.Response, time.Duration, error) {
+	deadline := time.Now().Add(timeout)
+
+	netConn, err := net.DialTimeout("tcp", server.Addr().String(), timeout)
+	if err != nil {
+		return nil, 0, err
+	}
+	_ = netConn.SetDeadline(deadline)
+
+	conn := newConn(netConn, zerolog.Nop())
+	defer conn.Close()
+
+	if proxyHeader != nil {
+		if _, err := netConn.Write(proxyHeader); err != nil {
+			return nil, 0, err
+		}
+	}
+	conn.ProtocolVersion = protocol
+
+	host, portString, err := net.SplitHostPort(server.Addr().String())
+	if err != nil {
+		return nil, 0, err
+	}
+	port, _ := strconv.Atoi(portString)
+
+	err = conn.WritePacket(&packets.Handshake{
+		Protocol:      encoding.Varint(protocol),
+		ServerAddress: encoding.String(host),
+		ServerPort:    encoding.UShort(port),
+		NextState:     encoding.Varint(proto.Status),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	conn.SwitchState(proto.Status)
+
+	if err := conn.WritePacket(&packets.StatusRequest{}); err != nil {
+		return nil, 0, err
+	}
+
+	packet, err := conn.Read()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if packet.ID != (&packets.StatusResponse{}).ID(protocol) {
+		return nil, 0, errors.New("server didn't answer with its status")
+	}
+
+	var response packets.StatusResponse
+	if err := conn.ReadPacket(packet, &response); err != nil {
+		return nil, 0, err
+	}
+	if response.Response == nil {
+		return nil, 0, errors.New("server answered with an empty status")
+	}
+
+	sent := time.Now()
+	if err := conn.WritePacket(&packets.StatusPing{Payload: sent.UnixMilli()}); err != nil {
+		return nil, 0, err
+	}
+
+	pong, err := conn.Read()
+	if err != nil {
+		return nil, 0, err
+	}
+	if pong.ID != (&packets.StatusPing{}).ID(protocol) {
+		return nil, 0, errors.New("server didn't answer the ping")
+	}
+
+	return response.Response, time.Since(sent), nil
+}
+
+// statusCache keeps the statuses of servers for a while, so the server list of every client
+// doesn't ping every server.
+type statusCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedStatus
+	// pings are the pings in flight, which clients missing the cache meanwhile wait for
+	pings map[string]*statusPing
+}
+
+type cachedStatus struct {
+	response *status.Response
+	expires  time.Time
+}
+
+// statusPing is a ping of a server in flight, done is closed once it answered or failed.
+type statusPing struct {
+	done     chan struct{}
+	response *status.Response
+	err      error
+}
+
+func newStatusCache() *statusCache {
+	return &statusCache{entries: make(map[string]cachedStatus), pings: make(map[string]*statusPing)}
+}
+
+// get returns the cached status of the server, pinging it if there is none or it expired. Misses
+// while the server is pinged wait for that ping. Failed pings aren't cached.
+func (c *statusCache) get(server *ServerInfo, protocol int, timeout time.Duration, ttl time.Duration, proxyHeader []byte) (*status.Response, error) {
+	key := server.Name() + "/" + server.Addr().String()
+
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok && time.Now().Before(entry.expires) {
+		c.mu.Unlock()
+		return entry.response, nil
+	}
+	if ping, ok := c.pings[key]; ok {
+		c.mu.Unlock()
+		<-ping.done
+		return ping.response, ping.err
+	}
+	ping := &statusPing{done: make(chan struct{})}
+	c.pings[key] = ping
+	c.mu.Unlock()
+
+	ping.response, _, ping.err = pingServer(server, protocol, timeout, proxyHeader)
+
+	c.mu.Lock()
+	if ping.err == nil {
+		c.entries[key] = cachedStatus{response: ping.response, expires: time.Now().Add(ttl)}
+	}
+	delete(c.pings, key)
+	c.mu.Unlock()
+	close(ping.done)
+
+	return ping.response, ping.err
+}
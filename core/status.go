@@ -0,0 +1,183 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gopro/core/component"
+	"gopro/core/proto"
+	"gopro/core/proto/status"
+	"image/png"
+	"os"
+	"sync"
+	"time"
+)
+
+// statusSampleSize is how many players the server list shows when hovering over the player count.
+const statusSampleSize = 12
+
+// statusResponse is the status shown in the server list of a client of the listener and protocol
+// version, before ServerStatusRequestEvent handlers change it.
+func (p *Proxy) statusResponse(listener *Listener, protocol int) *status.Response {
+	config := p.Config().Status
+
+	response := &status.Response{
+		Version:     status.Version{Name: "gopro " + proto.SupportedVersions(), Protocol: proto.MaximumProtocol},
+		Players:     status.Players{Max: config.MaxPlayers, Online: p.PlayerCount(), Sample: p.statusSample()},
+		Description: *component.ParseMarkup(listener.MOTD(), nil),
+		Favicon:     p.Favicon(),
+	}
+
+	// clients of other versions see the server as incompatible
+	if proto.Supported(protocol) {
+		response.Version.Protocol = protocol
+	} else {
+		protocol = proto.MaximumProtocol
+	}
+
+	switch config.PingBackends {
+	case PingBackendsCounts:
+		p.addBackendCounts(response, protocol)
+	case PingBackendsMOTD:
+		// listeners with a MOTD of their own show it
+		if listener.Config().MOTD == "" {
+			p.addBackendMOTD(response, protocol)
+		}
+	}
+
+	return response
+}
+
+func (p *Proxy) statusSample() []status.SamplePlauer {
+	players := p.Players()
+	if len(players) > statusSampleSize {
+		players = players[:statusSampleSize]
+	}
+
+	sample := make([]status.SamplePlauer, len(players))
+	for i, player := range players {
+		sample[i] = status.SamplePlauer{Name: player.Username(), Id: player.UUID().String()}
+	}
+
+	return sample
+}
+
+func (p *Proxy) pingTimeouts() (timeout time.Duration, cache time.Duration) {
+	config := p.Config().Status
+	return time.Duration(config.PingTimeout) * time.Millisecond, time.Duration(config.PingCache) * time.Millisecond
+}
+
+// addBackendCounts replaces the player counts with the sums of the counts of the backends that
+// answer in time. The counts of the proxy stay if none does.
+func (p *Proxy) addBackendCounts(response *status.Response, protocol int) {
+	timeout, cache := p.pingTimeouts()
+	servers := p.Servers()
+
+	var wg sync.WaitGroup
+	responses := make([]*status.Response, len(servers))
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server *ServerInfo) {
+			defer wg.Done()
+
+			backend, err := p.statusCache.get(server, protocol, timeout, cache, p.serverProxyHeader(nil, server))
+			if err != nil {
+				p.logger.Debug().Err(err).Str("server", server.Name()).Msg("Failed to ping server")
+				return
+			}
+			responses[i] = backend
+		}(i, server)
+	}
+	wg.Wait()
+
+	online, max, answered := 0, 0, false
+	for _, backend := range responses {
+		if backend != nil {
+			online += backend.Players.Online
+			max += backend.Players.Max
+			answered = true
+		}
+	}
+
+	if answered {
+		response.Players.Online = online
+		response.Players.Max = max
+	}
+}
+
+// addBackendMOTD shows the MOTD of the configured server, and its favicon if the proxy has none.
+func (p *Proxy) addBackendMOTD(response *status.Response, protocol int) {
+	config := p.Config()
+
+	name := config.Status.MOTDServer
+	if name == "" && len(config.Try) > 0 {
+		name = config.Try[0]
+	}
+	if group := p.Group(name); group != nil && len(group.servers) > 0 {
+		name = group.servers[0]
+	}
+	server := p.Server(name)
+	if server == nil {
+		return
+	}
+
+	timeout, cache := p.pingTimeouts()
+	backend, err := p.statusCache.get(server, protocol, timeout, cache, p.serverProxyHeader(nil, server))
+	if err != nil {
+		p.logger.Debug().Err(err).Str("server", server.Name()).Msg("Failed to ping server")
+		return
+	}
+
+	// the response of the cache is shared, handlers of the status event may change the copy
+	response.Description = *backend.Description.Clone()
+	if response.Favicon == "" {
+		response.Favicon = backend.Favicon
+	}
+}
+
+// Favicon is the favicon shown in the server list, as a data URL.
+func (p *Proxy) Favicon() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.favicon
+}
+
+// loadFavicon reads the favicon of the config, keeping none if it is missing or invalid.
+func (p *Proxy) loadFavicon() {
+	path := p.Config().Status.Favicon
+
+	favicon := ""
+	if path != "" {
+		var err error
+		favicon, err = readFavicon(path)
+		if errors.Is(err, os.ErrNotExist) {
+			p.logger.Debug().Str("file", path).Msg("No favicon")
+		} else if err != nil {
+			p.logger.Error().Err(err).Str("file", path).Msg("Failed to load favicon")
+		}
+	}
+
+	p.mu.Lock()
+	p.favicon = favicon
+	p.mu.Unlock()
+}
+
+// readFavicon reads a 64x64 PNG image as a data URL.
+func readFavicon(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("not a PNG image: %w", err)
+	}
+	if config.Width != 64 || config.Height != 64 {
+		return "", fmt.Errorf("image is %dx%d, favicons have to be 64x64", config.Width, config.Height)
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -32,24 +32,11 @@ func (h *statusHandler) Handle(packet *proto.Packet) {
 
 func (h *statusHandler) handleStatusRequest() {
 	h.logger.Debug().Msg("Handling Status Request")
-	e := event.NewServerStatusRequestEvent()
-	h.deps.EventBus.Trigger(e)
+	response := h.deps.Proxy.statusResponse(h.conn.listener, h.conn.ProtocolVersion)
+	e := event.NewServerStatusRequestEvent(h.conn.Conn.RemoteAddr(), h.conn.ProtocolVersion, response)
+	h.deps.EventBus.Fire(e)
 
-	packet, err := packets.NewStatusResponse(e.Response)
-	if err != nil {
-		h.logger.Error().Err(err).Str("packet", "request").Msg("Error while initializing packet, closing connection")
-		h.conn.Close()
-		return
-	}
-
-	toSend, err := proto.Write(0x00, &packet.JSONResponse)
-	if err != nil {
-		h.logger.Error().Err(err).Str("packet", "response").Msg("Error while writing packet, closing connection")
-		h.conn.Close()
-		return
-	}
-
-	err = h.conn.SendPacket(toSend)
+	err := h.conn.WritePacket(packets.NewStatusResponse(e.Response))
 	if err != nil {
 		h.logger.Error().Err(err).Str("packet", "response").Msg("Error while sending packet, closing connection")
 		h.conn.Close()
@@ -60,21 +47,15 @@ func (h *statusHandler) handleStatusRequest() {
 
 func (h *statusHandler) handlePing(packet *proto.Packet) {
 	h.logger.Debug().Msg("Handling Status Ping")
-	ping, err := packets.NewStatusPing(packet)
+	var ping packets.StatusPing
+	err := h.conn.ReadPacket(packet, &ping)
 	if err != nil {
 		h.logger.Error().Err(err).Str("packet", "ping").Msg("Error while reading packet, closing connection")
 		h.conn.Close()
 		return
 	}
 
-	toSend, err := proto.Write(0x01, &ping.Payload)
-	if err != nil {
-		h.logger.Error().Err(err).Str("packet", "pong").Msg("Error while writing packet, closing connection")
-		h.conn.Close()
-		return
-	}
-
-	err = h.conn.SendPacket(toSend)
+	err = h.conn.WritePacket(&ping)
 	if err != nil {
 		h.logger.Error().Err(err).Str("packet", "pong").Msg("Error while sending packet, closing connection")
 		h.conn.Close()
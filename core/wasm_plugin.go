@@ -0,0 +1,432 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"gopro/core/command"
+	"gopro/core/component"
+	"gopro/core/event"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// wasmManifestSection is the custom section a WebAssembly plugin describes itself in, as JSON
+// with the fields of wasmManifest. Without it the plugin is named after its file.
+const wasmManifestSection = "gopro.plugin"
+
+// wasmCallTimeout is how long a call into a WebAssembly plugin may run. A plugin exceeding it
+// is stopped for good.
+const wasmCallTimeout = 2 * time.Second
+
+type wasmManifest struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	Version          string   `json:"version"`
+	Authors          []string `json:"authors"`
+	Description      string   `json:"description"`
+	Dependencies     []string `json:"dependencies"`
+	SoftDependencies []string `json:"soft_dependencies"`
+}
+
+// wasmPlugin runs a WebAssembly module without access to the file system or network. It talks
+// to the proxy through the functions of the "gopro" host module:
+//
+//	log(level, ptr, len)                             0 debug, 1 info, 2 warn, 3 error
+//	subscribe(namePtr, nameLen) -> status            0 subscribed, 1 unknown event
+//	send_message(uuidPtr, uuidLen, msgPtr, msgLen) -> status
+//	disconnect(uuidPtr, uuidLen, msgPtr, msgLen) -> status
+//	broadcast(msgPtr, msgLen)
+//	register_command(namePtr, nameLen) -> status     0 registered, 1 invalid or taken name
+//
+// Messages are markup as read by component.ParseMarkup, the status of player functions is 0 on
+// success and 1 if the player isn't online. The module may export:
+//
+//	gopro_init() -> status                           non-zero fails loading the plugin
+//	gopro_shutdown()
+//	gopro_alloc(size) -> ptr                         required to receive events and commands
+//	gopro_on_event(namePtr, nameLen, jsonPtr, jsonLen) -> cancel
+//	gopro_on_command(namePtr, nameLen, jsonPtr, jsonLen)
+//
+// Events and commands arrive as JSON, a non-zero result of gopro_on_event cancels cancellable
+// events. Commands take the rest of the input as one argument. Modules are instantiated as
+// reactors, _initialize runs before gopro_init.
+type wasmPlugin struct {
+	proxy    *Proxy
+	id       string
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	logger   zerolog.Logger
+
+	// mu serializes the calls into the module, which isn't safe for concurrent use
+	mu            sync.Mutex
+	module        api.Module
+	subscriptions []*event.Subscription
+	commands      []string
+}
+
+func (p *Proxy) openWasmPlugin(path string) (Plugin, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return Plugin{}, err
+	}
+
+	ctx := context.Background()
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCustomSections(true).WithCloseOnContextDone(true))
+
+	compiled, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		_ = runtime.Close(ctx)
+		return Plugin{}, err
+	}
+
+	manifest := wasmManifest{ID: strings.ToLower(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))}
+	for _, section := range compiled.CustomSections() {
+		if section.Name() != wasmManifestSection {
+			continue
+		}
+		if err := json.Unmarshal(section.Data(), &manifest); err != nil {
+			_ = runtime.Close(ctx)
+			return Plugin{}, fmt.Errorf("reading %s section: %w", wasmManifestSection, err)
+		}
+	}
+
+	w := &wasmPlugin{proxy: p, id: manifest.ID, runtime: runtime, compiled: compiled, logger: p.logger}
+
+	return Plugin{
+		ID:               manifest.ID,
+		Name:             manifest.Name,
+		Version:          manifest.Version,
+		Authors:          manifest.Authors,
+		Description:      manifest.Description,
+		Dependencies:     manifest.Dependencies,
+		SoftDependencies: manifest.SoftDependencies,
+		Init:             w.init,
+		Shutdown:         w.shutdown,
+	}, nil
+}
+
+func (w *wasmPlugin) init(p *Proxy) error {
+	ctx := context.Background()
+	if container := p.Plugin(w.id); container != nil {
+		w.logger = container.Logger()
+	}
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, w.runtime); err != nil {
+		return err
+	}
+
+	_, err := w.runtime.NewHostModuleBuilder("gopro").
+		NewFunctionBuilder().WithFunc(w.hostLog).Export("log").
+		NewFunctionBuilder().WithFunc(w.hostSubscribe).Export("subscribe").
+		NewFunctionBuilder().WithFunc(w.hostSendMessage).Export("send_message").
+		NewFunctionBuilder().WithFunc(w.hostDisconnect).Export("disconnect").
+		NewFunctionBuilder().WithFunc(w.hostBroadcast).Export("broadcast").
+		NewFunctionBuilder().WithFunc(w.hostRegisterCommand).Export("register_command").
+		Instantiate(ctx)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	module, err := w.runtime.InstantiateModule(ctx, w.compiled, wazero.NewModuleConfig().WithName(w.id).WithStartFunctions("_initialize"))
+	if err != nil {
+		return err
+	}
+	w.module = module
+
+	results, err := w.call("gopro_init")
+	if err != nil {
+		return err
+	}
+	if len(results) > 0 && results[0] != 0 {
+		return fmt.Errorf("gopro_init failed with status %d", results[0])
+	}
+
+	return nil
+}
+
+func (w *wasmPlugin) shutdown(*Proxy) error {
+	w.mu.Lock()
+	for _, subscription := range w.subscriptions {
+		subscription.Unsubscribe()
+	}
+	w.subscriptions = nil
+	for _, name := range w.commands {
+		w.proxy.commands.Unregister(name)
+	}
+	w.commands = nil
+
+	_, err := w.call("gopro_shutdown")
+	w.mu.Unlock()
+
+	return errors.Join(err, w.runtime.Close(context.Background()))
+}
+
+// call calls the export if the module has it. The caller holds mu.
+func (w *wasmPlugin) call(name string, params ...uint64) ([]uint64, error) {
+	if w.module == nil {
+		return nil, errors.New("module isn't running")
+	}
+
+	function := w.module.ExportedFunction(name)
+	if function == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), wasmCallTimeout)
+	defer cancel()
+
+	return function.Call(ctx, params...)
+}
+
+// write copies the data into memory allocated by the module. The caller holds mu.
+func (w *wasmPlugin) write(data []byte) (uint32, error) {
+	results, err := w.call("gopro_alloc", uint64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, errors.New("module doesn't export gopro_alloc")
+	}
+
+	ptr := uint32(results[0])
+	if !w.module.Memory().Write(ptr, data) {
+		return 0, errors.New("gopro_alloc returned memory out of range")
+	}
+
+	return ptr, nil
+}
+
+// dispatch hands an event to the module, returning whether it asked to cancel the event.
+func (w *wasmPlugin) dispatch(name string, payload any) bool {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		w.logger.Error().Err(err).Str("event", name).Msg("Failed to encode event")
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	namePtr, err := w.write([]byte(name))
+	if err != nil {
+		w.logger.Error().Err(err).Str("event", name).Msg("Failed to pass event to plugin")
+		return false
+	}
+	dataPtr, err := w.write(data)
+	if err != nil {
+		w.logger.Error().Err(err).Str("event", name).Msg("Failed to pass event to plugin")
+		return false
+	}
+
+	results, err := w.call("gopro_on_event", uint64(namePtr), uint64(len(name)), uint64(dataPtr), uint64(len(data)))
+	if err != nil {
+		w.logger.Error().Err(err).Str("event", name).Msg("Plugin failed handling event")
+		return false
+	}
+
+	return len(results) > 0 && results[0] != 0
+}
+
+// runCommand hands a command run by the source to the module.
+func (w *wasmPlugin) runCommand(name string, ctx *command.Context) error {
+	payload := map[string]any{"source": ctx.Source.Name(), "args": ctx.String("args")}
+	if player, ok := ctx.Source.(*Player); ok {
+		payload["player"] = toWasmPlayer(player)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	namePtr, err := w.write([]byte(name))
+	if err != nil {
+		return err
+	}
+	dataPtr, err := w.write(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.call("gopro_on_command", uint64(namePtr), uint64(len(name)), uint64(dataPtr), uint64(len(data)))
+	return err
+}
+
+func readString(m api.Module, ptr uint32, length uint32) string {
+	data, ok := m.Memory().Read(ptr, length)
+	if !ok {
+		return ""
+	}
+
+	return string(data)
+}
+
+func (w *wasmPlugin) hostLog(_ context.Context, m api.Module, level uint32, ptr uint32, length uint32) {
+	message := readString(m, ptr, length)
+
+	switch level {
+	case 0:
+		w.logger.Debug().Msg(message)
+	case 2:
+		w.logger.Warn().Msg(message)
+	case 3:
+		w.logger.Error().Msg(message)
+	default:
+		w.logger.Info().Msg(message)
+	}
+}
+
+// hostSubscribe is called from within a call into the module, so mu is already held.
+func (w *wasmPlugin) hostSubscribe(_ context.Context, m api.Module, ptr uint32, length uint32) uint32 {
+	name := readString(m, ptr, length)
+
+	subscribe, ok := wasmEvents[name]
+	if !ok {
+		return 1
+	}
+
+	subscription := subscribe(w.proxy.eventBus, func(payload any) bool {
+		return w.dispatch(name, payload)
+	})
+	w.subscriptions = append(w.subscriptions, subscription.Named(w.id))
+
+	return 0
+}
+
+// hostRegisterCommand is called from within a call into the module, so mu is already held.
+func (w *wasmPlugin) hostRegisterCommand(_ context.Context, m api.Module, ptr uint32, length uint32) uint32 {
+	name := readString(m, ptr, length)
+	if name == "" || strings.ContainsAny(name, " /") {
+		return 1
+	}
+
+	run := func(ctx *command.Context) error {
+		return w.runCommand(name, ctx)
+	}
+	// replacing a command of the proxy or another plugin would also remove it on unload
+	registered := w.proxy.commands.TryRegister(command.Literal(name).
+		Executes(run).
+		Then(command.Argument("args", command.GreedyString()).Executes(run)))
+	if !registered {
+		w.logger.Warn().Str("command", name).Msg("Command is registered already, not registering it")
+		return 1
+	}
+	w.commands = append(w.commands, name)
+
+	return 0
+}
+
+func (w *wasmPlugin) player(m api.Module, ptr uint32, length uint32) *Player {
+	id, err := uuid.Parse(readString(m, ptr, length))
+	if err != nil {
+		return nil
+	}
+
+	return w.proxy.Player(id)
+}
+
+func (w *wasmPlugin) hostSendMessage(_ context.Context, m api.Module, uuidPtr, uuidLength, messagePtr, messageLength uint32) uint32 {
+	player := w.player(m, uuidPtr, uuidLength)
+	if player == nil {
+		return 1
+	}
+
+	if err := player.SendMessage(component.ParseMarkup(readString(m, messagePtr, messageLength), nil)); err != nil {
+		w.logger.Debug().Err(err).Msg("Failed to send message")
+	}
+
+	return 0
+}
+
+func (w *wasmPlugin) hostDisconnect(_ context.Context, m api.Module, uuidPtr, uuidLength, reasonPtr, reasonLength uint32) uint32 {
+	player := w.player(m, uuidPtr, uuidLength)
+	if player == nil {
+		return 1
+	}
+
+	player.Disconnect(component.ParseMarkup(readString(m, reasonPtr, reasonLength), nil))
+	return 0
+}
+
+func (w *wasmPlugin) hostBroadcast(_ context.Context, m api.Module, ptr uint32, length uint32) {
+	message := component.ParseMarkup(readString(m, ptr, length), nil)
+
+	for _, player := range w.proxy.Players() {
+		_ = player.SendMessage(message)
+	}
+}
+
+// wasmPlayer is how players are passed to WebAssembly plugins.
+type wasmPlayer struct {
+	UUID     string `json:"uuid"`
+	Username string `json:"username"`
+	Address  string `json:"address"`
+}
+
+func toWasmPlayer(player event.Player) wasmPlayer {
+	return wasmPlayer{UUID: player.UUID().String(), Username: player.Username(), Address: player.RemoteAddr().String()}
+}
+
+func serverName(server event.Server) string {
+	if server == nil {
+		return ""
+	}
+
+	return server.Name()
+}
+
+// wasmEvents are the events WebAssembly plugins can subscribe to, by name. The handler passes
+// the payload to the plugin and reports whether it asked to cancel the event.
+var wasmEvents = map[string]func(bus *event.Bus, handle func(payload any) bool) *event.Subscription{
+	"PreLoginEvent": func(bus *event.Bus, handle func(any) bool) *event.Subscription {
+		return event.Subscribe(bus, event.Moderate, func(e *event.PreLoginEvent) {
+			payload := map[string]any{"username": e.Username, "address": e.RemoteAddr.String()}
+			if handle(payload) {
+				e.Reject(nil)
+			}
+		})
+	},
+	"PostLoginEvent": func(bus *event.Bus, handle func(any) bool) *event.Subscription {
+		return event.Subscribe(bus, event.Moderate, func(e *event.PostLoginEvent) {
+			handle(map[string]any{"player": toWasmPlayer(e.Player)})
+		})
+	},
+	"ServerPreConnectEvent": func(bus *event.Bus, handle func(any) bool) *event.Subscription {
+		return event.Subscribe(bus, event.Moderate, func(e *event.ServerPreConnectEvent) {
+			payload := map[string]any{"player": toWasmPlayer(e.Player), "server": serverName(e.Target)}
+			if handle(payload) {
+				e.SetCancelled(true)
+			}
+		})
+	},
+	"ServerPostConnectEvent": func(bus *event.Bus, handle func(any) bool) *event.Subscription {
+		return event.Subscribe(bus, event.Moderate, func(e *event.ServerPostConnectEvent) {
+			payload := map[string]any{"player": toWasmPlayer(e.Player), "previous": serverName(e.Previous)}
+			if player, ok := e.Player.(*Player); ok {
+				payload["server"] = serverName(asEventServer(player.CurrentServer()))
+			}
+			handle(payload)
+		})
+	},
+	"DisconnectEvent": func(bus *event.Bus, handle func(any) bool) *event.Subscription {
+		return event.Subscribe(bus, event.Moderate, func(e *event.DisconnectEvent) {
+			handle(map[string]any{"player": toWasmPlayer(e.Player)})
+		})
+	},
+}
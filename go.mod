@@ -1,11 +1,16 @@
 module gopro
 
-go 1.22
+go 1.22.0
+
+require (
+	github.com/google/uuid v1.6.0
+	github.com/rs/zerolog v1.33.0
+	github.com/tetratelabs/wazero v1.9.0
+	golang.org/x/term v0.21.0
+)
 
 require (
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/rs/zerolog v1.33.0 // indirect
 	golang.org/x/sys v0.21.0 // indirect
 )